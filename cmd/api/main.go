@@ -1,6 +1,10 @@
 package main
 
-import "github.com/abyan-dev/productivity/pkg/server"
+import (
+	_ "time/tzdata"
+
+	"github.com/abyan-dev/productivity/pkg/server"
+)
 
 func main() {
 	srv := server.Server{}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/rollup"
+	"github.com/abyan-dev/productivity/pkg/utils"
+)
+
+// Rebuilds the daily metrics rollups from the raw session and task rows.
+//
+//	go run ./cmd/rollup -user someone@example.com -from 2024-01-01 -to 2024-07-01
+//
+// Without -user every user with recorded activity is rebuilt, and without
+// -from or -to the range is open on that end.
+func main() {
+	user := flag.String("user", "", "email of the user to rebuild, all users if empty")
+	fromStr := flag.String("from", "", "first day to rebuild (YYYY-MM-DD), inclusive")
+	toStr := flag.String("to", "", "last day to rebuild (YYYY-MM-DD), exclusive")
+	flag.Parse()
+
+	var from, to time.Time
+	var err error
+	if *fromStr != "" {
+		if from, err = time.Parse(rollup.DateLayout, *fromStr); err != nil {
+			log.Fatalf("Invalid -from date: %v", err)
+		}
+	}
+	if *toStr != "" {
+		if to, err = time.Parse(rollup.DateLayout, *toStr); err != nil {
+			log.Fatalf("Invalid -to date: %v", err)
+		}
+	}
+
+	config, err := utils.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	db, err := utils.InitDB(config)
+	if err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
+
+	userID := ""
+	if *user != "" {
+		var found model.User
+		if err := db.Where("email = ?", *user).First(&found).Error; err != nil {
+			log.Fatalf("Error finding user %s: %v", *user, err)
+		}
+		userID = found.ID
+	}
+
+	slog.Info("Rebuilding rollups...", slog.String("user", *user), slog.String("from", *fromStr), slog.String("to", *toStr))
+	if err := rollup.Rebuild(db, userID, from, to); err != nil {
+		log.Fatalf("Error rebuilding rollups: %v", err)
+	}
+	slog.Info("Successfully rebuilt rollups")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/utils"
+)
+
+// Backfills the stable user IDs of existing users from a CSV file exported
+// from the auth service, with the ID and the email of one user per line.
+//
+//	go run ./cmd/users -file users.csv
+//
+// The server's migration already keys every user's data by an ID derived
+// from their email, which is replaced by their stable ID the first time they
+// sign in with a token carrying it. Linking them here does that ahead of
+// time, and is optional.
+func main() {
+	file := flag.String("file", "", "CSV file of user IDs and emails")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatalf("Missing -file")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Error opening %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		log.Fatalf("Error reading %s: %v", *file, err)
+	}
+
+	users := map[string]string{}
+	for i, record := range records {
+		if len(record) != 2 {
+			log.Fatalf("Line %d does not have exactly an ID and an email", i+1)
+		}
+		id, email := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		if i == 0 && id == "id" {
+			continue
+		}
+		users[id] = email
+	}
+
+	config, err := utils.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	db, err := utils.InitDB(config)
+	if err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
+
+	if _, err := account.MigrateUserIDs(db); err != nil {
+		log.Fatalf("Error keying tables by user ID: %v", err)
+	}
+
+	slog.Info("Linking user IDs...", slog.Int("users", len(users)))
+	linked, err := account.Link(db, users)
+	if err != nil {
+		log.Fatalf("Error linking user IDs after %d users: %v", linked, err)
+	}
+	slog.Info("Successfully linked user IDs", slog.Int("linked", linked))
+}
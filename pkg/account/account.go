@@ -0,0 +1,203 @@
+package account
+
+import (
+	"errors"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrEmailTaken is returned when an email is already linked to another user
+// ID.
+var ErrEmailTaken = errors.New("email belongs to another user")
+
+// LegacyID returns the ID of a user only seen with legacy tokens, which
+// carry no sub claim, derived from their email. It is replaced by the ID in
+// the sub claim once the user shows up with such a token.
+func LegacyID(email string) string {
+	return "legacy:" + utils.HashToken(email)
+}
+
+// Resolve returns the user with the given stable ID. A user seen for the
+// first time is linked to the email in their token, taking over the data
+// they had under their legacy ID, if any. Legacy tokens without an ID
+// resolve to the user with their email.
+func Resolve(db *gorm.DB, id string, email string) (model.User, error) {
+	if id == "" {
+		return resolveLegacy(db, email)
+	}
+
+	var user model.User
+	err := db.Where("id = ?", id).First(&user).Error
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.User{}, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = link(tx, id, email)
+		return err
+	})
+	return user, err
+}
+
+func resolveLegacy(db *gorm.DB, email string) (model.User, error) {
+	var user model.User
+	err := db.Where("email = ?", email).First(&user).Error
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.User{}, err
+	}
+
+	now := time.Now().UTC()
+	user = model.User{ID: LegacyID(email), Email: email, CreatedAt: now, UpdatedAt: now}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&user).Error; err != nil {
+		return model.User{}, err
+	}
+	err = db.Where("email = ?", email).First(&user).Error
+	return user, err
+}
+
+// link links the ID to the email, adopting the legacy user with that email
+// if there is one.
+func link(tx *gorm.DB, id string, email string) (model.User, error) {
+	var user model.User
+	adopted, err := adopt(tx, LegacyID(email), id)
+	if err != nil {
+		return user, err
+	}
+	if !adopted {
+		now := time.Now().UTC()
+		user = model.User{ID: id, Email: email, CreatedAt: now, UpdatedAt: now}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&user)
+		if result.Error != nil {
+			return user, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return user, nil
+		}
+	}
+
+	// Either the user was adopted, another request linked them first, or
+	// the email is linked to someone else.
+	err = tx.Where("id = ?", id).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, ErrEmailTaken
+	}
+	return user, err
+}
+
+// adopt moves the data of the legacy user with the given ID to the user
+// with the given stable ID, and reports whether there was such a user. This
+// happens once per user, the first time they show up with a sub claim.
+func adopt(tx *gorm.DB, legacyID string, id string) (bool, error) {
+	var legacy model.User
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", legacyID).First(&legacy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, owned := range keyedTables() {
+		if owned.Table == "token_revocations" {
+			continue
+		}
+		err := tx.Model(owned.Model).Where("user_id = ?", legacyID).UpdateColumn("user_id", id).Error
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := moveRevocation(tx, legacyID, id); err != nil {
+		return false, err
+	}
+
+	err = tx.Model(&model.User{}).Where("id = ?", legacyID).
+		UpdateColumns(map[string]interface{}{"id": id, "updated_at": time.Now().UTC()}).Error
+	return err == nil, err
+}
+
+// keyedTables returns every table keyed by the user's ID, erasure requests
+// included.
+func keyedTables() []model.OwnedTable {
+	tables := append([]model.OwnedTable{}, model.OwnedTables...)
+	return append(tables, model.OwnedTable{Table: "erasure_requests", Model: &model.ErasureRequest{}})
+}
+
+// Link links user IDs to emails, as a backfill from the auth service's user
+// list does. Pairs that are already linked are left alone. It returns how
+// many pairs it linked.
+func Link(db *gorm.DB, users map[string]string) (int, error) {
+	linked := 0
+	for id, email := range users {
+		var existing model.User
+		err := db.Where("id = ?", id).First(&existing).Error
+		if err == nil {
+			if existing.Email != email {
+				return linked, ErrEmailTaken
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return linked, err
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			_, err := link(tx, id, email)
+			return err
+		})
+		if err != nil {
+			return linked, err
+		}
+		linked++
+	}
+	return linked, nil
+}
+
+// ChangeEmail changes the email of the user with the given stable ID. The
+// user's data is keyed by their ID, so none of it has to move. Users not
+// linked yet are linked to oldEmail first. Changing to the email the user
+// already has does nothing, so notifications can be replayed.
+func ChangeEmail(db *gorm.DB, id string, oldEmail string, newEmail string) (model.User, error) {
+	var user model.User
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user, err = link(tx, id, oldEmail)
+		}
+		if err != nil {
+			return err
+		}
+
+		if user.Email == newEmail {
+			return nil
+		}
+
+		var linked int64
+		if err := tx.Model(&model.User{}).Where("email = ?", newEmail).Count(&linked).Error; err != nil {
+			return err
+		}
+		if linked > 0 {
+			return ErrEmailTaken
+		}
+
+		user.Email = newEmail
+		user.UpdatedAt = time.Now().UTC()
+		return tx.Model(&user).UpdateColumns(map[string]interface{}{"email": user.Email, "updated_at": user.UpdatedAt}).Error
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
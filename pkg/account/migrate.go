@@ -0,0 +1,111 @@
+package account
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MigrateUserIDs re-keys the tables that were keyed by the user's email to
+// the user's ID, and returns how many tables it converted. Emails that are
+// not linked to an ID yet are given their legacy ID. Tables that have
+// already been converted are left alone, and it must run before the tables
+// are auto-migrated.
+func MigrateUserIDs(db *gorm.DB) (int, error) {
+	if err := db.AutoMigrate(&model.User{}); err != nil {
+		return 0, err
+	}
+
+	converted := 0
+	for _, owned := range keyedTables() {
+		migrator := db.Migrator()
+		if !migrator.HasTable(owned.Table) || !migrator.HasColumn(owned.Table, "user_email") {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return migrateTable(tx, owned)
+		})
+		if err != nil {
+			return converted, fmt.Errorf("%s: %w", owned.Table, err)
+		}
+		converted++
+	}
+
+	return converted, nil
+}
+
+func migrateTable(tx *gorm.DB, owned model.OwnedTable) error {
+	var emails []string
+	if err := tx.Table(owned.Table).Where("user_email <> ''").Distinct().Pluck("user_email", &emails).Error; err != nil {
+		return err
+	}
+
+	// Revocations outlive the users they revoke, such as erased ones, who
+	// must not be brought back by their revocation.
+	if owned.Table != "token_revocations" {
+		now := time.Now().UTC()
+		for _, email := range emails {
+			user := model.User{ID: LegacyID(email), Email: email, CreatedAt: now, UpdatedAt: now}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&user).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	table := tx.Statement.Quote(owned.Table)
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN user_id varchar(100) NOT NULL DEFAULT ''", table),
+		fmt.Sprintf("UPDATE %s SET user_id = users.id FROM users WHERE users.email = %s.user_email", table, table),
+	}
+	for _, sql := range statements {
+		if err := tx.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+
+	var orphans []string
+	if err := tx.Table(owned.Table).Where("user_id = ''").Distinct().Pluck("user_email", &orphans).Error; err != nil {
+		return err
+	}
+	for _, email := range orphans {
+		if err := tx.Table(owned.Table).Where("user_id = '' AND user_email = ?", email).UpdateColumn("user_id", LegacyID(email)).Error; err != nil {
+			return err
+		}
+	}
+
+	statements = []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN user_id DROP DEFAULT", table)}
+
+	// Tables keyed by the email alone, or along with the day, get the ID in
+	// its place.
+	statement := &gorm.Statement{DB: tx}
+	if err := statement.Parse(owned.Model); err != nil {
+		return err
+	}
+	if primaryKey := statement.Schema.PrimaryFieldDBNames; slices.Contains(primaryKey, "user_id") {
+		columns := make([]string, len(primaryKey))
+		for i, name := range primaryKey {
+			columns[i] = tx.Statement.Quote(name)
+		}
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table, tx.Statement.Quote(owned.Table+"_pkey")),
+			fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s)", table, strings.Join(columns, ", ")),
+		)
+	}
+
+	// Indexes on the email go along with it, and are created again on the
+	// ID when the table is auto-migrated.
+	statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN user_email", table))
+
+	for _, sql := range statements {
+		if err := tx.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
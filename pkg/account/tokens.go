@@ -0,0 +1,115 @@
+package account
+
+import (
+	"errors"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokeTokens revokes every token issued to the user before now: access
+// and refresh tokens alike, which are refused until lifetime, the longest
+// any of them lives, has passed, and personal access tokens and app
+// passwords, which are deleted.
+func RevokeTokens(db *gorm.DB, userID string, now time.Time, lifetime time.Duration) error {
+	revokedBefore := now.UTC().Truncate(time.Second)
+	revocation := model.TokenRevocation{UserID: userID, RevokedBefore: revokedBefore, ExpiresAt: revokedBefore.Add(lifetime)}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "expires_at"}),
+	}).Create(&revocation).Error
+	if err != nil {
+		return err
+	}
+
+	for _, credential := range []interface{}{&model.PersonalAccessToken{}, &model.AppPassword{}} {
+		if err := db.Where("user_id = ? AND created_at < ?", userID, revokedBefore).Delete(credential).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveRevocation moves the revocation of one user ID to another, keeping
+// the later one if both have one.
+func moveRevocation(tx *gorm.DB, fromID string, toID string) error {
+	var revocation model.TokenRevocation
+	err := tx.Where("user_id = ?", fromID).First(&revocation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Delete(&revocation).Error; err != nil {
+		return err
+	}
+	revocation.UserID = toID
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"revoked_before": gorm.Expr("GREATEST(token_revocations.revoked_before, excluded.revoked_before)"),
+			"expires_at":     gorm.Expr("GREATEST(token_revocations.expires_at, excluded.expires_at)"),
+		}),
+	}).Create(&revocation).Error
+}
+
+// TokenUserID returns the ID of the user a token belongs to: the one in its
+// sub claim, or for legacy tokens, the one of the user with its email.
+func TokenUserID(db *gorm.DB, claims jwt.MapClaims) (string, error) {
+	if subject, _ := claims["sub"].(string); subject != "" {
+		return subject, nil
+	}
+
+	email, _ := claims["email"].(string)
+	var user model.User
+	err := db.Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return LegacyID(email), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.ID, nil
+}
+
+// TokenRevoked reports whether the token with the given claims was issued
+// before its user's tokens were revoked. Tokens without an iat claim are
+// taken to have been issued lifetime, how long tokens of their type live,
+// before they expire. Tokens with neither claim are treated as issued
+// before any revocation.
+func TokenRevoked(db *gorm.DB, claims jwt.MapClaims, lifetime time.Duration) (bool, error) {
+	userID, err := TokenUserID(db, claims)
+	if err != nil {
+		return false, err
+	}
+
+	var issuedAt time.Time
+	if numericDate, err := claims.GetIssuedAt(); err == nil && numericDate != nil {
+		issuedAt = numericDate.Time
+	} else if numericDate, err := claims.GetExpirationTime(); err == nil && numericDate != nil {
+		issuedAt = numericDate.Time.Add(-lifetime)
+	}
+	return IssuedBeforeRevocation(db, userID, issuedAt)
+}
+
+// IssuedBeforeRevocation reports whether a token of the user issued at the
+// given time has been revoked along with all their others. Times are
+// compared to the second, so tokens issued in the second of the revocation,
+// such as right after signing in again, are not revoked.
+func IssuedBeforeRevocation(db *gorm.DB, userID string, issuedAt time.Time) (bool, error) {
+	var revocation model.TokenRevocation
+	err := db.Where("user_id = ?", userID).First(&revocation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return issuedAt.Truncate(time.Second).Before(revocation.RevokedBefore), nil
+}
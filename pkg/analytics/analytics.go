@@ -0,0 +1,150 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/utils"
+)
+
+// Interval is a span of focus time, typically a completed Pomodoro session.
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+type HeatmapDay struct {
+	Date         string  `json:"date"`
+	FocusMinutes float64 `json:"focus_minutes"`
+	Level        int     `json:"level"`
+}
+
+type Heatmap struct {
+	From            string       `json:"from"`
+	To              string       `json:"to"`
+	TimeZone        string       `json:"time_zone"`
+	MaxFocusMinutes float64      `json:"max_focus_minutes"`
+	Days            []HeatmapDay `json:"days"`
+}
+
+type FocusWindow struct {
+	StartHour    int     `json:"start_hour"`
+	EndHour      int     `json:"end_hour"`
+	FocusMinutes float64 `json:"focus_minutes"`
+	Share        float64 `json:"share"`
+}
+
+type TimeOfDay struct {
+	TimeZone string `json:"time_zone"`
+	// Matrix holds focus minutes indexed by weekday (0 is Sunday) and local
+	// hour of day.
+	Matrix     [7][24]float64 `json:"matrix"`
+	ByHour     [24]float64    `json:"by_hour"`
+	BestWindow *FocusWindow   `json:"best_window"`
+}
+
+const dateLayout = "2006-01-02"
+
+// splitByHour calls fn with the portion of the interval falling into each
+// local clock hour it spans. It steps in absolute time to the next local
+// hour boundary, so that a clock hour repeated when daylight saving time
+// ends is visited twice rather than forever, and zones offset by half an
+// hour split at their own hour boundaries.
+func splitByHour(interval Interval, location *time.Location, fn func(hourStart time.Time, minutes float64)) {
+	for cur := interval.Start; cur.Before(interval.End); {
+		local := cur.In(location)
+		intoHour := time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second + time.Duration(local.Nanosecond())
+		hourStart := local.Add(-intoHour)
+		next := cur.Add(time.Hour - intoHour)
+		if next.After(interval.End) {
+			next = interval.End
+		}
+		fn(hourStart, next.Sub(cur).Minutes())
+		cur = next
+	}
+}
+
+// BuildHeatmap lays out the focus minutes per local date for every day in
+// [from, to), where from and to are local midnights. Each day is assigned a
+// level from 0 to 4 relative to the busiest day, GitHub contribution graph
+// style.
+func BuildHeatmap(minutesByDate map[string]float64, from time.Time, to time.Time, location *time.Location) Heatmap {
+	heatmap := Heatmap{
+		From:     from.In(location).Format(dateLayout),
+		To:       to.In(location).Format(dateLayout),
+		TimeZone: location.String(),
+		Days:     []HeatmapDay{},
+	}
+
+	for day := utils.StartOfDay(from, location); day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+		minutes := minutesByDate[date]
+		if minutes > heatmap.MaxFocusMinutes {
+			heatmap.MaxFocusMinutes = minutes
+		}
+		heatmap.Days = append(heatmap.Days, HeatmapDay{Date: date, FocusMinutes: minutes})
+	}
+
+	for i := range heatmap.Days {
+		heatmap.Days[i].Level = level(heatmap.Days[i].FocusMinutes, heatmap.MaxFocusMinutes)
+	}
+
+	return heatmap
+}
+
+func level(minutes float64, max float64) int {
+	if minutes <= 0 || max <= 0 {
+		return 0
+	}
+	l := int(minutes / max * 4)
+	if l < 1 {
+		return 1
+	}
+	if l > 4 {
+		return 4
+	}
+	return l
+}
+
+// BuildTimeOfDay distributes focus time over a weekday by hour matrix in the
+// given location and detects the best focus window of windowHours
+// consecutive hours, which may wrap around midnight.
+func BuildTimeOfDay(intervals []Interval, location *time.Location, windowHours int) TimeOfDay {
+	result := TimeOfDay{TimeZone: location.String()}
+
+	total := 0.0
+	for _, interval := range intervals {
+		splitByHour(interval, location, func(hourStart time.Time, minutes float64) {
+			result.Matrix[hourStart.Weekday()][hourStart.Hour()] += minutes
+			result.ByHour[hourStart.Hour()] += minutes
+			total += minutes
+		})
+	}
+
+	result.BestWindow = bestWindow(result.ByHour, windowHours, total)
+
+	return result
+}
+
+func bestWindow(byHour [24]float64, windowHours int, total float64) *FocusWindow {
+	if total <= 0 || windowHours < 1 || windowHours > 24 {
+		return nil
+	}
+
+	var best *FocusWindow
+	for start := 0; start < 24; start++ {
+		minutes := 0.0
+		for offset := 0; offset < windowHours; offset++ {
+			minutes += byHour[(start+offset)%24]
+		}
+		if best == nil || minutes > best.FocusMinutes {
+			best = &FocusWindow{
+				StartHour:    start,
+				EndHour:      (start + windowHours) % 24,
+				FocusMinutes: minutes,
+				Share:        minutes / total,
+			}
+		}
+	}
+
+	return best
+}
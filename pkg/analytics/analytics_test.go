@@ -0,0 +1,146 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func at(day int, hour int, minute int) time.Time {
+	return time.Date(2024, 7, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestBuildHeatmap(t *testing.T) {
+	minutesByDate := map[string]float64{
+		"2024-07-22": 60,
+		"2024-07-24": 150,
+		"2024-07-25": 30, // outside the range
+	}
+
+	heatmap := BuildHeatmap(minutesByDate, at(22, 0, 0), at(25, 0, 0), time.UTC)
+
+	expected := []HeatmapDay{
+		{"2024-07-22", 60, 1},
+		{"2024-07-23", 0, 0},
+		{"2024-07-24", 150, 4},
+	}
+
+	if len(heatmap.Days) != len(expected) {
+		t.Fatalf("Days length = %d, want %d", len(heatmap.Days), len(expected))
+	}
+	for i, day := range heatmap.Days {
+		if day != expected[i] {
+			t.Errorf("Day %d = %+v, want %+v", i, day, expected[i])
+		}
+	}
+	if heatmap.MaxFocusMinutes != 150 {
+		t.Errorf("MaxFocusMinutes = %v, want 150", heatmap.MaxFocusMinutes)
+	}
+	if heatmap.From != "2024-07-22" || heatmap.To != "2024-07-25" {
+		t.Errorf("Range = %s..%s, want 2024-07-22..2024-07-25", heatmap.From, heatmap.To)
+	}
+}
+
+func TestBuildHeatmapAcrossDaylightSavingTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	// Clocks in Berlin moved forward on 2024-03-31.
+	from := time.Date(2024, 3, 30, 0, 0, 0, 0, berlin)
+	to := time.Date(2024, 4, 2, 0, 0, 0, 0, berlin)
+
+	heatmap := BuildHeatmap(map[string]float64{"2024-03-31": 25}, from, to, berlin)
+
+	if len(heatmap.Days) != 3 || heatmap.Days[1].Date != "2024-03-31" || heatmap.Days[1].FocusMinutes != 25 {
+		t.Errorf("Days = %+v, want three days with 25 minutes on 2024-03-31", heatmap.Days)
+	}
+}
+
+func TestBuildTimeOfDay(t *testing.T) {
+	intervals := []Interval{
+		{at(22, 9, 30), at(22, 10, 30)}, // Monday
+		{at(23, 10, 0), at(23, 11, 0)},  // Tuesday
+		{at(27, 22, 0), at(27, 22, 25)}, // Saturday
+	}
+
+	result := BuildTimeOfDay(intervals, time.UTC, 2)
+
+	if result.Matrix[time.Monday][9] != 30 || result.Matrix[time.Monday][10] != 30 {
+		t.Errorf("Monday row = %v, want 30 minutes at 9 and 10", result.Matrix[time.Monday])
+	}
+	if result.Matrix[time.Tuesday][10] != 60 {
+		t.Errorf("Tuesday 10:00 = %v, want 60", result.Matrix[time.Tuesday][10])
+	}
+	if result.ByHour[10] != 90 {
+		t.Errorf("ByHour[10] = %v, want 90", result.ByHour[10])
+	}
+
+	if result.BestWindow == nil {
+		t.Fatalf("BestWindow = nil, want a window")
+	}
+	if result.BestWindow.StartHour != 9 || result.BestWindow.EndHour != 11 || result.BestWindow.FocusMinutes != 120 {
+		t.Errorf("BestWindow = %+v, want 9-11 with 120 minutes", result.BestWindow)
+	}
+	if math.Abs(result.BestWindow.Share-120.0/145.0) > 1e-9 {
+		t.Errorf("BestWindow share = %v, want %v", result.BestWindow.Share, 120.0/145.0)
+	}
+}
+
+func TestBuildTimeOfDayAcrossDaylightSavingTime(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		interval Interval
+		location *time.Location
+		expected map[int]float64
+	}{
+		// Clocks went back from 02:00 EDT to 01:00 EST on 2024-11-03, so
+		// the 1:00 hour happened twice.
+		{
+			"fall back",
+			Interval{time.Date(2024, 11, 3, 5, 10, 0, 0, time.UTC), time.Date(2024, 11, 3, 7, 30, 0, 0, time.UTC)},
+			newYork,
+			map[int]float64{1: 110, 2: 30},
+		},
+		// Clocks went forward from 02:00 EST to 03:00 EDT on 2024-03-10, so
+		// the 2:00 hour never happened.
+		{
+			"spring forward",
+			Interval{time.Date(2024, 3, 10, 6, 30, 0, 0, time.UTC), time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC)},
+			newYork,
+			map[int]float64{1: 30, 3: 30},
+		},
+		{
+			"half-hour offset",
+			Interval{time.Date(2024, 6, 3, 4, 0, 0, 0, time.UTC), time.Date(2024, 6, 3, 5, 0, 0, 0, time.UTC)},
+			kolkata,
+			map[int]float64{9: 30, 10: 30},
+		},
+	}
+
+	for _, test := range tests {
+		result := BuildTimeOfDay([]Interval{test.interval}, test.location, 1)
+		for hour, minutes := range result.ByHour {
+			if math.Abs(minutes-test.expected[hour]) > 1e-9 {
+				t.Errorf("%s: ByHour[%d] = %v, want %v", test.name, hour, minutes, test.expected[hour])
+			}
+		}
+	}
+}
+
+func TestBuildTimeOfDayWithoutSessions(t *testing.T) {
+	result := BuildTimeOfDay(nil, time.UTC, 2)
+	if result.BestWindow != nil {
+		t.Errorf("BestWindow = %+v, want nil", result.BestWindow)
+	}
+}
@@ -0,0 +1,304 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"gorm.io/gorm"
+)
+
+// SchemaVersion is the version of the archive layout. Archives of newer
+// versions are rejected on import, older ones are read as far as they go.
+const SchemaVersion = 1
+
+const (
+	manifestFile      = "manifest.json"
+	tasksFile         = "tasks.json"
+	subjectsFile      = "subjects.json"
+	sessionsFile      = "sessions.json"
+	interruptionsFile = "interruptions.json"
+	goalsFile         = "goals.json"
+	settingsFile      = "settings.json"
+	tasksCSVFile      = "tasks.csv"
+	sessionsCSVFile   = "sessions.csv"
+)
+
+// maxFileSize bounds each file read from an uploaded archive.
+const maxFileSize = 64 << 20
+
+// Manifest describes an archive. Counts holds the number of records in
+// each JSON file.
+type Manifest struct {
+	SchemaVersion int            `json:"schema_version"`
+	ExportedAt    time.Time      `json:"exported_at"`
+	UserID        string         `json:"user_id"`
+	Counts        map[string]int `json:"counts"`
+}
+
+// Data is everything an account holds. The JSON files of an archive are
+// the source of truth; the CSV files are for spreadsheets.
+type Data struct {
+	Tasks         []model.Task            `json:"tasks"`
+	Subjects      []model.Subject         `json:"subjects"`
+	Sessions      []model.PomodoroSession `json:"sessions"`
+	Interruptions []model.Interruption    `json:"interruptions"`
+	Goals         []model.Goal            `json:"goals"`
+	Settings      *model.UserSettings     `json:"settings"`
+}
+
+// Load reads the data of a user.
+func Load(db *gorm.DB, userID string) (Data, error) {
+	data := Data{}
+
+	for _, load := range []struct {
+		dest  interface{}
+		order string
+	}{
+		{&data.Tasks, "id"},
+		{&data.Subjects, "id"},
+		{&data.Sessions, "start_time"},
+		{&data.Interruptions, "occurred_at"},
+		{&data.Goals, "id"},
+	} {
+		if err := db.Where("user_id = ?", userID).Order(load.order).Find(load.dest).Error; err != nil {
+			return Data{}, err
+		}
+	}
+
+	var settings model.UserSettings
+	err := db.Where("user_id = ?", userID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Data{}, err
+	}
+	if err == nil {
+		data.Settings = &settings
+	}
+
+	return data, nil
+}
+
+// Write writes the data as a ZIP archive.
+func Write(w io.Writer, userID string, data Data, now time.Time) error {
+	archive := zip.NewWriter(w)
+
+	manifest := Manifest{
+		SchemaVersion: SchemaVersion,
+		ExportedAt:    now.UTC(),
+		UserID:        userID,
+		Counts: map[string]int{
+			tasksFile:         len(data.Tasks),
+			subjectsFile:      len(data.Subjects),
+			sessionsFile:      len(data.Sessions),
+			interruptionsFile: len(data.Interruptions),
+			goalsFile:         len(data.Goals),
+		},
+	}
+
+	for _, file := range []struct {
+		name  string
+		value interface{}
+	}{
+		{manifestFile, manifest},
+		{tasksFile, data.Tasks},
+		{subjectsFile, data.Subjects},
+		{sessionsFile, data.Sessions},
+		{interruptionsFile, data.Interruptions},
+		{goalsFile, data.Goals},
+		{settingsFile, data.Settings},
+	} {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.value); err != nil {
+			return err
+		}
+	}
+
+	if err := writeCSV(archive, tasksCSVFile, tasksTable(data.Tasks)); err != nil {
+		return err
+	}
+	if err := writeCSV(archive, sessionsCSVFile, sessionsTable(data.Sessions)); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// Read reads an archive written by Write.
+func Read(r io.ReaderAt, size int64) (Data, Manifest, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return Data{}, Manifest{}, err
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	read := func(name string, dest interface{}) error {
+		f, ok := files[name]
+		if !ok {
+			return fmt.Errorf("%s is missing", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		body, err := io.ReadAll(io.LimitReader(rc, maxFileSize+1))
+		if err != nil {
+			return err
+		}
+		if len(body) > maxFileSize {
+			return fmt.Errorf("%s is too large", name)
+		}
+		if err := json.Unmarshal(body, dest); err != nil {
+			return fmt.Errorf("%s is invalid: %w", name, err)
+		}
+		return nil
+	}
+
+	var manifest Manifest
+	if err := read(manifestFile, &manifest); err != nil {
+		return Data{}, Manifest{}, err
+	}
+	if manifest.SchemaVersion < 1 || manifest.SchemaVersion > SchemaVersion {
+		return Data{}, Manifest{}, fmt.Errorf("schema version %d is not supported", manifest.SchemaVersion)
+	}
+
+	data := Data{}
+	for _, file := range []struct {
+		name string
+		dest interface{}
+	}{
+		{tasksFile, &data.Tasks},
+		{subjectsFile, &data.Subjects},
+		{sessionsFile, &data.Sessions},
+		{interruptionsFile, &data.Interruptions},
+		{goalsFile, &data.Goals},
+		{settingsFile, &data.Settings},
+	} {
+		if err := read(file.name, file.dest); err != nil {
+			return Data{}, Manifest{}, err
+		}
+	}
+
+	return data, manifest, nil
+}
+
+// Bytes writes the data as a ZIP archive in memory.
+func Bytes(userID string, data Data, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Write(&buf, userID, data, now); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ErrNoSigningSecret is returned when download links would be signed with an
+// empty secret, which anyone could forge signatures for.
+var ErrNoSigningSecret = errors.New("EXPORT_SIGNING_SECRET is not set")
+
+// SigningSecret returns the secret download links are signed with.
+func SigningSecret() string {
+	return os.Getenv("EXPORT_SIGNING_SECRET")
+}
+
+// SignDownload returns the signature of a download link of an export that
+// is valid until expires.
+func SignDownload(secret string, jobID uint, expires time.Time) (string, error) {
+	if secret == "" {
+		return "", ErrNoSigningSecret
+	}
+	return signDownload(secret, jobID, expires), nil
+}
+
+// VerifyDownload reports whether a download link is authentic and has not
+// expired. Without a secret no link is.
+func VerifyDownload(secret string, jobID uint, expires int64, signature string, now time.Time) bool {
+	if secret == "" || now.Unix() > expires {
+		return false
+	}
+	expected := signDownload(secret, jobID, time.Unix(expires, 0))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func signDownload(secret string, jobID uint, expires time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "export-download.%d.%d", jobID, expires.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func writeCSV(archive *zip.Writer, name string, records [][]string) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(records); err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+func tasksTable(tasks []model.Task) [][]string {
+	records := [][]string{{"id", "title", "description", "due_date", "is_complete", "completed_at", "subject_id", "created_at"}}
+	for _, task := range tasks {
+		records = append(records, []string{
+			strconv.FormatUint(uint64(task.ID), 10),
+			task.Title,
+			task.Description,
+			formatTime(&task.DueDate),
+			strconv.FormatBool(task.IsComplete),
+			formatTime(task.CompletedAt),
+			formatID(task.SubjectID),
+			formatTime(&task.CreatedAt),
+		})
+	}
+	return records
+}
+
+func sessionsTable(sessions []model.PomodoroSession) [][]string {
+	records := [][]string{{"id", "start_time", "end_time", "is_manual", "subject_id"}}
+	for _, session := range sessions {
+		records = append(records, []string{
+			strconv.FormatUint(uint64(session.ID), 10),
+			formatTime(&session.StartTime),
+			formatTime(session.EndTime),
+			strconv.FormatBool(session.IsManual),
+			formatID(session.SubjectID),
+		})
+	}
+	return records
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
@@ -0,0 +1,128 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+)
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	subjectID := uint(4)
+	completedAt := time.Date(2024, 7, 1, 7, 0, 0, 0, time.UTC)
+	endTime := time.Date(2024, 6, 30, 10, 25, 0, 0, time.UTC)
+
+	data := Data{
+		Tasks: []model.Task{
+			{ID: 1, Title: "Essay, draft", DueDate: time.Date(2024, 7, 2, 10, 0, 0, 0, time.UTC), SubjectID: &subjectID},
+			{ID: 2, Title: "Quiz", DueDate: completedAt, IsComplete: true, CompletedAt: &completedAt},
+		},
+		Subjects:      []model.Subject{{ID: 4, Name: "Calculus", Color: "#1e90ff"}},
+		Sessions:      []model.PomodoroSession{{ID: 9, StartTime: time.Date(2024, 6, 30, 10, 0, 0, 0, time.UTC), EndTime: &endTime, SubjectID: &subjectID}},
+		Interruptions: []model.Interruption{{ID: 3, SessionID: 9, OccurredAt: time.Date(2024, 6, 30, 10, 5, 0, 0, time.UTC), Kind: model.InterruptionExternal}},
+		Goals:         []model.Goal{{ID: 2, Kind: model.GoalFocusMinutes, Period: model.GoalDaily, Target: 120}},
+		Settings:      &model.UserSettings{UserID: "someone@example.com", TimeZone: "Asia/Jakarta"},
+	}
+
+	body, err := Bytes("someone@example.com", data, now)
+	if err != nil {
+		t.Fatalf("Bytes() error = %v", err)
+	}
+
+	read, manifest, err := Read(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	if manifest.SchemaVersion != SchemaVersion || !manifest.ExportedAt.Equal(now) || manifest.Counts[tasksFile] != 2 {
+		t.Errorf("manifest = %+v, want version %d with 2 tasks", manifest, SchemaVersion)
+	}
+	if len(read.Tasks) != 2 || read.Tasks[0].Title != "Essay, draft" || *read.Tasks[0].SubjectID != 4 || !read.Tasks[1].CompletedAt.Equal(completedAt) {
+		t.Errorf("tasks = %+v, want %+v", read.Tasks, data.Tasks)
+	}
+	if len(read.Sessions) != 1 || !read.Sessions[0].EndTime.Equal(endTime) || len(read.Interruptions) != 1 || read.Interruptions[0].SessionID != 9 {
+		t.Errorf("sessions = %+v and interruptions = %+v, want those written", read.Sessions, read.Interruptions)
+	}
+	if len(read.Subjects) != 1 || len(read.Goals) != 1 || read.Settings == nil || read.Settings.TimeZone != "Asia/Jakarta" {
+		t.Errorf("subjects = %+v, goals = %+v and settings = %+v, want those written", read.Subjects, read.Goals, read.Settings)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range archive.File {
+		if f.Name != tasksCSVFile {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var csv bytes.Buffer
+		if _, err := csv.ReadFrom(rc); err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+
+		expected := "id,title,description,due_date,is_complete,completed_at,subject_id,created_at\n" +
+			"1,\"Essay, draft\",,2024-07-02T10:00:00Z,false,,4,\n"
+		if !strings.HasPrefix(csv.String(), expected) {
+			t.Errorf("tasks.csv = %q, want it to start with %q", csv.String(), expected)
+		}
+	}
+}
+
+func TestReadRejectsNewerSchema(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(`{"schema_version": 99}`))
+	w.Close()
+
+	if _, _, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err == nil {
+		t.Error("Read() of a newer schema version succeeded, want an error")
+	}
+}
+
+func TestVerifyDownload(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	expires := now.Add(15 * time.Minute)
+	signature, err := SignDownload("secret", 7, expires)
+	if err != nil {
+		t.Fatalf("Failed to sign download: %v", err)
+	}
+	if _, err := SignDownload("", 7, expires); err == nil {
+		t.Error("Expected signing with an empty secret to fail")
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		jobID     uint
+		expires   int64
+		signature string
+		now       time.Time
+		expected  bool
+	}{
+		{"valid", "secret", 7, expires.Unix(), signature, now, true},
+		{"expired", "secret", 7, expires.Unix(), signature, expires.Add(time.Second), false},
+		{"other job", "secret", 8, expires.Unix(), signature, now, false},
+		{"extended expiry", "secret", 7, expires.Add(time.Hour).Unix(), signature, now, false},
+		{"other secret", "other", 7, expires.Unix(), signature, now, false},
+		{"empty secret", "", 7, expires.Unix(), signDownload("", 7, expires), now, false},
+	}
+
+	for _, test := range tests {
+		if result := VerifyDownload(test.secret, test.jobID, test.expires, test.signature, test.now); result != test.expected {
+			t.Errorf("%s: VerifyDownload() = %v, want %v", test.name, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Exporter generates the archives of pending exports and discards them
+// once they expire. Jobs are claimed with SKIP LOCKED, and a job whose
+// exporter died is claimed again once it goes stale.
+type Exporter struct {
+	DB         *gorm.DB
+	Interval   time.Duration
+	Retention  time.Duration
+	StaleAfter time.Duration
+}
+
+func NewExporter(db *gorm.DB) *Exporter {
+	return &Exporter{
+		DB:         db,
+		Interval:   5 * time.Second,
+		Retention:  24 * time.Hour,
+		StaleAfter: 10 * time.Minute,
+	}
+}
+
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if job, err := e.RunOnce(now); err != nil {
+				slog.Error("Failed to run export", slog.String("error", err.Error()))
+			} else if job != nil {
+				slog.Info("Finished export", slog.Uint64("job_id", uint64(job.ID)), slog.String("status", job.Status))
+			}
+			if err := e.Expire(now); err != nil {
+				slog.Error("Failed to expire exports", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// RunOnce generates the archive of the next pending export and returns the
+// export, or nil if there was none.
+func (e *Exporter) RunOnce(now time.Time) (*model.ExportJob, error) {
+	job, err := e.claim(now)
+	if err != nil || job == nil {
+		return nil, err
+	}
+
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	job.UpdatedAt = finishedAt
+
+	data, err := Load(e.DB, job.UserID)
+	var archive []byte
+	if err == nil {
+		archive, err = Bytes(job.UserID, data, finishedAt)
+	}
+
+	updates := map[string]interface{}{"finished_at": finishedAt, "updated_at": finishedAt}
+	if err != nil {
+		job.Status = model.ExportFailed
+		job.LastError = err.Error()
+		updates["last_error"] = job.LastError
+	} else {
+		expiresAt := finishedAt.Add(e.Retention)
+		job.Status = model.ExportCompleted
+		job.Size = int64(len(archive))
+		job.ExpiresAt = &expiresAt
+		updates["archive"] = archive
+		updates["size"] = job.Size
+		updates["expires_at"] = expiresAt
+	}
+	updates["status"] = job.Status
+
+	if err := e.DB.Model(job).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Expire discards the archives of exports past their expiry.
+func (e *Exporter) Expire(now time.Time) error {
+	return e.DB.Model(&model.ExportJob{}).
+		Where("status = ? AND expires_at <= ?", model.ExportCompleted, now.UTC()).
+		Updates(map[string]interface{}{"status": model.ExportExpired, "archive": nil, "updated_at": now.UTC()}).Error
+}
+
+func (e *Exporter) claim(now time.Time) (*model.ExportJob, error) {
+	var job model.ExportJob
+	err := e.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Omit("archive").
+			Where("status = ? OR (status = ? AND updated_at < ?)", model.ExportPending, model.ExportRunning, now.Add(-e.StaleAfter).UTC()).
+			Order("id").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Status = model.ExportRunning
+		job.UpdatedAt = now.UTC()
+		return tx.Model(&job).Updates(map[string]interface{}{"status": job.Status, "updated_at": job.UpdatedAt}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,237 @@
+package archive
+
+import (
+	"strings"
+
+	"github.com/abyan-dev/productivity/pkg/importer"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/outbox"
+	"gorm.io/gorm"
+)
+
+// Counts is how many records a restore created, and how many it skipped
+// because the account already had them.
+type Counts struct {
+	Subjects      int `json:"subjects"`
+	Tasks         int `json:"tasks"`
+	Sessions      int `json:"sessions"`
+	Interruptions int `json:"interruptions"`
+	Goals         int `json:"goals"`
+	Settings      int `json:"settings"`
+	Skipped       int `json:"skipped"`
+}
+
+// Restore adds the data of an archive to the user's account. Records get
+// new IDs, and records the account already has are skipped: subjects with
+// the same name, tasks with the same dedup key as in imports, sessions that
+// overlap an existing one and identical goals. Existing settings are kept.
+// Sessions that were still running when the archive was written are left
+// out.
+func Restore(tx *gorm.DB, userID string, data Data) (Counts, error) {
+	counts := Counts{}
+
+	subjectIDs, err := restoreSubjects(tx, userID, data.Subjects, &counts)
+	if err != nil {
+		return Counts{}, err
+	}
+	if err := restoreTasks(tx, userID, data.Tasks, subjectIDs, &counts); err != nil {
+		return Counts{}, err
+	}
+	sessionIDs, err := restoreSessions(tx, userID, data.Sessions, subjectIDs, &counts)
+	if err != nil {
+		return Counts{}, err
+	}
+
+	for _, interruption := range data.Interruptions {
+		sessionID, ok := sessionIDs[interruption.SessionID]
+		if !ok {
+			counts.Skipped++
+			continue
+		}
+		interruption.ID = 0
+		interruption.SessionID = sessionID
+		interruption.UserID = userID
+		if err := tx.Create(&interruption).Error; err != nil {
+			return Counts{}, err
+		}
+		counts.Interruptions++
+	}
+
+	if err := restoreGoals(tx, userID, data.Goals, &counts); err != nil {
+		return Counts{}, err
+	}
+
+	if data.Settings != nil {
+		var existing int64
+		if err := tx.Model(&model.UserSettings{}).Where("user_id = ?", userID).Count(&existing).Error; err != nil {
+			return Counts{}, err
+		}
+		if existing == 0 {
+			settings := *data.Settings
+			settings.UserID = userID
+			settings.LastWeeklyReportAt = nil
+			if err := tx.Create(&settings).Error; err != nil {
+				return Counts{}, err
+			}
+			counts.Settings++
+		} else {
+			counts.Skipped++
+		}
+	}
+
+	return counts, nil
+}
+
+func restoreSubjects(tx *gorm.DB, userID string, subjects []model.Subject, counts *Counts) (map[uint]uint, error) {
+	var existing []model.Subject
+	if err := tx.Where("user_id = ?", userID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	byName := map[string]uint{}
+	for _, subject := range existing {
+		byName[strings.ToLower(subject.Name)] = subject.ID
+	}
+
+	ids := map[uint]uint{}
+	for _, subject := range subjects {
+		if id, ok := byName[strings.ToLower(subject.Name)]; ok {
+			ids[subject.ID] = id
+			counts.Skipped++
+			continue
+		}
+
+		oldID := subject.ID
+		subject.ID = 0
+		subject.UserID = userID
+		if err := tx.Create(&subject).Error; err != nil {
+			return nil, err
+		}
+		ids[oldID] = subject.ID
+		byName[strings.ToLower(subject.Name)] = subject.ID
+		counts.Subjects++
+	}
+	return ids, nil
+}
+
+func restoreTasks(tx *gorm.DB, userID string, tasks []model.Task, subjectIDs map[uint]uint, counts *Counts) error {
+	existing, err := importer.ExistingKeys(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	var davNames []string
+	if err := tx.Model(&model.Task{}).Where("user_id = ? AND dav_name <> ''", userID).Pluck("dav_name", &davNames).Error; err != nil {
+		return err
+	}
+	takenNames := map[string]bool{}
+	for _, name := range davNames {
+		takenNames[name] = true
+	}
+
+	for _, task := range tasks {
+		key := importer.DedupKey(task.Title, task.DueDate)
+		if existing[key] {
+			counts.Skipped++
+			continue
+		}
+		existing[key] = true
+
+		task.ID = 0
+		task.UserID = userID
+		task.SubjectID = remap(task.SubjectID, subjectIDs)
+		task.ImportJobID = nil
+		if takenNames[task.DAVName] {
+			task.DAVName = ""
+		}
+		if task.DAVName != "" {
+			takenNames[task.DAVName] = true
+		}
+
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		if err := outbox.Record(tx, userID, outbox.EventTaskCreated, task); err != nil {
+			return err
+		}
+		counts.Tasks++
+	}
+	return nil
+}
+
+func restoreSessions(tx *gorm.DB, userID string, sessions []model.PomodoroSession, subjectIDs map[uint]uint, counts *Counts) (map[uint]uint, error) {
+	ids := map[uint]uint{}
+	for _, session := range sessions {
+		if session.EndTime == nil {
+			counts.Skipped++
+			continue
+		}
+
+		var overlapping int64
+		err := tx.Model(&model.PomodoroSession{}).
+			Where("user_id = ? AND start_time < ? AND (end_time IS NULL OR end_time > ?)", userID, session.EndTime.UTC(), session.StartTime.UTC()).
+			Count(&overlapping).Error
+		if err != nil {
+			return nil, err
+		}
+		if overlapping > 0 {
+			counts.Skipped++
+			continue
+		}
+
+		oldID := session.ID
+		session.ID = 0
+		session.UserID = userID
+		session.SubjectID = remap(session.SubjectID, subjectIDs)
+		if err := tx.Create(&session).Error; err != nil {
+			return nil, err
+		}
+		if err := outbox.Record(tx, userID, outbox.EventSessionCreated, session); err != nil {
+			return nil, err
+		}
+		ids[oldID] = session.ID
+		counts.Sessions++
+	}
+	return ids, nil
+}
+
+func restoreGoals(tx *gorm.DB, userID string, goals []model.Goal, counts *Counts) error {
+	var existing []model.Goal
+	if err := tx.Where("user_id = ?", userID).Find(&existing).Error; err != nil {
+		return err
+	}
+
+	for _, goal := range goals {
+		duplicate := false
+		for _, other := range existing {
+			if other.Kind == goal.Kind && other.Period == goal.Period && other.Target == goal.Target && other.RestDays == goal.RestDays {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate || !model.IsValidGoalKind(goal.Kind) || !model.IsValidGoalPeriod(goal.Period) {
+			counts.Skipped++
+			continue
+		}
+
+		goal.ID = 0
+		goal.UserID = userID
+		goal.MetNotifiedFor = nil
+		if err := tx.Create(&goal).Error; err != nil {
+			return err
+		}
+		existing = append(existing, goal)
+		counts.Goals++
+	}
+	return nil
+}
+
+func remap(id *uint, ids map[uint]uint) *uint {
+	if id == nil {
+		return nil
+	}
+	mapped, ok := ids[*id]
+	if !ok {
+		return nil
+	}
+	return &mapped
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Where RequireAuthenticated looks for the access token first, when a
+// request carries both an Authorization header and a cookie.
+const (
+	PrecedenceHeader = "header"
+	PrecedenceCookie = "cookie"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+)
+
+type Config struct {
+	// Algorithm is the one algorithm tokens may be signed with.
+	Algorithm string
+	// Secret verifies HS256 tokens.
+	Secret string
+	// JWKSURL or JWKSFile provide the public keys for RS256 and ES256
+	// tokens. The file holds a JWKS or one or more PEM public keys.
+	JWKSURL  string
+	JWKSFile string
+	// RefreshInterval is how often the keys are fetched again from the
+	// URL. Keys with an unknown kid trigger a fetch too, at most once per
+	// RefreshRateLimit, from either the URL or the file.
+	RefreshInterval  time.Duration
+	RefreshRateLimit time.Duration
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// TokenPrecedence is PrecedenceHeader or PrecedenceCookie.
+	TokenPrecedence string
+	// AllowedOrigins are the origins browsers may make credentialed
+	// requests from, for CORS and the CSRF origin check.
+	AllowedOrigins []string
+}
+
+func LoadConfig() (*Config, error) {
+	config := &Config{
+		Algorithm:        strings.ToUpper(os.Getenv("JWT_ALGORITHM")),
+		Secret:           os.Getenv("JWT_SECRET"),
+		JWKSURL:          os.Getenv("JWT_JWKS_URL"),
+		JWKSFile:         os.Getenv("JWT_JWKS_FILE"),
+		RefreshInterval:  time.Hour,
+		RefreshRateLimit: 5 * time.Minute,
+		Issuer:           os.Getenv("JWT_ISSUER"),
+		Audience:         os.Getenv("JWT_AUDIENCE"),
+		TokenPrecedence:  strings.ToLower(os.Getenv("JWT_TOKEN_PRECEDENCE")),
+		AllowedOrigins:   []string{"http://localhost:3000"},
+	}
+
+	if config.Algorithm == "" {
+		config.Algorithm = AlgorithmHS256
+	}
+	switch config.TokenPrecedence {
+	case "":
+		config.TokenPrecedence = PrecedenceHeader
+	case PrecedenceHeader, PrecedenceCookie:
+	default:
+		return nil, fmt.Errorf("invalid JWT_TOKEN_PRECEDENCE %q", config.TokenPrecedence)
+	}
+	if originsStr := os.Getenv("ALLOWED_ORIGINS"); originsStr != "" {
+		config.AllowedOrigins = nil
+		for _, origin := range strings.Split(originsStr, ",") {
+			if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+				config.AllowedOrigins = append(config.AllowedOrigins, origin)
+			}
+		}
+	}
+	if intervalStr := os.Getenv("JWT_JWKS_REFRESH_INTERVAL"); intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_JWKS_REFRESH_INTERVAL: %w", err)
+		}
+		config.RefreshInterval = interval
+	}
+
+	return config, nil
+}
+
+// Verifier verifies the tokens issued by the auth service.
+type Verifier struct {
+	algorithm string
+	keyfunc   jwt.Keyfunc
+	options   []jwt.ParserOption
+}
+
+// NewVerifier creates a verifier for the configured algorithm. Keys are
+// fetched from a JWKS URL right away, but a failure to do so is only
+// logged, so that the service can start while the auth service is down.
+func NewVerifier(config *Config) (*Verifier, error) {
+	verifier := &Verifier{
+		algorithm: config.Algorithm,
+		options:   []jwt.ParserOption{jwt.WithValidMethods([]string{config.Algorithm})},
+	}
+	if config.Issuer != "" {
+		verifier.options = append(verifier.options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		verifier.options = append(verifier.options, jwt.WithAudience(config.Audience))
+	}
+
+	switch config.Algorithm {
+	case AlgorithmHS256:
+		if config.Secret == "" {
+			return nil, errors.New("JWT_SECRET must be set for HS256")
+		}
+		secret := []byte(config.Secret)
+		verifier.keyfunc = func(*jwt.Token) (interface{}, error) { return secret, nil }
+
+	case AlgorithmRS256, AlgorithmES256:
+		switch {
+		case config.JWKSURL != "":
+			jwks, err := keyfunc.Get(config.JWKSURL, keyfunc.Options{
+				RefreshInterval:   config.RefreshInterval,
+				RefreshRateLimit:  config.RefreshRateLimit,
+				RefreshTimeout:    10 * time.Second,
+				RefreshUnknownKID: true,
+				RefreshErrorHandler: func(err error) {
+					slog.Error("Failed to refresh JWKS", slog.String("url", config.JWKSURL), slog.String("error", err.Error()))
+				},
+				TolerateInitialJWKHTTPError: true,
+			})
+			if err != nil {
+				return nil, err
+			}
+			verifier.keyfunc = singleKeyFallback(jwks.Keyfunc, jwks.ReadOnlyKeys)
+
+		case config.JWKSFile != "":
+			keys, err := newFileKeys(config.JWKSFile, config.Algorithm, config.RefreshRateLimit)
+			if err != nil {
+				return nil, err
+			}
+			verifier.keyfunc = singleKeyFallback(keys.Keyfunc, keys.ReadOnlyKeys)
+
+		default:
+			return nil, fmt.Errorf("JWT_JWKS_URL or JWT_JWKS_FILE must be set for %s", config.Algorithm)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", config.Algorithm)
+	}
+
+	return verifier, nil
+}
+
+// Symmetric reports whether tokens are signed with the shared secret, in
+// which case this service can issue them too.
+func (v *Verifier) Symmetric() bool {
+	return v.algorithm == AlgorithmHS256
+}
+
+// Keyfunc returns the key to verify the token with, refusing tokens signed
+// with any other algorithm. It does not check the claims; Parse does.
+func (v *Verifier) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != v.algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return v.keyfunc(token)
+}
+
+// Parse verifies the token and its claims and returns the claims.
+func (v *Verifier) Parse(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, v.Keyfunc, v.options...)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// singleKeyFallback lets tokens without a kid through to the only key
+// there is, as issuers with a single key often leave it out.
+func singleKeyFallback(next jwt.Keyfunc, keys func() map[string]interface{}) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Header["kid"]; !ok {
+			all := keys()
+			if len(all) == 1 {
+				for _, key := range all {
+					return key, nil
+				}
+			}
+		}
+		return next(token)
+	}
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"email": "test@example.com",
+		"iss":   "https://auth.example.com",
+		"aud":   "productivity",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func pemKey(t *testing.T, key interface{}, kid string) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	block := &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	if kid != "" {
+		block.Headers = map[string]string{"kid": kid}
+	}
+	return pem.EncodeToMemory(block)
+}
+
+func rsaJWKS(key *rsa.PublicKey, kid string) []byte {
+	encoded, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	return encoded
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func TestVerifierHS256(t *testing.T) {
+	verifier, err := NewVerifier(&Config{Algorithm: AlgorithmHS256, Secret: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", claims())); err != nil {
+		t.Errorf("Expected token signed with the secret to verify, got %v", err)
+	}
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodHS256, []byte("other"), "", claims())); err == nil {
+		t.Error("Expected token signed with another secret to fail")
+	}
+
+	if _, err := NewVerifier(&Config{Algorithm: AlgorithmHS256}); err == nil {
+		t.Error("Expected HS256 without a secret to fail")
+	}
+}
+
+func TestVerifierPEMFile(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "keys.pem")
+	writeFile(t, path, pemKey(t, &rsaKey.PublicKey, ""))
+
+	verifier, err := NewVerifier(&Config{
+		Algorithm: AlgorithmRS256,
+		JWKSFile:  path,
+		Issuer:    "https://auth.example.com",
+		Audience:  "productivity",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodRS256, rsaKey, "", claims())); err != nil {
+		t.Errorf("Expected token without kid to verify against the only key, got %v", err)
+	}
+
+	wrongIssuer := claims()
+	wrongIssuer["iss"] = "https://evil.example.com"
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodRS256, rsaKey, "", wrongIssuer)); err == nil {
+		t.Error("Expected token from another issuer to fail")
+	}
+
+	wrongAudience := claims()
+	wrongAudience["aud"] = "billing"
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodRS256, rsaKey, "", wrongAudience)); err == nil {
+		t.Error("Expected token for another audience to fail")
+	}
+
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", claims())); err == nil {
+		t.Error("Expected HS256 token to fail")
+	}
+}
+
+func TestVerifierFileRotation(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	path := filepath.Join(t.TempDir(), "keys.pem")
+	writeFile(t, path, pemKey(t, &oldKey.PublicKey, "old"))
+
+	verifier, err := NewVerifier(&Config{Algorithm: AlgorithmES256, JWKSFile: path})
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodES256, oldKey, "old", claims())); err != nil {
+		t.Errorf("Expected token signed with the old key to verify, got %v", err)
+	}
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodES256, newKey, "new", claims())); err == nil {
+		t.Error("Expected token signed with an unknown key to fail")
+	}
+
+	rotated := append(pemKey(t, &oldKey.PublicKey, "old"), pemKey(t, &newKey.PublicKey, "new")...)
+	writeFile(t, path, rotated)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodES256, newKey, "new", claims())); err != nil {
+		t.Errorf("Expected token signed with the new key to verify after rotation, got %v", err)
+	}
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodES256, oldKey, "old", claims())); err != nil {
+		t.Errorf("Expected token signed with the old key to still verify, got %v", err)
+	}
+}
+
+func TestVerifierJWKSURL(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var current atomic.Value
+	current.Store(rsaJWKS(&oldKey.PublicKey, "old"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(current.Load().([]byte))
+	}))
+	defer server.Close()
+
+	verifier, err := NewVerifier(&Config{
+		Algorithm:        AlgorithmRS256,
+		JWKSURL:          server.URL,
+		RefreshInterval:  time.Hour,
+		RefreshRateLimit: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodRS256, oldKey, "old", claims())); err != nil {
+		t.Errorf("Expected token signed with the published key to verify, got %v", err)
+	}
+
+	current.Store(rsaJWKS(&newKey.PublicKey, "new"))
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := verifier.Parse(sign(t, jwt.SigningMethodRS256, newKey, "new", claims())); err != nil {
+		t.Errorf("Expected token signed with a newly published key to verify, got %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	jwks, err := ParseKeys(rsaJWKS(&rsaKey.PublicKey, "a"), AlgorithmRS256)
+	if err != nil || len(jwks.KIDs()) != 1 || jwks.KIDs()[0] != "a" {
+		t.Errorf("Expected JWKS with kid a, got %v, %v", jwks, err)
+	}
+
+	jwks, err = ParseKeys(pemKey(t, &rsaKey.PublicKey, "b"), AlgorithmRS256)
+	if err != nil || len(jwks.KIDs()) != 1 || jwks.KIDs()[0] != "b" {
+		t.Errorf("Expected PEM key with kid b, got %v, %v", jwks, err)
+	}
+
+	if _, err := ParseKeys([]byte("not a key"), AlgorithmRS256); err == nil {
+		t.Error("Expected garbage to fail")
+	}
+}
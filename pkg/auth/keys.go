@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fileKeys are public keys read from a file, which is read again when a
+// token names a kid it does not have, so that keys can be rotated by
+// replacing the file.
+type fileKeys struct {
+	path      string
+	algorithm string
+	rateLimit time.Duration
+
+	mu        sync.Mutex
+	jwks      *keyfunc.JWKS
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newFileKeys(path string, algorithm string, rateLimit time.Duration) (*fileKeys, error) {
+	keys := &fileKeys{path: path, algorithm: algorithm, rateLimit: rateLimit}
+	if err := keys.load(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (k *fileKeys) Keyfunc(token *jwt.Token) (interface{}, error) {
+	k.mu.Lock()
+	jwks := k.jwks
+	k.mu.Unlock()
+
+	key, err := jwks.Keyfunc(token)
+	if !errors.Is(err, keyfunc.ErrKIDNotFound) {
+		return key, err
+	}
+
+	if !k.reload() {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	jwks = k.jwks
+	k.mu.Unlock()
+	return jwks.Keyfunc(token)
+}
+
+func (k *fileKeys) ReadOnlyKeys() map[string]interface{} {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.jwks.ReadOnlyKeys()
+}
+
+// reload reads the file again if it changed and was not checked within the
+// rate limit, and reports whether it did. When the new file cannot be read,
+// the old keys are kept.
+func (k *fileKeys) reload() bool {
+	k.mu.Lock()
+	if time.Since(k.checkedAt) < k.rateLimit {
+		k.mu.Unlock()
+		return false
+	}
+	k.checkedAt = time.Now()
+	modTime := k.modTime
+	k.mu.Unlock()
+
+	info, err := os.Stat(k.path)
+	if err != nil || info.ModTime().Equal(modTime) {
+		return false
+	}
+	if err := k.load(); err != nil {
+		slog.Error("Failed to reload JWKS file", slog.String("path", k.path), slog.String("error", err.Error()))
+		return false
+	}
+	return true
+}
+
+func (k *fileKeys) load() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+
+	jwks, err := ParseKeys(data, k.algorithm)
+	if err != nil {
+		return fmt.Errorf("%s: %w", k.path, err)
+	}
+
+	k.mu.Lock()
+	k.jwks = jwks
+	k.modTime = info.ModTime()
+	k.mu.Unlock()
+	return nil
+}
+
+// ParseKeys parses a JWKS, or one or more PEM public keys. PEM keys take
+// their kid from a "kid" header in the PEM block if there is one, and from
+// a hash of the key otherwise.
+func ParseKeys(data []byte, algorithm string) (*keyfunc.JWKS, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return keyfunc.NewJSON(trimmed)
+	}
+
+	given := map[string]keyfunc.GivenKey{}
+	options := keyfunc.GivenKeyOptions{Algorithm: algorithm}
+	for {
+		var block *pem.Block
+		block, trimmed = pem.Decode(trimmed)
+		if block == nil {
+			break
+		}
+
+		key, err := parsePublicKey(block)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := block.Headers["kid"]
+		if kid == "" {
+			sum := sha256.Sum256(block.Bytes)
+			kid = base64.RawURLEncoding.EncodeToString(sum[:])
+		}
+
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			given[kid] = keyfunc.NewGivenRSA(key, options)
+		case *ecdsa.PublicKey:
+			given[kid] = keyfunc.NewGivenECDSA(key, options)
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+	}
+
+	if len(given) == 0 {
+		return nil, errors.New("no public keys found")
+	}
+	return keyfunc.NewGiven(given), nil
+}
+
+func parsePublicKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return certificate.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
@@ -0,0 +1,250 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	NamespaceDAV            = "DAV:"
+	NamespaceCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+)
+
+var (
+	PropResourceType               = xml.Name{Space: NamespaceDAV, Local: "resourcetype"}
+	PropDisplayName                = xml.Name{Space: NamespaceDAV, Local: "displayname"}
+	PropCurrentUserPrincipal       = xml.Name{Space: NamespaceDAV, Local: "current-user-principal"}
+	PropPrincipalURL               = xml.Name{Space: NamespaceDAV, Local: "principal-URL"}
+	PropOwner                      = xml.Name{Space: NamespaceDAV, Local: "owner"}
+	PropCurrentUserPrivilegeSet    = xml.Name{Space: NamespaceDAV, Local: "current-user-privilege-set"}
+	PropSupportedReportSet         = xml.Name{Space: NamespaceDAV, Local: "supported-report-set"}
+	PropSyncToken                  = xml.Name{Space: NamespaceDAV, Local: "sync-token"}
+	PropGetETag                    = xml.Name{Space: NamespaceDAV, Local: "getetag"}
+	PropGetContentType             = xml.Name{Space: NamespaceDAV, Local: "getcontenttype"}
+	PropGetLastModified            = xml.Name{Space: NamespaceDAV, Local: "getlastmodified"}
+	PropCalendarHomeSet            = xml.Name{Space: NamespaceCalDAV, Local: "calendar-home-set"}
+	PropSupportedCalendarComponent = xml.Name{Space: NamespaceCalDAV, Local: "supported-calendar-component-set"}
+	PropCalendarData               = xml.Name{Space: NamespaceCalDAV, Local: "calendar-data"}
+	PropCalendarUserAddressSet     = xml.Name{Space: NamespaceCalDAV, Local: "calendar-user-address-set"}
+	PropGetCTag                    = xml.Name{Space: NamespaceCalendarServer, Local: "getctag"}
+	ReportCalendarQuery            = xml.Name{Space: NamespaceCalDAV, Local: "calendar-query"}
+	ReportCalendarMultiget         = xml.Name{Space: NamespaceCalDAV, Local: "calendar-multiget"}
+	ReportSyncCollection           = xml.Name{Space: NamespaceDAV, Local: "sync-collection"}
+	PreconditionValidSyncToken     = xml.Name{Space: NamespaceDAV, Local: "valid-sync-token"}
+	PreconditionSupportedComponent = xml.Name{Space: NamespaceCalDAV, Local: "supported-calendar-component"}
+	PreconditionValidCalendarData  = xml.Name{Space: NamespaceCalDAV, Local: "valid-calendar-data"}
+	PreconditionNoUIDConflict      = xml.Name{Space: NamespaceCalDAV, Local: "no-uid-conflict"}
+	PreconditionSupportedReport    = xml.Name{Space: NamespaceDAV, Local: "supported-report"}
+)
+
+var prefixes = map[string]string{
+	NamespaceDAV:            "D",
+	NamespaceCalDAV:         "C",
+	NamespaceCalendarServer: "CS",
+}
+
+// Request is a parsed PROPFIND or REPORT body.
+type Request struct {
+	// Kind is the root element, such as a propfind or one of the reports.
+	Kind xml.Name
+	// Props are the requested properties. AllProp is set instead if the
+	// request did not name any, which includes an empty body.
+	Props   []xml.Name
+	AllProp bool
+	// Hrefs are the resources requested by a calendar-multiget report.
+	Hrefs []string
+	// SyncToken is the token sent with a sync-collection report.
+	SyncToken string
+}
+
+// ParseRequest parses a PROPFIND or REPORT request body.
+func ParseRequest(body []byte) (Request, error) {
+	req := Request{}
+	if len(bytes.TrimSpace(body)) == 0 {
+		req.Kind = xml.Name{Space: NamespaceDAV, Local: "propfind"}
+		req.AllProp = true
+		return req, nil
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var path []xml.Name
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Request{}, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			parent := xml.Name{}
+			if len(path) > 0 {
+				parent = path[len(path)-1]
+			}
+			path = append(path, t.Name)
+
+			switch {
+			case len(path) == 1:
+				req.Kind = t.Name
+			case parent == xml.Name{Space: NamespaceDAV, Local: "prop"} && len(path) == 3:
+				req.Props = append(req.Props, t.Name)
+			case t.Name == xml.Name{Space: NamespaceDAV, Local: "allprop"}:
+				req.AllProp = true
+			case t.Name == xml.Name{Space: NamespaceDAV, Local: "href"} && len(path) == 2:
+				var href string
+				if err := decoder.DecodeElement(&href, &t); err != nil {
+					return Request{}, err
+				}
+				req.Hrefs = append(req.Hrefs, strings.TrimSpace(href))
+				path = path[:len(path)-1]
+			case t.Name == PropSyncToken && len(path) == 2:
+				var syncToken string
+				if err := decoder.DecodeElement(&syncToken, &t); err != nil {
+					return Request{}, err
+				}
+				req.SyncToken = strings.TrimSpace(syncToken)
+				path = path[:len(path)-1]
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+
+	if len(req.Props) == 0 && req.Kind.Local == "propfind" {
+		req.AllProp = true
+	}
+
+	return req, nil
+}
+
+// Prop is a property value. Value is inner XML, which may refer to the D, C
+// and CS namespace prefixes.
+type Prop struct {
+	Name  xml.Name
+	Value string
+}
+
+// Text returns a property whose value is the given text.
+func Text(name xml.Name, text string) Prop {
+	return Prop{Name: name, Value: escape(text)}
+}
+
+// Href returns a property whose value is a single href.
+func Href(name xml.Name, href string) Prop {
+	return Prop{Name: name, Value: "<D:href>" + escape(href) + "</D:href>"}
+}
+
+// Response is the outcome for one resource in a multistatus response. If
+// Status is set, the resource as a whole has that status instead of props.
+type Response struct {
+	Href     string
+	Status   int
+	Props    []Prop
+	NotFound []xml.Name
+}
+
+// Select returns the props that were requested, and the names of the
+// requested props that are not available.
+func Select(req Request, available []Prop) ([]Prop, []xml.Name) {
+	if req.AllProp {
+		return available, nil
+	}
+
+	found := []Prop{}
+	missing := []xml.Name{}
+	for _, name := range req.Props {
+		ok := false
+		for _, prop := range available {
+			if prop.Name == name {
+				found = append(found, prop)
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			missing = append(missing, name)
+		}
+	}
+	return found, missing
+}
+
+// Multistatus renders a 207 Multi-Status body. A non-empty syncToken is
+// included, as required for sync-collection reports.
+func Multistatus(responses []Response, syncToken string) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:CS="http://calendarserver.org/ns/">`)
+
+	for _, r := range responses {
+		b.WriteString("<D:response><D:href>" + escape(r.Href) + "</D:href>")
+		if r.Status != 0 {
+			b.WriteString("<D:status>" + statusLine(r.Status) + "</D:status>")
+		} else {
+			if len(r.Props) > 0 || len(r.NotFound) == 0 {
+				b.WriteString("<D:propstat><D:prop>")
+				for _, prop := range r.Props {
+					writeElement(&b, prop.Name, prop.Value)
+				}
+				b.WriteString("</D:prop><D:status>" + statusLine(http.StatusOK) + "</D:status></D:propstat>")
+			}
+			if len(r.NotFound) > 0 {
+				b.WriteString("<D:propstat><D:prop>")
+				for _, name := range r.NotFound {
+					writeElement(&b, name, "")
+				}
+				b.WriteString("</D:prop><D:status>" + statusLine(http.StatusNotFound) + "</D:status></D:propstat>")
+			}
+		}
+		b.WriteString("</D:response>")
+	}
+
+	if syncToken != "" {
+		b.WriteString("<D:sync-token>" + escape(syncToken) + "</D:sync-token>")
+	}
+	b.WriteString("</D:multistatus>")
+
+	return []byte(b.String())
+}
+
+// Error renders a DAV:error body naming the precondition that failed.
+func Error(precondition xml.Name) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	b.WriteString(`<D:error xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`)
+	writeElement(&b, precondition, "")
+	b.WriteString("</D:error>")
+	return []byte(b.String())
+}
+
+func writeElement(b *strings.Builder, name xml.Name, value string) {
+	tag := name.Local
+	attr := ""
+	if prefix, ok := prefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		attr = ` xmlns="` + escape(name.Space) + `"`
+	}
+
+	if value == "" {
+		b.WriteString("<" + tag + attr + "/>")
+		return
+	}
+	b.WriteString("<" + tag + attr + ">" + value + "</" + tag + ">")
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+func escape(text string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestParseRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		kind      string
+		props     []string
+		allProp   bool
+		hrefs     []string
+		syncToken string
+	}{
+		{
+			name:    "empty propfind",
+			body:    "",
+			kind:    "propfind",
+			allProp: true,
+		},
+		{
+			name: "propfind",
+			body: `<?xml version="1.0"?><d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/">
+				<d:prop><d:displayname/><cs:getctag/><d:resourcetype/></d:prop></d:propfind>`,
+			kind:  "propfind",
+			props: []string{"displayname", "getctag", "resourcetype"},
+		},
+		{
+			name:    "allprop",
+			body:    `<propfind xmlns="DAV:"><allprop/></propfind>`,
+			kind:    "propfind",
+			allProp: true,
+		},
+		{
+			name: "calendar-multiget",
+			body: `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+				<d:prop><d:getetag/><c:calendar-data/></d:prop>
+				<d:href>/dav/tasks/task-1.ics</d:href>
+				<d:href> /dav/tasks/abc.ics </d:href>
+			</c:calendar-multiget>`,
+			kind:  "calendar-multiget",
+			props: []string{"getetag", "calendar-data"},
+			hrefs: []string{"/dav/tasks/task-1.ics", "/dav/tasks/abc.ics"},
+		},
+		{
+			name: "sync-collection",
+			body: `<sync-collection xmlns="DAV:"><sync-token>http://example.com/sync/12</sync-token>
+				<sync-level>1</sync-level><prop><getetag/></prop></sync-collection>`,
+			kind:      "sync-collection",
+			props:     []string{"getetag"},
+			syncToken: "http://example.com/sync/12",
+		},
+		{
+			name: "calendar-query with nested filters",
+			body: `<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+				<D:prop><D:getetag/></D:prop>
+				<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO"/></C:comp-filter></C:filter>
+			</C:calendar-query>`,
+			kind:  "calendar-query",
+			props: []string{"getetag"},
+		},
+	}
+
+	for _, test := range tests {
+		req, err := ParseRequest([]byte(test.body))
+		if err != nil {
+			t.Errorf("%s: ParseRequest() error = %v", test.name, err)
+			continue
+		}
+
+		var props []string
+		for _, prop := range req.Props {
+			props = append(props, prop.Local)
+		}
+
+		if req.Kind.Local != test.kind || req.AllProp != test.allProp || req.SyncToken != test.syncToken ||
+			strings.Join(props, ",") != strings.Join(test.props, ",") || strings.Join(req.Hrefs, ",") != strings.Join(test.hrefs, ",") {
+			t.Errorf("%s: ParseRequest() = %+v", test.name, req)
+		}
+	}
+
+	if _, err := ParseRequest([]byte("<propfind")); err == nil {
+		t.Error("ParseRequest() of malformed XML did not fail")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	available := []Prop{Text(PropDisplayName, "Tasks"), Text(PropGetCTag, "1")}
+	unknown := xml.Name{Space: "http://apple.com/ns/ical/", Local: "calendar-color"}
+
+	found, missing := Select(Request{Props: []xml.Name{PropGetCTag, unknown}}, available)
+	if len(found) != 1 || found[0].Name != PropGetCTag || len(missing) != 1 || missing[0] != unknown {
+		t.Errorf("Select() = (%v, %v), want the ctag found and the color missing", found, missing)
+	}
+
+	found, missing = Select(Request{AllProp: true}, available)
+	if len(found) != 2 || len(missing) != 0 {
+		t.Errorf("Select() with allprop = (%v, %v), want everything found", found, missing)
+	}
+}
+
+func TestMultistatus(t *testing.T) {
+	body := string(Multistatus([]Response{
+		{
+			Href:     "/dav/tasks/",
+			Props:    []Prop{Text(PropDisplayName, "Tasks & more"), Href(PropCurrentUserPrincipal, "/dav/")},
+			NotFound: []xml.Name{{Space: "http://apple.com/ns/ical/", Local: "calendar-color"}},
+		},
+		{Href: "/dav/tasks/gone.ics", Status: 404},
+	}, "http://example.com/sync/3"))
+
+	for _, expected := range []string{
+		`<D:response><D:href>/dav/tasks/</D:href><D:propstat><D:prop><D:displayname>Tasks &amp; more</D:displayname><D:current-user-principal><D:href>/dav/</D:href></D:current-user-principal></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat>`,
+		`<D:propstat><D:prop><calendar-color xmlns="http://apple.com/ns/ical/"/></D:prop><D:status>HTTP/1.1 404 Not Found</D:status></D:propstat>`,
+		`<D:response><D:href>/dav/tasks/gone.ics</D:href><D:status>HTTP/1.1 404 Not Found</D:status></D:response>`,
+		`<D:sync-token>http://example.com/sync/3</D:sync-token></D:multistatus>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Multistatus() does not contain %q:\n%s", expected, body)
+		}
+	}
+
+	var parsed struct {
+		Responses []struct {
+			Href string `xml:"href"`
+		} `xml:"response"`
+	}
+	if err := xml.Unmarshal([]byte(body), &parsed); err != nil || len(parsed.Responses) != 2 {
+		t.Errorf("Multistatus() is not well-formed: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package erasure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/revocation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GracePeriod is how long an erasure can be cancelled after it was
+// requested.
+const GracePeriod = 7 * 24 * time.Hour
+
+// Erase deletes all data of a user and returns the number of rows deleted
+// per table. The user's revocations go along with the rest, so every token
+// issued to them before now is revoked again in one row, kept until those
+// tokens have expired. Their legacy tokens, which carry only their email,
+// are revoked the same way. It should run in a transaction, so that a
+// failure leaves the data as it was.
+func Erase(tx *gorm.DB, userID string, now time.Time) (map[string]int64, error) {
+	deleted := map[string]int64{}
+
+	var user model.User
+	if err := tx.Where("id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+		return nil, err
+	}
+
+	for _, owned := range model.OwnedTables {
+		result := tx.Where("user_id = ?", userID).Delete(owned.Model)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		deleted[owned.Table] = result.RowsAffected
+	}
+
+	result := tx.Where("id = ?", userID).Delete(&model.User{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	deleted["users"] = result.RowsAffected
+
+	if err := account.RevokeTokens(tx, userID, now, revocation.DefaultLifetime); err != nil {
+		return nil, err
+	}
+
+	if user.Email == "" {
+		return deleted, nil
+	}
+	if legacyID := account.LegacyID(user.Email); legacyID != userID {
+		if err := account.RevokeTokens(tx, legacyID, now, revocation.DefaultLifetime); err != nil {
+			return nil, err
+		}
+	}
+
+	// Admin audit entries are kept, but no longer say who they concerned.
+	err := tx.Model(&model.AdminAuditEntry{}).Where("target_email = ?", user.Email).
+		UpdateColumns(map[string]interface{}{"target_email": "", "payload": ""}).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Model(&model.AdminAuditEntry{}).Where("actor_email = ?", user.Email).UpdateColumn("actor_email", "").Error
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// Worker erases the data of users whose grace period has ended. Requests
+// are claimed with SKIP LOCKED, so several replicas can run a worker at
+// once.
+type Worker struct {
+	DB       *gorm.DB
+	Interval time.Duration
+}
+
+func NewWorker(db *gorm.DB) *Worker {
+	return &Worker{
+		DB:       db,
+		Interval: time.Minute,
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := w.RunOnce(time.Now()); err != nil {
+				slog.Error("Failed to erase user data", slog.String("error", err.Error()))
+			} else if n > 0 {
+				slog.Info("Erased user data", slog.Int("count", n))
+			}
+		}
+	}
+}
+
+// RunOnce carries out the erasures that are due, each in its own
+// transaction, and returns how many it carried out.
+func (w *Worker) RunOnce(now time.Time) (int, error) {
+	erased := 0
+	for {
+		done, err := w.eraseNext(now)
+		if err != nil {
+			return erased, err
+		}
+		if !done {
+			return erased, nil
+		}
+		erased++
+	}
+}
+
+func (w *Worker) eraseNext(now time.Time) (bool, error) {
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		var request model.ErasureRequest
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("erase_after <= ?", now.UTC()).
+			Order("erase_after").
+			First(&request).Error
+		if err != nil {
+			return err
+		}
+
+		deleted, err := Erase(tx, request.UserID, now)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(deleted)
+		if err != nil {
+			return err
+		}
+
+		audit := model.ErasureAudit{
+			RequestID:   request.ID,
+			RequestedBy: request.RequestedBy,
+			RequestedAt: request.RequestedAt,
+			ErasedAt:    now.UTC(),
+			Deleted:     string(encoded),
+		}
+		if err := tx.Create(&audit).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&request).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
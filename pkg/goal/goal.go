@@ -0,0 +1,136 @@
+package goal
+
+import (
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// maxHistory bounds how far back goal progress is tracked.
+const maxHistory = 366 * 24 * time.Hour
+
+type PeriodResult struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Value float64   `json:"value"`
+	Met   bool      `json:"met"`
+	Rest  bool      `json:"rest"`
+}
+
+type Progress struct {
+	Goal          model.Goal     `json:"goal"`
+	Current       PeriodResult   `json:"current"`
+	CurrentStreak int            `json:"current_streak"`
+	LongestStreak int            `json:"longest_streak"`
+	History       []PeriodResult `json:"history"`
+}
+
+// Sample is a single contribution towards a goal, such as the minutes of a
+// focus session or one completed task, at the time it happened.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// PeriodStart returns the start of the goal period containing t.
+func PeriodStart(g model.Goal, t time.Time, location *time.Location) time.Time {
+	if g.Period == model.GoalWeekly {
+		return utils.StartOfWeek(t, location)
+	}
+	return utils.StartOfDay(t, location)
+}
+
+// NextPeriod returns the start of the goal period following the one that
+// begins at start.
+func NextPeriod(g model.Goal, start time.Time) time.Time {
+	if g.Period == model.GoalWeekly {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Evaluate buckets the samples into goal periods from the period containing
+// since up to and including the period containing now, and computes the
+// resulting streaks. The current period is never counted as missed while it
+// is still in progress.
+func Evaluate(g model.Goal, samples []Sample, since time.Time, now time.Time, location *time.Location) Progress {
+	currentStart := PeriodStart(g, now, location)
+
+	totals := map[int64]float64{}
+	for _, sample := range samples {
+		totals[PeriodStart(g, sample.Time, location).Unix()] += sample.Value
+	}
+
+	progress := Progress{Goal: g, History: []PeriodResult{}}
+
+	streak := 0
+	for start := PeriodStart(g, since, location); !start.After(currentStart); start = NextPeriod(g, start) {
+		result := PeriodResult{
+			Start: start,
+			End:   NextPeriod(g, start),
+			Value: totals[start.Unix()],
+		}
+		result.Met = result.Value >= g.Target
+		result.Rest = g.Period == model.GoalDaily && g.IsRestDay(start.Weekday())
+
+		switch {
+		case result.Met:
+			streak++
+		case result.Rest, start.Equal(currentStart):
+			// Neither extends nor breaks the streak.
+		default:
+			streak = 0
+		}
+
+		if streak > progress.LongestStreak {
+			progress.LongestStreak = streak
+		}
+
+		if start.Equal(currentStart) {
+			progress.Current = result
+		} else {
+			progress.History = append(progress.History, result)
+		}
+	}
+
+	progress.CurrentStreak = streak
+
+	return progress
+}
+
+// Track loads the sessions or tasks a goal is measured against and evaluates
+// its progress as of now.
+func Track(db *gorm.DB, g model.Goal, now time.Time, location *time.Location) (Progress, error) {
+	since := g.CreatedAt
+	if earliest := now.Add(-maxHistory); since.Before(earliest) {
+		since = earliest
+	}
+	from := PeriodStart(g, since, location).UTC()
+
+	samples := []Sample{}
+
+	switch g.Kind {
+	case model.GoalFocusMinutes:
+		var sessions []model.PomodoroSession
+		err := db.Where("user_id = ? AND end_time IS NOT NULL AND start_time >= ?", g.UserID, from).Find(&sessions).Error
+		if err != nil {
+			return Progress{}, err
+		}
+		for _, session := range sessions {
+			samples = append(samples, Sample{Time: session.StartTime, Value: session.Duration().Minutes()})
+		}
+	case model.GoalTasksCompleted:
+		var tasks []model.Task
+		err := db.Where("user_id = ? AND is_complete = ? AND completed_at >= ?", g.UserID, true, from).Find(&tasks).Error
+		if err != nil {
+			return Progress{}, err
+		}
+		for _, task := range tasks {
+			samples = append(samples, Sample{Time: *task.CompletedAt, Value: 1})
+		}
+	}
+
+	return Evaluate(g, samples, since, now, location), nil
+}
@@ -0,0 +1,93 @@
+package goal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+)
+
+func day(d int, hour int) time.Time {
+	return time.Date(2024, 7, d, hour, 0, 0, 0, time.UTC)
+}
+
+func TestEvaluateDailyStreak(t *testing.T) {
+	g := model.Goal{Kind: model.GoalFocusMinutes, Period: model.GoalDaily, Target: 100}
+
+	samples := []Sample{
+		{day(22, 9), 60}, {day(22, 14), 50}, // met
+		{day(23, 10), 30},  // missed
+		{day(24, 10), 120}, // met
+		{day(25, 10), 100}, // met
+		{day(26, 10), 110}, // met
+		{day(27, 8), 20},   // today, in progress
+	}
+
+	progress := Evaluate(g, samples, day(22, 0), day(27, 12), time.UTC)
+
+	if len(progress.History) != 5 {
+		t.Fatalf("History length = %d, want 5", len(progress.History))
+	}
+	if progress.History[0].Value != 110 || !progress.History[0].Met {
+		t.Errorf("First period = %+v, want value 110 and met", progress.History[0])
+	}
+	if progress.History[1].Met {
+		t.Errorf("Second period should be missed: %+v", progress.History[1])
+	}
+	if progress.Current.Value != 20 || progress.Current.Met {
+		t.Errorf("Current period = %+v, want value 20 and not met", progress.Current)
+	}
+	if progress.CurrentStreak != 3 {
+		t.Errorf("CurrentStreak = %d, want 3", progress.CurrentStreak)
+	}
+	if progress.LongestStreak != 3 {
+		t.Errorf("LongestStreak = %d, want 3", progress.LongestStreak)
+	}
+}
+
+func TestEvaluateRestDaysDoNotBreakStreak(t *testing.T) {
+	// 2024-07-27 is a Saturday and 2024-07-28 a Sunday.
+	g := model.Goal{Kind: model.GoalFocusMinutes, Period: model.GoalDaily, Target: 60, RestDays: "saturday,sunday"}
+
+	samples := []Sample{
+		{day(25, 10), 60},
+		{day(26, 10), 60},
+		{day(29, 10), 60},
+	}
+
+	progress := Evaluate(g, samples, day(25, 0), day(30, 12), time.UTC)
+
+	if progress.CurrentStreak != 3 {
+		t.Errorf("CurrentStreak = %d, want 3", progress.CurrentStreak)
+	}
+	if !progress.History[2].Rest || !progress.History[3].Rest {
+		t.Errorf("Weekend periods should be rest days: %+v", progress.History)
+	}
+}
+
+func TestEvaluateWeeklyGoalInTimeZone(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	g := model.Goal{Kind: model.GoalTasksCompleted, Period: model.GoalWeekly, Target: 2}
+
+	// 2024-07-21 20:00 UTC is already Monday 2024-07-22 in Jakarta.
+	samples := []Sample{
+		{time.Date(2024, 7, 21, 20, 0, 0, 0, time.UTC), 1},
+		{day(23, 10), 1},
+	}
+
+	progress := Evaluate(g, samples, day(15, 12), day(30, 12), jakarta)
+
+	if len(progress.History) != 2 {
+		t.Fatalf("History length = %d, want 2", len(progress.History))
+	}
+	if progress.History[0].Met || !progress.History[1].Met {
+		t.Errorf("History = %+v, want first week missed and second met", progress.History)
+	}
+	if progress.CurrentStreak != 1 || progress.LongestStreak != 1 {
+		t.Errorf("Streaks = (%d, %d), want (1, 1)", progress.CurrentStreak, progress.LongestStreak)
+	}
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"errors"
+	"runtime"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/revocation"
+	"github.com/abyan-dev/productivity/pkg/rollup"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// startedAt approximates when the service started, for its uptime.
+var startedAt = time.Now()
+
+// AdminStats aggregates the data of all users.
+type AdminStats struct {
+	Users             int64   `json:"users"`
+	Tasks             int64   `json:"tasks"`
+	CompletedTasks    int64   `json:"completed_tasks"`
+	Sessions          int64   `json:"sessions"`
+	FocusMinutes      float64 `json:"focus_minutes"`
+	ScheduledErasures int64   `json:"scheduled_erasures"`
+}
+
+type AdminUser struct {
+	Email          string `json:"email"`
+	UserID         string `json:"user_id"`
+	Tasks          int64  `json:"tasks"`
+	CompletedTasks int64  `json:"completed_tasks"`
+	Sessions       int64  `json:"sessions"`
+}
+
+type ServiceStats struct {
+	StartedAt     time.Time   `json:"started_at"`
+	UptimeSeconds int64       `json:"uptime_seconds"`
+	Goroutines    int         `json:"goroutines"`
+	GoVersion     string      `json:"go_version"`
+	DB            DBPoolStats `json:"db"`
+}
+
+type DBPoolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
+
+type RebuildRollupsPayload struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// GetAdminStats returns aggregate stats over all users.
+func GetAdminStats(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	stats := AdminStats{}
+
+	if err := db.Model(&model.User{}).Count(&stats.Users).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve stats.")
+	}
+	if err := db.Model(&model.Task{}).Count(&stats.Tasks).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve stats.")
+	}
+	if err := db.Model(&model.Task{}).Where("is_complete = ?", true).Count(&stats.CompletedTasks).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve stats.")
+	}
+	if err := db.Model(&model.PomodoroSession{}).Count(&stats.Sessions).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve stats.")
+	}
+	if err := db.Model(&model.DailyRollup{}).Select("COALESCE(SUM(focus_minutes), 0)").Scan(&stats.FocusMinutes).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve stats.")
+	}
+	if err := db.Model(&model.ErasureRequest{}).Count(&stats.ScheduledErasures).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve stats.")
+	}
+
+	return response.Ok(c, "Successfully retrieved stats", stats)
+}
+
+// GetAdminUsers lists every user, with how many tasks and sessions they
+// have.
+func GetAdminUsers(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	users := []AdminUser{}
+	err := db.Raw(`SELECT users.email, users.id AS user_id,
+		(SELECT COUNT(*) FROM tasks WHERE tasks.user_id = users.id) AS tasks,
+		(SELECT COUNT(*) FROM tasks WHERE tasks.user_id = users.id AND tasks.is_complete) AS completed_tasks,
+		(SELECT COUNT(*) FROM pomodoro_sessions WHERE pomodoro_sessions.user_id = users.id) AS sessions
+		FROM users
+		ORDER BY users.email`).Scan(&users).Error
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve users.")
+	}
+
+	return response.Ok(c, "Successfully retrieved users", users)
+}
+
+// GetAdminUserTasks shows a user's tasks, for support.
+func GetAdminUserTasks(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	email := c.Params("email")
+	if isEmailValid, emailValFeedback := utils.ValidateEmail(email); !isEmailValid {
+		return response.BadRequest(c, emailValFeedback)
+	}
+
+	user, err := findUserByEmail(db, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.NotFound(c, "User not found")
+		}
+		return response.InternalServerError(c, "Database error")
+	}
+
+	tasks := []model.Task{}
+	if err := db.Where("user_id = ?", user.ID).Order("id").Find(&tasks).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve tasks.")
+	}
+
+	return response.Ok(c, "Successfully retrieved tasks", tasks)
+}
+
+// AdminStopPomodoro stops a user's running Pomodoro as if they had stopped
+// it themselves.
+func AdminStopPomodoro(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	email := c.Params("email")
+	if isEmailValid, emailValFeedback := utils.ValidateEmail(email); !isEmailValid {
+		return response.BadRequest(c, emailValFeedback)
+	}
+
+	user, err := findUserByEmail(db, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.NotFound(c, "User not found")
+		}
+		return response.InternalServerError(c, "Database error")
+	}
+
+	return stopPomodoro(c, db, user.ID)
+}
+
+// AdminRevokeTokens revokes every token issued to a user so far, signing
+// them out everywhere.
+func AdminRevokeTokens(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	revocations := c.Locals("revocations").(*revocation.Store)
+
+	email := c.Params("email")
+	if isEmailValid, emailValFeedback := utils.ValidateEmail(email); !isEmailValid {
+		return response.BadRequest(c, emailValFeedback)
+	}
+
+	user, err := findUserByEmail(db, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.NotFound(c, "User not found")
+		}
+		return response.InternalServerError(c, "Database error")
+	}
+
+	if err := revocations.RevokeAll(user.ID, time.Now()); err != nil {
+		return response.InternalServerError(c, "Failed to revoke tokens.")
+	}
+
+	return response.Ok(c, "Successfully revoked tokens")
+}
+
+// AdminRebuildRollups marks the metrics rollups of a user, or of every user
+// when no email is given, for the rollup worker to rebuild. The range is
+// open on any end that is not given.
+func AdminRebuildRollups(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	userID := ""
+	if email := c.Params("email"); email != "" {
+		if isEmailValid, emailValFeedback := utils.ValidateEmail(email); !isEmailValid {
+			return response.BadRequest(c, emailValFeedback)
+		}
+
+		user, err := findUserByEmail(db, email)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return response.NotFound(c, "User not found")
+			}
+			return response.InternalServerError(c, "Database error")
+		}
+		userID = user.ID
+	}
+
+	requestPayload := RebuildRollupsPayload{}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&requestPayload); err != nil {
+			return response.BadRequest(c, "Invalid request payload")
+		}
+	}
+
+	var from, to time.Time
+	var err error
+	if requestPayload.From != "" {
+		if from, err = time.Parse(rollup.DateLayout, requestPayload.From); err != nil {
+			return response.BadRequest(c, "From must be a date in the format YYYY-MM-DD")
+		}
+	}
+	if requestPayload.To != "" {
+		if to, err = time.Parse(rollup.DateLayout, requestPayload.To); err != nil {
+			return response.BadRequest(c, "To must be a date in the format YYYY-MM-DD")
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return response.BadRequest(c, "From must be before to")
+	}
+
+	if err := rollup.Invalidate(db, userID, from, to); err != nil {
+		return response.InternalServerError(c, "Failed to queue rollups for rebuilding.")
+	}
+
+	return response.Accepted(c, "Successfully queued rollups for rebuilding.")
+}
+
+// GetServiceStats reports on the running service and its database pool.
+func GetServiceStats(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve database stats.")
+	}
+	pool := sqlDB.Stats()
+
+	stats := ServiceStats{
+		StartedAt:     startedAt.UTC(),
+		UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		GoVersion:     runtime.Version(),
+		DB: DBPoolStats{
+			MaxOpenConnections: pool.MaxOpenConnections,
+			OpenConnections:    pool.OpenConnections,
+			InUse:              pool.InUse,
+			Idle:               pool.Idle,
+			WaitCount:          pool.WaitCount,
+			WaitDurationMs:     pool.WaitDuration.Milliseconds(),
+			MaxIdleClosed:      pool.MaxIdleClosed,
+			MaxLifetimeClosed:  pool.MaxLifetimeClosed,
+		},
+	}
+
+	return response.Ok(c, "Successfully retrieved service stats", stats)
+}
+
+// GetAdminAudit lists the most recent admin audit entries, newest first.
+// The number of entries defaults to 100 and is capped at 1000.
+func GetAdminAudit(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 || limit > 1000 {
+		return response.BadRequest(c, "Limit must be between 1 and 1000")
+	}
+
+	query := db.Order("id DESC").Limit(limit)
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor_email = ?", actor)
+	}
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target_email = ?", target)
+	}
+
+	entries := []model.AdminAuditEntry{}
+	if err := query.Find(&entries).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve audit log.")
+	}
+
+	return response.Ok(c, "Successfully retrieved audit log", entries)
+}
+
+// findUserByEmail finds the user an admin route acts on by the email in its
+// path.
+func findUserByEmail(db *gorm.DB, email string) (model.User, error) {
+	var user model.User
+	err := db.Where("email = ?", email).First(&user).Error
+	return user, err
+}
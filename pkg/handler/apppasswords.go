@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const maxAppPasswordsPerUser = 20
+
+type AppPasswordPayload struct {
+	Name string `json:"name"`
+}
+
+// CreatedAppPassword is the only response that includes the password.
+type CreatedAppPassword struct {
+	model.AppPassword
+	Password string `json:"password"`
+}
+
+func CreateAppPassword(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	if madeWithToken(c) {
+		return response.Forbidden(c, "Personal access tokens cannot create app passwords")
+	}
+
+	claims := c.Locals("user").(jwt.MapClaims)
+	role, _ := claims["role"].(string)
+
+	requestPayload := AppPasswordPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	if requestPayload.Name == "" || len(requestPayload.Name) > 100 {
+		return response.BadRequest(c, "Name must be between 1 and 100 characters")
+	}
+
+	var count int64
+	if err := db.Model(&model.AppPassword{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve app passwords.")
+	}
+	if count >= maxAppPasswordsPerUser {
+		return response.BadRequest(c, "A user can have at most 20 app passwords")
+	}
+
+	password, passwordHash, err := utils.NewSecretToken("app_")
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create app password.")
+	}
+
+	appPassword := model.AppPassword{
+		Name:         requestPayload.Name,
+		PasswordHash: passwordHash,
+		Role:         role,
+		CreatedAt:    time.Now().UTC(),
+		UserID:       userID,
+	}
+
+	if err := db.Create(&appPassword).Error; err != nil {
+		return response.InternalServerError(c, "Failed to create app password.")
+	}
+
+	return response.Created(c, "Successfully created app password.", CreatedAppPassword{AppPassword: appPassword, Password: password})
+}
+
+func GetAllAppPasswords(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	appPasswords := []model.AppPassword{}
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&appPasswords).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve app passwords.")
+	}
+
+	return response.Ok(c, "Successfully retrieved app passwords", appPasswords)
+}
+
+func DeleteAppPassword(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	result := db.Where("user_id = ?", userID).Delete(&model.AppPassword{}, id)
+	if result.Error != nil {
+		return response.InternalServerError(c, "Failed to delete app password.")
+	}
+
+	if result.RowsAffected == 0 {
+		return response.NotFound(c, "App password not found")
+	}
+
+	return response.Ok(c, "Successfully deleted app password.")
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/revocation"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/abyan-dev/productivity/pkg/webhook"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Events the auth service sends when a user changes their email, and when
+// an admin changes a user's role.
+const (
+	AuthEventEmailChanged = "user.email_changed"
+	AuthEventRoleChanged  = "user.role_changed"
+)
+
+// AuthEvent is a notification from the auth service. It is signed the same
+// way as the webhooks this service sends, with AUTH_WEBHOOK_SECRET.
+type AuthEvent struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type EmailChangedPayload struct {
+	UserID   string `json:"user_id"`
+	OldEmail string `json:"old_email"`
+	NewEmail string `json:"new_email"`
+}
+
+type RoleChangedPayload struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+// HandleAuthEvent applies notifications from the auth service. Event types
+// it does not know are acknowledged and ignored.
+func HandleAuthEvent(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	secret := os.Getenv("AUTH_WEBHOOK_SECRET")
+	if secret == "" {
+		return response.ServiceUnavailable(c, "Auth events are not configured")
+	}
+	if !webhook.Verify(secret, c.Get(webhook.SignatureHeader), c.Body(), time.Now()) {
+		return response.Unauthorized(c, "Invalid signature")
+	}
+
+	event := AuthEvent{}
+	if err := json.Unmarshal(c.Body(), &event); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	switch event.Type {
+	case AuthEventEmailChanged:
+		return applyEmailChanged(c, db, event)
+	case AuthEventRoleChanged:
+		return applyRoleChanged(c, db, event)
+	default:
+		return response.Ok(c, "Ignored event of type "+event.Type)
+	}
+}
+
+func applyEmailChanged(c *fiber.Ctx, db *gorm.DB, event AuthEvent) error {
+	requestPayload := EmailChangedPayload{}
+	if err := json.Unmarshal(event.Data, &requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	if requestPayload.UserID == "" {
+		return response.BadRequest(c, "User ID is required")
+	}
+	for _, email := range []string{requestPayload.OldEmail, requestPayload.NewEmail} {
+		if isEmailValid, emailValFeedback := utils.ValidateEmail(email); !isEmailValid {
+			return response.BadRequest(c, emailValFeedback)
+		}
+	}
+
+	user, err := account.ChangeEmail(db, requestPayload.UserID, requestPayload.OldEmail, requestPayload.NewEmail)
+	if err != nil {
+		if errors.Is(err, account.ErrEmailTaken) {
+			return response.BadRequest(c, "The new email belongs to another user")
+		}
+		return response.InternalServerError(c, "Failed to change email.")
+	}
+
+	slog.Info("Applied email change", slog.String("user_id", requestPayload.UserID), slog.String("event_id", event.ID))
+	return response.Ok(c, "Successfully changed email", user)
+}
+
+// applyRoleChanged revokes all of the user's tokens. Their tokens, personal
+// access tokens and app passwords carry the role they were issued with, so
+// none of them may outlive it; the user signs in again to get the new one.
+func applyRoleChanged(c *fiber.Ctx, db *gorm.DB, event AuthEvent) error {
+	revocations := c.Locals("revocations").(*revocation.Store)
+
+	requestPayload := RoleChangedPayload{}
+	if err := json.Unmarshal(event.Data, &requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	if isEmailValid, emailValFeedback := utils.ValidateEmail(requestPayload.Email); !isEmailValid {
+		return response.BadRequest(c, emailValFeedback)
+	}
+
+	user, err := account.Resolve(db, requestPayload.UserID, requestPayload.Email)
+	if err != nil {
+		if errors.Is(err, account.ErrEmailTaken) {
+			return response.BadRequest(c, "The email belongs to another user")
+		}
+		return response.InternalServerError(c, "Failed to change role.")
+	}
+
+	if err := revocations.RevokeAll(user.ID, time.Now()); err != nil {
+		return response.InternalServerError(c, "Failed to change role.")
+	}
+
+	slog.Info("Revoked tokens after role change", slog.String("user_id", requestPayload.UserID), slog.String("role", requestPayload.Role), slog.String("event_id", event.ID))
+	return response.Ok(c, "Successfully revoked tokens after role change")
+}
@@ -0,0 +1,704 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/caldav"
+	"github.com/abyan-dev/productivity/pkg/ical"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/notify"
+	"github.com/abyan-dev/productivity/pkg/outbox"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	davRootPath  = "/dav/"
+	davTasksPath = "/dav/tasks/"
+
+	// davSyncTokenPrefix turns outbox event IDs into the URIs that
+	// sync-collection requires sync tokens to be.
+	davSyncTokenPrefix = "http://productivity.abyan.dev/ns/sync/"
+
+	davXMLContentType  = "application/xml; charset=utf-8"
+	davTodoContentType = "text/calendar; charset=utf-8; component=VTODO"
+)
+
+var (
+	errDAVResourceNotFound   = errors.New("resource not found")
+	errDAVPreconditionFailed = errors.New("precondition failed")
+	errDAVUIDConflict        = errors.New("UID belongs to another resource")
+	errDAVNameReserved       = errors.New("resource name is reserved")
+)
+
+// CalDAVOptions advertises CalDAV support, which is how clients discover
+// that the server speaks it at all.
+func CalDAVOptions(c *fiber.Ctx) error {
+	c.Set("DAV", "1, 3, calendar-access")
+	c.Set(fiber.HeaderAllow, "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// CalDAVWellKnown points clients that only know the host at the principal.
+func CalDAVWellKnown(c *fiber.Ctx) error {
+	return c.Redirect(davRootPath, fiber.StatusMovedPermanently)
+}
+
+// PropfindPrincipal serves the user's principal, which is also their
+// calendar home containing the single task collection.
+func PropfindPrincipal(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	email, ok := userEmail(c)
+	if !ok {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	req, err := caldav.ParseRequest(c.Body())
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	principal := []caldav.Prop{
+		{Name: caldav.PropResourceType, Value: "<D:collection/><D:principal/>"},
+		caldav.Text(caldav.PropDisplayName, email),
+		caldav.Href(caldav.PropCurrentUserPrincipal, davRootPath),
+		caldav.Href(caldav.PropPrincipalURL, davRootPath),
+		caldav.Href(caldav.PropCalendarHomeSet, davRootPath),
+		caldav.Href(caldav.PropCalendarUserAddressSet, "mailto:"+email),
+	}
+	responses := []caldav.Response{davResponse(req, davRootPath, principal)}
+
+	if c.Get("Depth", "infinity") != "0" {
+		collection, err := tasksCollectionProps(db, userID)
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		responses = append(responses, davResponse(req, davTasksPath, collection))
+	}
+
+	return sendMultistatus(c, responses, "")
+}
+
+// PropfindTasks serves the task collection and, unless the depth is 0, the
+// tasks in it.
+func PropfindTasks(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	req, err := caldav.ParseRequest(c.Body())
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	collection, err := tasksCollectionProps(db, userID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	responses := []caldav.Response{davResponse(req, davTasksPath, collection)}
+
+	if c.Get("Depth", "infinity") != "0" {
+		var tasks []model.Task
+		if err := db.Where("user_id = ?", userID).Order("id").Find(&tasks).Error; err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		now := time.Now()
+		for _, task := range tasks {
+			responses = append(responses, davResponse(req, davTaskHref(task), taskProps(req, task, now)))
+		}
+	}
+
+	return sendMultistatus(c, responses, "")
+}
+
+// PropfindTask serves the properties of a single task.
+func PropfindTask(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	req, err := caldav.ParseRequest(c.Body())
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	task, err := findDAVTask(db, userID, davParamName(c))
+	if err != nil {
+		return davLookupError(c, err)
+	}
+
+	return sendMultistatus(c, []caldav.Response{davResponse(req, davTaskHref(task), taskProps(req, task, time.Now()))}, "")
+}
+
+// ReportTasks answers the calendar-query, calendar-multiget and
+// sync-collection reports on the task collection.
+func ReportTasks(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	req, err := caldav.ParseRequest(c.Body())
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	now := time.Now()
+
+	switch req.Kind {
+	case caldav.ReportCalendarQuery:
+		// The collection only holds to-dos, so every task matches the
+		// component filters clients send.
+		var tasks []model.Task
+		if err := db.Where("user_id = ?", userID).Order("id").Find(&tasks).Error; err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		responses := []caldav.Response{}
+		for _, task := range tasks {
+			responses = append(responses, davResponse(req, davTaskHref(task), taskProps(req, task, now)))
+		}
+		return sendMultistatus(c, responses, "")
+
+	case caldav.ReportCalendarMultiget:
+		responses := []caldav.Response{}
+		for _, href := range req.Hrefs {
+			name, ok := davTaskName(href)
+			if !ok {
+				responses = append(responses, caldav.Response{Href: href, Status: http.StatusNotFound})
+				continue
+			}
+
+			task, err := findDAVTask(db, userID, name)
+			if errors.Is(err, errDAVResourceNotFound) {
+				responses = append(responses, caldav.Response{Href: href, Status: http.StatusNotFound})
+				continue
+			}
+			if err != nil {
+				return c.SendStatus(fiber.StatusInternalServerError)
+			}
+			responses = append(responses, davResponse(req, href, taskProps(req, task, now)))
+		}
+		return sendMultistatus(c, responses, "")
+
+	case caldav.ReportSyncCollection:
+		return syncTasks(c, db, userID, req, now)
+	}
+
+	return sendDAVError(c, fiber.StatusForbidden, caldav.PreconditionSupportedReport)
+}
+
+// GetTaskResource returns a task as an iCalendar VTODO.
+func GetTaskResource(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	task, err := findDAVTask(db, userID, davParamName(c))
+	if err != nil {
+		return davLookupError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, davTodoContentType)
+	c.Set(fiber.HeaderETag, davETag(task))
+	c.Set(fiber.HeaderLastModified, task.UpdatedAt.UTC().Format(http.TimeFormat))
+	return c.SendString(ical.Todo(task, time.Now()))
+}
+
+// PutTaskResource creates or replaces a task from an iCalendar VTODO. The
+// If-Match and If-None-Match headers keep clients from overwriting changes
+// they have not seen.
+func PutTaskResource(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	name := davParamName(c)
+	if name == "" || len(name) > 255 {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	location, err := userLocation(db, userID, "")
+	if err != nil {
+		location = time.UTC
+	}
+
+	todo, err := ical.ParseTodo(string(c.Body()), location)
+	if errors.Is(err, ical.ErrNoTodo) {
+		return sendDAVError(c, fiber.StatusForbidden, caldav.PreconditionSupportedComponent)
+	}
+	if err != nil || todo.UID == "" {
+		return sendDAVError(c, fiber.StatusForbidden, caldav.PreconditionValidCalendarData)
+	}
+
+	var dueDate time.Time
+	if todo.Due != nil {
+		dueDate = todo.Due.UTC()
+	}
+
+	// The task is read and written in one transaction that holds the
+	// user's CalDAV lock and locks the task's row, so that two requests
+	// cannot both pass the checks below and one of their changes be lost.
+	var task model.Task
+	var exists, dueDateChanged, completedNow bool
+	var previousCompletedAt time.Time
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockDAVUser(tx, userID); err != nil {
+			return err
+		}
+
+		var err error
+		task, err = findDAVTask(tx.Clauses(clause.Locking{Strength: "UPDATE"}).Session(&gorm.Session{}), userID, name)
+		exists = err == nil
+		if err != nil && !errors.Is(err, errDAVResourceNotFound) {
+			return err
+		}
+
+		ifMatch := c.Get(fiber.HeaderIfMatch)
+		if c.Get(fiber.HeaderIfNoneMatch) == "*" && exists {
+			return errDAVPreconditionFailed
+		}
+		if ifMatch != "" && (!exists || (ifMatch != "*" && ifMatch != davETag(task))) {
+			return errDAVPreconditionFailed
+		}
+
+		if exists {
+			if todo.UID != ical.TaskUID(task) {
+				return errDAVUIDConflict
+			}
+		} else {
+			// Names of the form task-<id>.ics belong to tasks created
+			// through the API, so clients cannot create resources under
+			// them.
+			if _, derived := derivedTaskID(name); derived {
+				return errDAVNameReserved
+			}
+
+			var conflicts int64
+			if err := tx.Model(&model.Task{}).Where("user_id = ? AND uid = ?", userID, todo.UID).Count(&conflicts).Error; err != nil {
+				return err
+			}
+			if conflicts > 0 {
+				return errDAVUIDConflict
+			}
+
+			task = model.Task{UID: todo.UID, DAVName: name, UserID: userID}
+		}
+
+		dueDateChanged = exists && !task.DueDate.Equal(dueDate)
+		completedNow = todo.Completed && !task.IsComplete
+		if task.CompletedAt != nil {
+			previousCompletedAt = *task.CompletedAt
+		}
+
+		task.Title = davTaskTitle(todo.Summary)
+		task.Description = todo.Description
+		task.DueDate = dueDate
+		if dueDateChanged {
+			task.OverdueNotifiedAt = nil
+		}
+
+		if completedNow {
+			completedAt := time.Now().UTC()
+			if todo.CompletedAt != nil {
+				completedAt = todo.CompletedAt.UTC()
+			}
+			task.CompletedAt = &completedAt
+		} else if !todo.Completed {
+			task.CompletedAt = nil
+		}
+		task.IsComplete = todo.Completed
+
+		if !exists {
+			if err := tx.Create(&task).Error; err != nil {
+				return err
+			}
+			if err := outbox.Record(tx, userID, outbox.EventTaskCreated, task); err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Save(&task).Error; err != nil {
+				return err
+			}
+			if err := outbox.Record(tx, userID, outbox.EventTaskUpdated, task); err != nil {
+				return err
+			}
+		}
+		if completedNow {
+			return outbox.Record(tx, userID, outbox.EventTaskCompleted, task)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errDAVPreconditionFailed):
+		return c.SendStatus(fiber.StatusPreconditionFailed)
+	case errors.Is(err, errDAVUIDConflict):
+		return sendDAVError(c, fiber.StatusForbidden, caldav.PreconditionNoUIDConflict)
+	case errors.Is(err, errDAVNameReserved):
+		return c.SendStatus(fiber.StatusConflict)
+	case err != nil:
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	touched := []time.Time{previousCompletedAt}
+	if !exists {
+		touched = append(touched, task.CreatedAt)
+	}
+	if task.CompletedAt != nil {
+		touched = append(touched, *task.CompletedAt)
+	}
+	touchRollups(db, userID, touched...)
+
+	if dueDateChanged {
+		if err := notify.RescheduleReminders(db, task, time.Now()); err != nil {
+			slog.Error("Failed to reschedule reminders", slog.Uint64("task_id", uint64(task.ID)), slog.String("error", err.Error()))
+		}
+	}
+
+	c.Set(fiber.HeaderETag, davETag(task))
+	if !exists {
+		return c.SendStatus(fiber.StatusCreated)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// DeleteTaskResource deletes a task, honouring If-Match.
+func DeleteTaskResource(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	var task model.Task
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockDAVUser(tx, userID); err != nil {
+			return err
+		}
+
+		var err error
+		task, err = findDAVTask(tx.Clauses(clause.Locking{Strength: "UPDATE"}).Session(&gorm.Session{}), userID, davParamName(c))
+		if err != nil {
+			return err
+		}
+
+		if ifMatch := c.Get(fiber.HeaderIfMatch); ifMatch != "" && ifMatch != "*" && ifMatch != davETag(task) {
+			return errDAVPreconditionFailed
+		}
+
+		if err := tx.Where("task_id = ?", task.ID).Delete(&model.Reminder{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&task).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, userID, outbox.EventTaskDeleted, task)
+	})
+	if errors.Is(err, errDAVPreconditionFailed) {
+		return c.SendStatus(fiber.StatusPreconditionFailed)
+	}
+	if err != nil {
+		return davLookupError(c, err)
+	}
+
+	touched := []time.Time{task.CreatedAt}
+	if task.CompletedAt != nil {
+		touched = append(touched, *task.CompletedAt)
+	}
+	touchRollups(db, userID, touched...)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// syncTasks answers a sync-collection report. Every task change is recorded
+// in the outbox, so the ID of the latest task event serves as sync token and
+// the events after a client's token name the tasks it has to fetch again.
+func syncTasks(c *fiber.Ctx, db *gorm.DB, userID string, req caldav.Request, now time.Time) error {
+	current, err := davSyncToken(db, userID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	responses := []caldav.Response{}
+
+	if req.SyncToken == "" {
+		var tasks []model.Task
+		if err := db.Where("user_id = ?", userID).Order("id").Find(&tasks).Error; err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		for _, task := range tasks {
+			responses = append(responses, davResponse(req, davTaskHref(task), taskProps(req, task, now)))
+		}
+		return sendMultistatus(c, responses, davSyncTokenPrefix+strconv.FormatUint(uint64(current), 10))
+	}
+
+	since, ok := parseSyncToken(req.SyncToken)
+	if !ok || since > current {
+		return sendDAVError(c, fiber.StatusForbidden, caldav.PreconditionValidSyncToken)
+	}
+
+	// Old events are deleted by the outbox janitor, so the changes since a
+	// token from before the latest event it deleted may be gone.
+	var pruned uint
+	err = db.Model(&model.User{}).
+		Where("id = ?", userID).
+		Select("COALESCE(MAX(pruned_task_event_id), 0)").
+		Scan(&pruned).Error
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	if since < pruned {
+		return sendDAVError(c, fiber.StatusForbidden, caldav.PreconditionValidSyncToken)
+	}
+
+	var events []model.OutboxEvent
+	err = db.Where("user_id = ? AND id > ? AND type LIKE ?", userID, since, "task.%").Order("id").Find(&events).Error
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	// The payload of every task event is the task, which is all that is
+	// left to name a resource by once the task is deleted.
+	changed := map[uint]model.Task{}
+	for _, event := range events {
+		var task model.Task
+		if err := json.Unmarshal([]byte(event.Payload), &task); err != nil || task.ID == 0 {
+			continue
+		}
+		changed[task.ID] = task
+	}
+
+	ids := make([]uint, 0, len(changed))
+	for id := range changed {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	existing := map[uint]model.Task{}
+	if len(ids) > 0 {
+		var tasks []model.Task
+		if err := db.Where("user_id = ? AND id IN ?", userID, ids).Find(&tasks).Error; err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		for _, task := range tasks {
+			existing[task.ID] = task
+		}
+	}
+
+	for _, id := range ids {
+		task, ok := existing[id]
+		if !ok {
+			responses = append(responses, caldav.Response{Href: davTaskHref(changed[id]), Status: http.StatusNotFound})
+			continue
+		}
+		responses = append(responses, davResponse(req, davTaskHref(task), taskProps(req, task, now)))
+	}
+
+	return sendMultistatus(c, responses, davSyncTokenPrefix+strconv.FormatUint(uint64(current), 10))
+}
+
+func tasksCollectionProps(db *gorm.DB, userID string) ([]caldav.Prop, error) {
+	current, err := davSyncToken(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	token := davSyncTokenPrefix + strconv.FormatUint(uint64(current), 10)
+
+	return []caldav.Prop{
+		{Name: caldav.PropResourceType, Value: "<D:collection/><C:calendar/>"},
+		caldav.Text(caldav.PropDisplayName, "Tasks"),
+		caldav.Href(caldav.PropOwner, davRootPath),
+		{Name: caldav.PropSupportedCalendarComponent, Value: `<C:comp name="VTODO"/>`},
+		{Name: caldav.PropCurrentUserPrivilegeSet, Value: "<D:privilege><D:read/></D:privilege><D:privilege><D:write/></D:privilege>" +
+			"<D:privilege><D:write-content/></D:privilege><D:privilege><D:bind/></D:privilege><D:privilege><D:unbind/></D:privilege>"},
+		{Name: caldav.PropSupportedReportSet, Value: "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+			"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>" +
+			"<D:supported-report><D:report><D:sync-collection/></D:report></D:supported-report>"},
+		caldav.Text(caldav.PropGetCTag, token),
+		caldav.Text(caldav.PropSyncToken, token),
+	}, nil
+}
+
+// taskProps returns the properties of a task. The calendar data is only
+// included if it was asked for, as allprop is meant to be cheap.
+func taskProps(req caldav.Request, task model.Task, now time.Time) []caldav.Prop {
+	props := []caldav.Prop{
+		{Name: caldav.PropResourceType},
+		caldav.Text(caldav.PropGetETag, davETag(task)),
+		caldav.Text(caldav.PropGetContentType, davTodoContentType),
+		caldav.Text(caldav.PropGetLastModified, task.UpdatedAt.UTC().Format(http.TimeFormat)),
+	}
+	if !req.AllProp {
+		props = append(props, caldav.Text(caldav.PropCalendarData, ical.Todo(task, now)))
+	}
+	return props
+}
+
+func davResponse(req caldav.Request, href string, available []caldav.Prop) caldav.Response {
+	props, missing := caldav.Select(req, available)
+	return caldav.Response{Href: href, Props: props, NotFound: missing}
+}
+
+// davSyncToken returns the ID of the user's latest task event, or 0 if
+// there is none. outbox.Record makes a user's events visible in the order of
+// their IDs, so no event can show up later behind the one returned.
+func davSyncToken(db *gorm.DB, userID string) (uint, error) {
+	var current uint
+	err := db.Model(&model.OutboxEvent{}).
+		Where("user_id = ? AND type LIKE ?", userID, "task.%").
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&current).Error
+	return current, err
+}
+
+func parseSyncToken(token string) (uint, bool) {
+	id, ok := strings.CutPrefix(token, davSyncTokenPrefix)
+	if !ok {
+		return 0, false
+	}
+	parsed, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(parsed), true
+}
+
+// davETag derives the ETag from the task's iCalendar representation, so
+// that it changes exactly when what clients see of the task does.
+func davETag(task model.Task) string {
+	sum := sha256.Sum256([]byte(ical.Todo(task, time.Time{})))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// findDAVTask looks a task up by its resource name: the name a client gave
+// it, or task-<id>.ics for tasks that were not created over CalDAV.
+// lockDAVUser serializes the CalDAV writes of a user until the transaction
+// ends, so that checking a resource's name and UID and writing it are one
+// step.
+func lockDAVUser(tx *gorm.DB, userID string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "dav:"+userID).Error
+}
+
+func findDAVTask(db *gorm.DB, userID string, name string) (model.Task, error) {
+	var task model.Task
+	err := db.Where("user_id = ? AND dav_name = ?", userID, name).First(&task).Error
+	if err == nil {
+		return task, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.Task{}, err
+	}
+
+	id, ok := derivedTaskID(name)
+	if !ok {
+		return model.Task{}, errDAVResourceNotFound
+	}
+
+	err = db.Where("id = ? AND user_id = ? AND dav_name = ''", id, userID).First(&task).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.Task{}, errDAVResourceNotFound
+	}
+	return task, err
+}
+
+func derivedTaskID(name string) (uint, bool) {
+	id, ok := strings.CutPrefix(name, "task-")
+	if !ok {
+		return 0, false
+	}
+	id, ok = strings.CutSuffix(id, ".ics")
+	if !ok {
+		return 0, false
+	}
+	parsed, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || parsed == 0 {
+		return 0, false
+	}
+	return uint(parsed), true
+}
+
+func davTaskHref(task model.Task) string {
+	name := task.DAVName
+	if name == "" {
+		name = "task-" + strconv.FormatUint(uint64(task.ID), 10) + ".ics"
+	}
+	return davTasksPath + url.PathEscape(name)
+}
+
+// davTaskName returns the resource name in an href, which clients may send
+// as a path or as an absolute URL.
+func davTaskName(href string) (string, bool) {
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	name, ok := strings.CutPrefix(parsed.Path, davTasksPath)
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+// davParamName returns the unescaped resource name in the request path.
+func davParamName(c *fiber.Ctx) string {
+	name, err := url.PathUnescape(c.Params("name"))
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// davTaskTitle fits a VTODO summary into a task title.
+func davTaskTitle(summary string) string {
+	title := strings.TrimSpace(summary)
+	if title == "" {
+		return "Untitled task"
+	}
+	if runes := []rune(title); len(runes) > 100 {
+		return string(runes[:100])
+	}
+	return title
+}
+
+func davLookupError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errDAVResourceNotFound) {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	return c.SendStatus(fiber.StatusInternalServerError)
+}
+
+func sendMultistatus(c *fiber.Ctx, responses []caldav.Response, syncToken string) error {
+	c.Set(fiber.HeaderContentType, davXMLContentType)
+	return c.Status(fiber.StatusMultiStatus).Send(caldav.Multistatus(responses, syncToken))
+}
+
+func sendDAVError(c *fiber.Ctx, status int, precondition xml.Name) error {
+	c.Set(fiber.HeaderContentType, davXMLContentType)
+	return c.Status(status).Send(caldav.Error(precondition))
+}
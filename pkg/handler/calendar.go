@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/ical"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// feedTaskHistory and feedSessionHistory bound how far back a calendar
+	// feed goes, so that it stays small enough to be polled.
+	feedTaskHistory    = 30 * 24 * time.Hour
+	feedSessionHistory = 90 * 24 * time.Hour
+)
+
+type CalendarFeedPayload struct {
+	IncludeSessions bool `json:"include_sessions"`
+	TasksAsTodos    bool `json:"tasks_as_todos"`
+}
+
+// CreatedCalendarFeed is the only response that includes the feed URL, as
+// it contains the secret token.
+type CreatedCalendarFeed struct {
+	model.CalendarFeed
+	URL string `json:"url"`
+}
+
+func ExportCalendar(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	var tasksAsTodos bool
+	switch c.Query("tasks_as", "event") {
+	case "event":
+	case "todo":
+		tasksAsTodos = true
+	default:
+		return response.BadRequest(c, "Tasks can only be exported as 'event' or 'todo'")
+	}
+
+	feed := model.CalendarFeed{UserID: userID, IncludeSessions: c.QueryBool("include_sessions"), TasksAsTodos: tasksAsTodos}
+	calendar, err := buildCalendar(db, feed, time.Time{}, time.Now())
+	if err != nil {
+		return response.InternalServerError(c, "Failed to export calendar.")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="productivity.ics"`)
+	return c.SendString(calendar)
+}
+
+func GetCalendarFeed(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	var feed model.CalendarFeed
+	result := db.Where("user_id = ?", userID).First(&feed)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Calendar feed not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve calendar feed.")
+	}
+
+	return response.Ok(c, "Successfully retrieved calendar feed", feed)
+}
+
+// CreateCalendarFeed creates the user's feed or, if they already have one,
+// replaces its token so that the previous URL stops working.
+func CreateCalendarFeed(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	if madeWithToken(c) {
+		return response.Forbidden(c, "Personal access tokens cannot create calendar feeds")
+	}
+
+	requestPayload := CalendarFeedPayload{}
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&requestPayload); err != nil {
+			return response.BadRequest(c, "Invalid request payload")
+		}
+	}
+
+	token, tokenHash, err := utils.NewSecretToken("")
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create calendar feed.")
+	}
+
+	feed := model.CalendarFeed{
+		UserID:          userID,
+		TokenHash:       tokenHash,
+		IncludeSessions: requestPayload.IncludeSessions,
+		TasksAsTodos:    requestPayload.TasksAsTodos,
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	if err := db.Save(&feed).Error; err != nil {
+		return response.InternalServerError(c, "Failed to create calendar feed.")
+	}
+
+	return response.Created(c, "Successfully created calendar feed.", CreatedCalendarFeed{
+		CalendarFeed: feed,
+		URL:          fmt.Sprintf("%s/api/productivity/calendar/feeds/%s/calendar.ics", c.BaseURL(), token),
+	})
+}
+
+func UpdateCalendarFeed(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	requestPayload := CalendarFeedPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	var feed model.CalendarFeed
+	result := db.Where("user_id = ?", userID).First(&feed)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Calendar feed not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve calendar feed.")
+	}
+
+	feed.IncludeSessions = requestPayload.IncludeSessions
+	feed.TasksAsTodos = requestPayload.TasksAsTodos
+
+	if err := db.Save(&feed).Error; err != nil {
+		return response.InternalServerError(c, "Failed to update calendar feed.")
+	}
+
+	return response.Ok(c, "Successfully updated calendar feed", feed)
+}
+
+func DeleteCalendarFeed(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	result := db.Where("user_id = ?", userID).Delete(&model.CalendarFeed{})
+	if result.Error != nil {
+		return response.InternalServerError(c, "Failed to delete calendar feed.")
+	}
+
+	if result.RowsAffected == 0 {
+		return response.NotFound(c, "Calendar feed not found")
+	}
+
+	return response.Ok(c, "Successfully deleted calendar feed.")
+}
+
+// ServeCalendarFeed serves a feed to calendar apps. It is not behind the
+// authentication middleware, since calendar apps cannot send our cookies;
+// the token in the URL authenticates the request instead.
+func ServeCalendarFeed(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	var feed model.CalendarFeed
+	result := db.Where("token_hash = ?", utils.HashToken(c.Params("token"))).First(&feed)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return response.NotFound(c, "Calendar feed not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve calendar feed.")
+	}
+
+	now := time.Now()
+	calendar, err := buildCalendar(db, feed, now.Add(-feedTaskHistory), now)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to build calendar feed.")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.SendString(calendar)
+}
+
+// buildCalendar renders the tasks due since dueSince, or all tasks with a
+// due date if it is zero, and if the feed includes them, recent focus
+// sessions.
+func buildCalendar(db *gorm.DB, feed model.CalendarFeed, dueSince time.Time, now time.Time) (string, error) {
+	var tasks []model.Task
+	query := db.Where("user_id = ?", feed.UserID)
+	if !dueSince.IsZero() {
+		query = query.Where("due_date >= ?", dueSince.UTC())
+	}
+	if err := query.Order("due_date").Find(&tasks).Error; err != nil {
+		return "", err
+	}
+
+	var sessions []model.PomodoroSession
+	options := ical.Options{TasksAsTodos: feed.TasksAsTodos, SubjectNames: map[uint]string{}}
+	if feed.IncludeSessions {
+		err := db.Where("user_id = ? AND end_time IS NOT NULL AND start_time >= ?", feed.UserID, now.Add(-feedSessionHistory).UTC()).
+			Order("start_time").
+			Find(&sessions).Error
+		if err != nil {
+			return "", err
+		}
+
+		var subjects []model.Subject
+		if err := db.Where("user_id = ?", feed.UserID).Find(&subjects).Error; err != nil {
+			return "", err
+		}
+		for _, subject := range subjects {
+			options.SubjectNames[subject.ID] = subject.Name
+		}
+	}
+
+	return ical.Calendar("Productivity", tasks, sessions, options, now), nil
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/middleware"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetCSRFToken returns the CSRF token to send in the X-CSRF-Token header,
+// setting the csrf_token cookie to a new one if there is none yet. Only
+// pages of the allowed origins can read the response.
+func GetCSRFToken(c *fiber.Ctx) error {
+	token := c.Cookies(middleware.CSRFCookie)
+	if token == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return response.InternalServerError(c, "Failed to create CSRF token.")
+		}
+		token = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	// The token is not secret from the page, which has to read it, but
+	// SameSite keeps it from being sent along by other sites.
+	c.Cookie(&fiber.Cookie{
+		Name:     middleware.CSRFCookie,
+		Value:    token,
+		Expires:  time.Now().Add(7 * 24 * time.Hour),
+		HTTPOnly: false,
+		SameSite: "Strict",
+		Secure:   false,
+	})
+
+	return response.Ok(c, "Successfully fetched CSRF token", fiber.Map{"token": token})
+}
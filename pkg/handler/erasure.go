@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/erasure"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RequestErasure schedules the erasure of all of the caller's data once
+// the grace period ends.
+func RequestErasure(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return scheduleErasure(c, db, userID, model.ErasureRequestedByUser)
+}
+
+func GetErasure(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	var request model.ErasureRequest
+	if err := db.Where("user_id = ?", userID).First(&request).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "No erasure is scheduled")
+		}
+		return response.InternalServerError(c, "Failed to retrieve erasure.")
+	}
+
+	return response.Ok(c, "Successfully retrieved erasure", request)
+}
+
+// CancelErasure cancels the caller's scheduled erasure.
+func CancelErasure(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return cancelErasure(c, db, userID)
+}
+
+// AdminRequestErasure schedules the erasure of another user's data, with
+// the same grace period.
+func AdminRequestErasure(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	email := c.Params("email")
+	isEmailValid, emailValFeedback := utils.ValidateEmail(email)
+	if !isEmailValid {
+		return response.BadRequest(c, emailValFeedback)
+	}
+
+	user, err := findUserByEmail(db, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.NotFound(c, "User not found")
+		}
+		return response.InternalServerError(c, "Database error")
+	}
+
+	return scheduleErasure(c, db, user.ID, model.ErasureRequestedByAdmin)
+}
+
+func AdminCancelErasure(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	user, err := findUserByEmail(db, c.Params("email"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.NotFound(c, "No erasure is scheduled")
+		}
+		return response.InternalServerError(c, "Database error")
+	}
+
+	return cancelErasure(c, db, user.ID)
+}
+
+func GetErasureAudits(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	audits := []model.ErasureAudit{}
+	if err := db.Order("id DESC").Find(&audits).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve erasure audits.")
+	}
+
+	return response.Ok(c, "Successfully retrieved erasure audits", audits)
+}
+
+func scheduleErasure(c *fiber.Ctx, db *gorm.DB, userID string, requestedBy string) error {
+	var existing model.ErasureRequest
+	err := db.Where("user_id = ?", userID).First(&existing).Error
+	if err == nil {
+		return response.BadRequest(c, "An erasure is already scheduled", existing)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.InternalServerError(c, "Failed to retrieve erasure.")
+	}
+
+	now := time.Now().UTC()
+	request := model.ErasureRequest{
+		RequestedBy: requestedBy,
+		RequestedAt: now,
+		EraseAfter:  now.Add(erasure.GracePeriod),
+		UserID:      userID,
+	}
+	if err := db.Create(&request).Error; err != nil {
+		return response.InternalServerError(c, "Failed to schedule erasure.")
+	}
+
+	return response.Accepted(c, "Successfully scheduled erasure.", request)
+}
+
+func cancelErasure(c *fiber.Ctx, db *gorm.DB, userID string) error {
+	result := db.Where("user_id = ?", userID).Delete(&model.ErasureRequest{})
+	if result.Error != nil {
+		return response.InternalServerError(c, "Failed to cancel erasure.")
+	}
+	if result.RowsAffected == 0 {
+		return response.NotFound(c, "No erasure is scheduled")
+	}
+
+	return response.Ok(c, "Successfully cancelled erasure.")
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/archive"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/rollup"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// exportLinkLifetime is how long a download link stays valid. A new one can
+// be fetched for as long as the export is kept.
+const exportLinkLifetime = 15 * time.Minute
+
+// ExportJobDetail is an export along with a link to download its archive,
+// once it is ready.
+type ExportJobDetail struct {
+	model.ExportJob
+	DownloadURL          string     `json:"download_url,omitempty"`
+	DownloadURLExpiresAt *time.Time `json:"download_url_expires_at,omitempty"`
+}
+
+// CreateExport queues an export of all of the caller's data.
+func CreateExport(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	var running int64
+	err := db.Model(&model.ExportJob{}).
+		Where("user_id = ? AND status IN ?", userID, []string{model.ExportPending, model.ExportRunning}).
+		Count(&running).Error
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve exports.")
+	}
+	if running > 0 {
+		return response.BadRequest(c, "Another export is still running")
+	}
+
+	now := time.Now().UTC()
+	job := model.ExportJob{
+		Status:    model.ExportPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    userID,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return response.InternalServerError(c, "Failed to create export.")
+	}
+
+	return response.Accepted(c, "Successfully queued export.", job)
+}
+
+func GetAllExports(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	jobs := []model.ExportJob{}
+	if err := db.Omit("archive").Where("user_id = ?", userID).Order("id DESC").Find(&jobs).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve exports.")
+	}
+
+	return response.Ok(c, "Successfully retrieved exports", jobs)
+}
+
+// GetExport returns an export. Completed exports come with a freshly signed
+// download link.
+func GetExport(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	var job model.ExportJob
+	if err := db.Omit("archive").Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Export not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve export.")
+	}
+
+	detail := ExportJobDetail{ExportJob: job}
+	now := time.Now()
+	if job.Status == model.ExportCompleted && job.ExpiresAt != nil && job.ExpiresAt.After(now) {
+		expires := now.Add(exportLinkLifetime)
+		if job.ExpiresAt.Before(expires) {
+			expires = *job.ExpiresAt
+		}
+		signature, err := archive.SignDownload(archive.SigningSecret(), job.ID, expires)
+		if err != nil {
+			return response.InternalServerError(c, "Failed to sign download link.")
+		}
+		detail.DownloadURL = fmt.Sprintf("%s/api/productivity/exports/%d/archive.zip?expires=%d&signature=%s", c.BaseURL(), job.ID, expires.Unix(), signature)
+		detail.DownloadURLExpiresAt = &expires
+	}
+
+	return response.Ok(c, "Successfully retrieved export", detail)
+}
+
+// DownloadExport serves the archive of an export. It is public, since the
+// signature in the link authenticates the request instead.
+func DownloadExport(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return response.NotFound(c, "Export not found")
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !archive.VerifyDownload(archive.SigningSecret(), uint(id), expires, c.Query("signature"), time.Now()) {
+		return response.Forbidden(c, "Download link is invalid or has expired")
+	}
+
+	var job model.ExportJob
+	if err := db.Where("id = ? AND status = ?", id, model.ExportCompleted).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Export not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve export.")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="productivity-export-%d.zip"`, job.ID))
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Send(job.Archive)
+}
+
+// ImportArchive restores an archive created by an export, which may come
+// from another instance, into the caller's account.
+func ImportArchive(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return response.BadRequest(c, "The archive must be uploaded as the file field")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return response.InternalServerError(c, "Failed to read archive.")
+	}
+	defer file.Close()
+
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to read archive.")
+	}
+
+	data, _, err := archive.Read(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return response.BadRequest(c, "Failed to read the archive: "+err.Error())
+	}
+
+	var counts archive.Counts
+	err = db.Transaction(func(tx *gorm.DB) error {
+		counts, err = archive.Restore(tx, userID, data)
+		return err
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to import archive.")
+	}
+
+	if err := rollup.Rebuild(db, userID, time.Time{}, time.Time{}); err != nil {
+		slog.Error("Failed to rebuild rollups", slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+
+	return response.Ok(c, "Successfully imported archive", counts)
+}
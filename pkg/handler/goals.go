@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/goal"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type GoalPayload struct {
+	Kind     string   `json:"kind"`
+	Period   string   `json:"period"`
+	Target   float64  `json:"target"`
+	RestDays []string `json:"rest_days"`
+}
+
+func CreateGoal(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	requestPayload := GoalPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	g := model.Goal{CreatedAt: time.Now().UTC(), UserID: userID}
+	if isValid, feedback := applyGoalPayload(&g, requestPayload); !isValid {
+		return response.BadRequest(c, feedback)
+	}
+
+	if err := db.Create(&g).Error; err != nil {
+		return response.InternalServerError(c, "Failed to create goal.")
+	}
+
+	return response.Created(c, "Successfully created goal.", g)
+}
+
+func GetAllGoals(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	goals := []model.Goal{}
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&goals).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve goals.")
+	}
+
+	return response.Ok(c, "Successfully retrieved goals", goals)
+}
+
+func UpdateGoal(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	requestPayload := GoalPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	var g model.Goal
+	result := db.Where("user_id = ?", userID).First(&g, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Goal not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve goal.")
+	}
+
+	if isValid, feedback := applyGoalPayload(&g, requestPayload); !isValid {
+		return response.BadRequest(c, feedback)
+	}
+
+	if err := db.Save(&g).Error; err != nil {
+		return response.InternalServerError(c, "Failed to update goal.")
+	}
+
+	return response.Ok(c, "Successfully updated goal", g)
+}
+
+func DeleteGoal(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	result := db.Where("user_id = ?", userID).Delete(&model.Goal{}, id)
+	if result.Error != nil {
+		return response.InternalServerError(c, "Failed to delete goal.")
+	}
+
+	if result.RowsAffected == 0 {
+		return response.NotFound(c, "Goal not found")
+	}
+
+	return response.Ok(c, "Successfully deleted goal.")
+}
+
+func GetGoalProgress(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	location, err := userLocation(db, userID, c.Query("tz"))
+	if err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	var g model.Goal
+	result := db.Where("user_id = ?", userID).First(&g, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Goal not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve goal.")
+	}
+
+	progress, err := goal.Track(db, g, time.Now(), location)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to evaluate goal.")
+	}
+
+	return response.Ok(c, "Successfully evaluated goal progress", progress)
+}
+
+func applyGoalPayload(g *model.Goal, payload GoalPayload) (bool, string) {
+	if !model.IsValidGoalKind(payload.Kind) {
+		return false, "Goal kind must be either 'focus_minutes' or 'tasks_completed'"
+	}
+
+	if !model.IsValidGoalPeriod(payload.Period) {
+		return false, "Goal period must be either 'daily' or 'weekly'"
+	}
+
+	if payload.Target <= 0 {
+		return false, "Goal target must be positive"
+	}
+
+	if len(payload.RestDays) > 0 && payload.Period != model.GoalDaily {
+		return false, "Rest days are only supported for daily goals"
+	}
+
+	restDays := make([]string, 0, len(payload.RestDays))
+	for _, name := range payload.RestDays {
+		day, isWeekday := model.ParseWeekday(name)
+		if !isWeekday {
+			return false, "Rest days must be weekday names such as 'sunday'"
+		}
+		restDays = append(restDays, strings.ToLower(day.String()))
+	}
+
+	g.Kind = payload.Kind
+	g.Period = payload.Period
+	g.Target = payload.Target
+	g.RestDays = strings.Join(restDays, ",")
+
+	return true, "Goal is valid"
+}
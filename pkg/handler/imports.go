@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/importer"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ImportJobDetail is an import along with the rows it rejected.
+type ImportJobDetail struct {
+	model.ImportJob
+	Errors []importer.RowError `json:"errors"`
+}
+
+// PreviewImport validates an export and reports which tasks an import of it
+// would create, without creating any.
+func PreviewImport(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	source, rows, feedback, err := parseImportUpload(c, db, userID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to read import.")
+	}
+	if feedback != "" {
+		return response.BadRequest(c, feedback)
+	}
+
+	existing, err := importer.ExistingKeys(db, userID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve tasks.")
+	}
+
+	return response.Ok(c, "Successfully previewed import from "+source, importer.NewPreview(rows, existing))
+}
+
+// CreateImport queues an import of an export. Invalid rows are left out,
+// and rows duplicating existing tasks are skipped when the job runs.
+func CreateImport(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	source, rows, feedback, err := parseImportUpload(c, db, userID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to read import.")
+	}
+	if feedback != "" {
+		return response.BadRequest(c, feedback)
+	}
+
+	var running int64
+	err = db.Model(&model.ImportJob{}).
+		Where("user_id = ? AND status IN ?", userID, []string{model.ImportPending, model.ImportRunning}).
+		Count(&running).Error
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve imports.")
+	}
+	if running > 0 {
+		return response.BadRequest(c, "Another import is still running")
+	}
+
+	items, rowErrors := importer.Validate(rows)
+	encodedItems, err := json.Marshal(items)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create import.")
+	}
+	encodedErrors, err := json.Marshal(rowErrors)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create import.")
+	}
+
+	now := time.Now().UTC()
+	job := model.ImportJob{
+		Source:    source,
+		Status:    model.ImportPending,
+		Total:     len(items),
+		Invalid:   len(rowErrors),
+		Items:     string(encodedItems),
+		RowErrors: string(encodedErrors),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    userID,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return response.InternalServerError(c, "Failed to create import.")
+	}
+
+	return response.Accepted(c, "Successfully queued import.", ImportJobDetail{ImportJob: job, Errors: rowErrors})
+}
+
+func GetAllImports(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	jobs := []model.ImportJob{}
+	if err := db.Omit("items", "row_errors").Where("user_id = ?", userID).Order("id DESC").Find(&jobs).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve imports.")
+	}
+
+	return response.Ok(c, "Successfully retrieved imports", jobs)
+}
+
+// GetImport returns an import, whose counts show its progress while it
+// runs.
+func GetImport(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	var job model.ImportJob
+	if err := db.Omit("items").Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Import not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve import.")
+	}
+
+	rowErrors := []importer.RowError{}
+	if err := json.Unmarshal([]byte(job.RowErrors), &rowErrors); err != nil {
+		return response.InternalServerError(c, "Failed to retrieve import.")
+	}
+
+	return response.Ok(c, "Successfully retrieved import", ImportJobDetail{ImportJob: job, Errors: rowErrors})
+}
+
+// RollbackImport deletes the tasks created by a finished import.
+func RollbackImport(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	var job model.ImportJob
+	if err := db.Omit("items", "row_errors").Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Import not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve import.")
+	}
+
+	tasks, err := importer.Rollback(db, &job, time.Now())
+	if err != nil {
+		if errors.Is(err, importer.ErrNotFinished) {
+			return response.BadRequest(c, "Only finished imports can be rolled back")
+		}
+		return response.InternalServerError(c, "Failed to roll back import.")
+	}
+
+	touched := []time.Time{}
+	for _, task := range tasks {
+		touched = append(touched, task.CreatedAt)
+		if task.CompletedAt != nil {
+			touched = append(touched, *task.CompletedAt)
+		}
+	}
+	touchRollups(db, userID, touched...)
+
+	return response.Ok(c, "Successfully rolled back import", job)
+}
+
+// parseImportUpload reads the export uploaded as the multipart field file,
+// along with the source, the CSV column mapping and the time zone of dates
+// without one. A non-empty feedback is a problem with the request.
+func parseImportUpload(c *fiber.Ctx, db *gorm.DB, userID string) (string, []importer.Row, string, error) {
+	source := c.FormValue("source")
+	if !importer.IsValidSource(source) {
+		return "", nil, "Source must be 'csv', 'todoist' or 'trello'", nil
+	}
+
+	mapping := importer.Mapping{}
+	if source == importer.SourceCSV {
+		if err := json.Unmarshal([]byte(c.FormValue("mapping")), &mapping); err != nil {
+			return "", nil, "Mapping must be a JSON object naming the CSV columns", nil
+		}
+	}
+
+	location, err := userLocation(db, userID, c.FormValue("time_zone"))
+	if err != nil {
+		return "", nil, "Time zone is invalid", nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return "", nil, "The export must be uploaded as the file field", nil
+	}
+	file, err := header.Open()
+	if err != nil {
+		return "", nil, "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	rows, err := importer.Parse(source, data, mapping, location)
+	if err != nil {
+		return "", nil, "Failed to read the export: " + err.Error(), nil
+	}
+
+	return source, rows, "", nil
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/auth"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/revocation"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Logout revokes the token the request was made with, along with the access
+// and refresh token cookies and their refresh token family, and clears the
+// cookies.
+func Logout(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	revocations := c.Locals("revocations").(*revocation.Store)
+	verifier := c.Locals("verifier").(*auth.Verifier)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	if madeWithToken(c) {
+		return response.BadRequest(c, "Personal access tokens are revoked by deleting them")
+	}
+
+	current, _ := c.Locals("token").(string)
+	tokens := []string{current, c.Cookies("access_token"), c.Cookies("refresh_token")}
+
+	// Only tokens that verify and belong to the caller are revoked, so
+	// that a forged cookie cannot choose which token IDs get revoked or
+	// for how long. Tokens that no longer verify are refused anyway.
+	// Ownership is decided on the user ID in the sub claim, or the one
+	// resolved from the email of a legacy token, and never on the raw
+	// email claim, which goes stale when a user changes their email.
+	now := time.Now()
+	revoked := map[string]bool{}
+	families := map[string]bool{}
+	for _, token := range tokens {
+		if token == "" || revoked[token] {
+			continue
+		}
+		claims, err := verifier.Parse(token)
+		if err != nil {
+			continue
+		}
+		owner, err := account.TokenUserID(db, claims)
+		if err != nil {
+			return response.InternalServerError(c, "Failed to log out.")
+		}
+		if owner != userID {
+			continue
+		}
+		if err := revocations.Revoke(token, claims, userID, now); err != nil {
+			return response.InternalServerError(c, "Failed to log out.")
+		}
+		revoked[token] = true
+
+		if familyID, _ := claims["fid"].(string); familyID != "" && !families[familyID] {
+			if err := revocations.RevokeFamily(familyID, now); err != nil {
+				return response.InternalServerError(c, "Failed to log out.")
+			}
+			families[familyID] = true
+		}
+	}
+
+	c.Cookie(utils.InvalidateCookie("access_token"))
+	c.Cookie(utils.InvalidateCookie("refresh_token"))
+
+	return response.Ok(c, "Successfully logged out")
+}
+
+// LogoutAll revokes every token issued to the caller so far, on every
+// device, personal access tokens included.
+func LogoutAll(c *fiber.Ctx) error {
+	revocations := c.Locals("revocations").(*revocation.Store)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	if err := revocations.RevokeAll(userID, time.Now()); err != nil {
+		return response.InternalServerError(c, "Failed to log out.")
+	}
+
+	c.Cookie(utils.InvalidateCookie("access_token"))
+	c.Cookie(utils.InvalidateCookie("refresh_token"))
+
+	return response.Ok(c, "Successfully logged out everywhere")
+}
@@ -1,10 +1,378 @@
 package handler
 
 import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/analytics"
+	"github.com/abyan-dev/productivity/pkg/model"
 	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/rollup"
+	"github.com/abyan-dev/productivity/pkg/utils"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMetricsPeriod = 7 * 24 * time.Hour
+	defaultFocusWindow   = 2
+	maxFocusWindow       = 12
 )
 
+type StudyMetrics struct {
+	From                  time.Time            `json:"from"`
+	To                    time.Time            `json:"to"`
+	TimeZone              string               `json:"time_zone"`
+	IncludesManual        bool                 `json:"includes_manual"`
+	SessionCount          int                  `json:"session_count"`
+	ManualSessionCount    int                  `json:"manual_session_count"`
+	TotalFocusMinutes     float64              `json:"total_focus_minutes"`
+	ManualFocusMinutes    float64              `json:"manual_focus_minutes"`
+	AverageSessionMinutes float64              `json:"average_session_minutes"`
+	TasksCompleted        int                  `json:"tasks_completed"`
+	TasksCreated          int                  `json:"tasks_created"`
+	Interruptions         InterruptionMetrics  `json:"interruptions"`
+	Week                  WeeklySubjectMetrics `json:"week"`
+}
+
+type InterruptionMetrics struct {
+	Total   int         `json:"total"`
+	PerHour float64     `json:"per_focus_hour"`
+	ByKind  []KindCount `json:"by_kind"`
+	ByHour  [24]int     `json:"by_hour_of_day"`
+}
+
+type WeeklySubjectMetrics struct {
+	WeekStart         time.Time        `json:"week_start"`
+	Subjects          []SubjectMetrics `json:"subjects"`
+	UnassignedMinutes float64          `json:"unassigned_minutes"`
+}
+
+type SubjectMetrics struct {
+	SubjectID     uint    `json:"subject_id"`
+	Name          string  `json:"name"`
+	Color         string  `json:"color"`
+	FocusMinutes  float64 `json:"focus_minutes"`
+	TargetMinutes float64 `json:"target_minutes"`
+	Progress      float64 `json:"progress"`
+}
+
+type KindCount struct {
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+}
+
 func GenerateStudyMetrics(c *fiber.Ctx) error {
-	return response.Ok(c, "")
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	location, err := userLocation(db, userID, c.Query("tz"))
+	if err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	to := time.Now().UTC()
+	if toStr := c.Query("to"); toStr != "" {
+		isToValid, _, toTime := utils.ValidateTime(toStr)
+		if !isToValid {
+			return response.BadRequest(c, "To time format is invalid")
+		}
+		to = toTime.UTC()
+	}
+
+	from := to.Add(-defaultMetricsPeriod)
+	if fromStr := c.Query("from"); fromStr != "" {
+		isFromValid, _, fromTime := utils.ValidateTime(fromStr)
+		if !isFromValid {
+			return response.BadRequest(c, "From time format is invalid")
+		}
+		from = fromTime.UTC()
+	}
+
+	if !to.After(from) {
+		return response.BadRequest(c, "End time must be after start time")
+	}
+
+	includeManual := c.QueryBool("include_manual", true)
+
+	days, err := rollup.Days(db, userID, from, to, location)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve study activity.")
+	}
+
+	var interruptionTimes []time.Time
+	err = db.Model(&model.Interruption{}).
+		Joins("JOIN pomodoro_sessions ON pomodoro_sessions.id = interruptions.session_id").
+		Where("pomodoro_sessions.user_id = ? AND pomodoro_sessions.end_time IS NOT NULL", userID).
+		Where("pomodoro_sessions.start_time >= ? AND pomodoro_sessions.start_time < ?", from, to).
+		Pluck("interruptions.occurred_at", &interruptionTimes).Error
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve interruptions.")
+	}
+
+	weekStart := utils.StartOfWeek(time.Now(), location)
+	weekEnd := weekStart.AddDate(0, 0, 7)
+
+	weekQuery := db.Where("user_id = ? AND end_time IS NOT NULL AND start_time >= ? AND start_time < ?", userID, weekStart.UTC(), weekEnd.UTC())
+	if !includeManual {
+		weekQuery = weekQuery.Where("is_manual = ?", false)
+	}
+
+	var weekSessions []model.PomodoroSession
+	if err := weekQuery.Find(&weekSessions).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve sessions.")
+	}
+
+	var subjects []model.Subject
+	subjectQuery := db.Where("user_id = ?", userID).
+		Where("(term_start IS NULL OR term_start < ?)", weekEnd.UTC()).
+		Where("(term_end IS NULL OR term_end >= ?)", weekStart.UTC())
+	if err := subjectQuery.Order("name").Find(&subjects).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve subjects.")
+	}
+
+	totals := rollup.Sum(days)
+
+	metrics := summarizeTotals(totals, includeManual)
+	metrics.From = from
+	metrics.To = to
+	metrics.TimeZone = location.String()
+	metrics.Interruptions = summarizeInterruptions(totals, interruptionTimes, location)
+	metrics.Week = summarizeSubjects(subjects, weekSessions)
+	metrics.Week.WeekStart = weekStart
+
+	return response.Ok(c, "Successfully generated study metrics", metrics)
+}
+
+func GetFocusHeatmap(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	location, err := userLocation(db, userID, c.Query("tz"))
+	if err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	// Default to the trailing year up to and including today.
+	to := utils.StartOfDay(time.Now(), location).AddDate(0, 0, 1)
+	from := to.AddDate(-1, 0, 0)
+
+	if yearStr := c.Query("year"); yearStr != "" {
+		year, err := strconv.Atoi(yearStr)
+		if err != nil || year < 1970 || year > 9999 {
+			return response.BadRequest(c, "Year is invalid")
+		}
+		from = time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+		to = from.AddDate(1, 0, 0)
+	}
+
+	days, err := rollup.Days(db, userID, from, to, location)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve study activity.")
+	}
+
+	includeManual := c.QueryBool("include_manual", true)
+	minutesByDate := make(map[string]float64, len(days))
+	for date, day := range days {
+		minutesByDate[date] = day.FocusMinutes
+		if !includeManual {
+			minutesByDate[date] -= day.ManualFocusMinutes
+		}
+	}
+
+	return response.Ok(c, "Successfully generated focus heatmap", analytics.BuildHeatmap(minutesByDate, from, to, location))
+}
+
+func GetTimeOfDayMetrics(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	location, err := userLocation(db, userID, c.Query("tz"))
+	if err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	to := time.Now().UTC()
+	if toStr := c.Query("to"); toStr != "" {
+		isToValid, _, toTime := utils.ValidateTime(toStr)
+		if !isToValid {
+			return response.BadRequest(c, "To time format is invalid")
+		}
+		to = toTime.UTC()
+	}
+
+	from := to.AddDate(-1, 0, 0)
+	if fromStr := c.Query("from"); fromStr != "" {
+		isFromValid, _, fromTime := utils.ValidateTime(fromStr)
+		if !isFromValid {
+			return response.BadRequest(c, "From time format is invalid")
+		}
+		from = fromTime.UTC()
+	}
+
+	if !to.After(from) {
+		return response.BadRequest(c, "End time must be after start time")
+	}
+
+	window := c.QueryInt("window", defaultFocusWindow)
+	if window < 1 || window > maxFocusWindow {
+		return response.BadRequest(c, "Window must be between 1 and 12 hours")
+	}
+
+	intervals, err := loadFocusIntervals(db, userID, from, to, c.QueryBool("include_manual", true))
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve sessions.")
+	}
+
+	return response.Ok(c, "Successfully generated time-of-day metrics", analytics.BuildTimeOfDay(intervals, location, window))
+}
+
+// loadFocusIntervals returns the completed sessions of a user that overlap
+// [from, to), clipped to that range. Only the session times are loaded so
+// that a full year of history stays cheap to read.
+func loadFocusIntervals(db *gorm.DB, userID string, from time.Time, to time.Time, includeManual bool) ([]analytics.Interval, error) {
+	query := db.Model(&model.PomodoroSession{}).
+		Select("start_time", "end_time").
+		Where("user_id = ? AND end_time IS NOT NULL AND start_time < ? AND end_time > ?", userID, to.UTC(), from.UTC())
+	if !includeManual {
+		query = query.Where("is_manual = ?", false)
+	}
+
+	var sessions []model.PomodoroSession
+	if err := query.Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	intervals := make([]analytics.Interval, 0, len(sessions))
+	for _, session := range sessions {
+		interval := analytics.Interval{Start: session.StartTime, End: *session.EndTime}
+		if interval.Start.Before(from) {
+			interval.Start = from
+		}
+		if interval.End.After(to) {
+			interval.End = to
+		}
+		intervals = append(intervals, interval)
+	}
+
+	return intervals, nil
+}
+
+// summarizeTotals derives the session metrics from aggregated counters,
+// leaving manually logged sessions out unless includeManual is set.
+func summarizeTotals(totals model.DailyRollup, includeManual bool) StudyMetrics {
+	metrics := StudyMetrics{
+		IncludesManual:     includeManual,
+		SessionCount:       totals.SessionCount,
+		ManualSessionCount: totals.ManualSessionCount,
+		TotalFocusMinutes:  totals.FocusMinutes,
+		ManualFocusMinutes: totals.ManualFocusMinutes,
+		TasksCompleted:     totals.TasksCompleted,
+		TasksCreated:       totals.TasksCreated,
+	}
+
+	if !includeManual {
+		metrics.SessionCount -= totals.ManualSessionCount
+		metrics.TotalFocusMinutes -= totals.ManualFocusMinutes
+		metrics.ManualSessionCount = 0
+		metrics.ManualFocusMinutes = 0
+	}
+
+	if metrics.SessionCount > 0 {
+		metrics.AverageSessionMinutes = metrics.TotalFocusMinutes / float64(metrics.SessionCount)
+	}
+
+	return metrics
+}
+
+// summarizeInterruptions aggregates interruptions logged during timed
+// sessions. The rate is taken over timed focus minutes only, since manually
+// logged sessions cannot have interruptions recorded against them.
+func summarizeInterruptions(totals model.DailyRollup, occurrences []time.Time, location *time.Location) InterruptionMetrics {
+	metrics := InterruptionMetrics{
+		Total:  totals.InternalInterruptions + totals.ExternalInterruptions,
+		ByKind: []KindCount{},
+	}
+
+	for kind, count := range map[string]int{
+		model.InterruptionInternal: totals.InternalInterruptions,
+		model.InterruptionExternal: totals.ExternalInterruptions,
+	} {
+		if count > 0 {
+			metrics.ByKind = append(metrics.ByKind, KindCount{Kind: kind, Count: count})
+		}
+	}
+	sort.Slice(metrics.ByKind, func(i, j int) bool {
+		if metrics.ByKind[i].Count != metrics.ByKind[j].Count {
+			return metrics.ByKind[i].Count > metrics.ByKind[j].Count
+		}
+		return metrics.ByKind[i].Kind < metrics.ByKind[j].Kind
+	})
+
+	for _, occurredAt := range occurrences {
+		metrics.ByHour[occurredAt.In(location).Hour()]++
+	}
+
+	if timedFocusMinutes := totals.FocusMinutes - totals.ManualFocusMinutes; timedFocusMinutes > 0 {
+		metrics.PerHour = float64(metrics.Total) / (timedFocusMinutes / 60)
+	}
+
+	return metrics
+}
+
+// summarizeSubjects compares the focus time spent on each subject with its
+// weekly target. Sessions without a subject, or whose subject is not in the
+// given list, are counted as unassigned.
+func summarizeSubjects(subjects []model.Subject, sessions []model.PomodoroSession) WeeklySubjectMetrics {
+	metrics := WeeklySubjectMetrics{Subjects: make([]SubjectMetrics, 0, len(subjects))}
+
+	minutesBySubject := map[uint]float64{}
+	for _, session := range sessions {
+		if session.SubjectID == nil {
+			metrics.UnassignedMinutes += session.Duration().Minutes()
+			continue
+		}
+		minutesBySubject[*session.SubjectID] += session.Duration().Minutes()
+	}
+
+	for _, subject := range subjects {
+		subjectMetrics := SubjectMetrics{
+			SubjectID:     subject.ID,
+			Name:          subject.Name,
+			Color:         subject.Color,
+			FocusMinutes:  minutesBySubject[subject.ID],
+			TargetMinutes: subject.WeeklyTargetHours * 60,
+		}
+		if subjectMetrics.TargetMinutes > 0 {
+			subjectMetrics.Progress = subjectMetrics.FocusMinutes / subjectMetrics.TargetMinutes
+		}
+		metrics.Subjects = append(metrics.Subjects, subjectMetrics)
+		delete(minutesBySubject, subject.ID)
+	}
+
+	for _, minutes := range minutesBySubject {
+		metrics.UnassignedMinutes += minutes
+	}
+
+	return metrics
+}
+
+// touchRollups refreshes the rollups of the days affected by a write. Errors
+// are only logged since the write itself succeeded; days that were already
+// marked as pending are retried by the catch-up worker.
+func touchRollups(db *gorm.DB, userID string, times ...time.Time) {
+	if err := rollup.Touch(db, userID, times...); err != nil {
+		slog.Error("Failed to refresh rollups", slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
 }
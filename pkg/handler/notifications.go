@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/notify"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type NotificationPreferencesPayload struct {
+	Channels        []string `json:"channels"`
+	WebhookURL      string   `json:"webhook_url"`
+	QuietHoursStart *int     `json:"quiet_hours_start"`
+	QuietHoursEnd   *int     `json:"quiet_hours_end"`
+}
+
+type NotificationList struct {
+	Notifications []model.Notification `json:"notifications"`
+	Page          int                  `json:"page"`
+	PageSize      int                  `json:"page_size"`
+	Total         int64                `json:"total"`
+}
+
+type UnreadCount struct {
+	Unread int64 `json:"unread"`
+}
+
+func GetAllNotifications(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	isPaginationValid, paginationValFeedback, page, pageSize := utils.ValidatePagination(c.Query("page"), c.Query("page_size"))
+	if !isPaginationValid {
+		return response.BadRequest(c, paginationValFeedback)
+	}
+
+	query := db.Model(&model.Notification{}).Where("user_id = ?", userID)
+	if c.QueryBool("unread") {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve notifications.")
+	}
+
+	notifications := []model.Notification{}
+	result := query.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&notifications)
+	if result.Error != nil {
+		return response.InternalServerError(c, "Failed to retrieve notifications.")
+	}
+
+	return response.Ok(c, "Successfully retrieved notifications", NotificationList{
+		Notifications: notifications,
+		Page:          page,
+		PageSize:      pageSize,
+		Total:         total,
+	})
+}
+
+// GetUnreadNotificationCount is meant to be polled, so it is served by a
+// partial index on unread notifications.
+func GetUnreadNotificationCount(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	var count UnreadCount
+	err := db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count.Unread).Error
+	if err != nil {
+		return response.InternalServerError(c, "Failed to count notifications.")
+	}
+
+	return response.Ok(c, "Successfully counted unread notifications", count)
+}
+
+func MarkNotificationRead(c *fiber.Ctx) error {
+	return setNotificationRead(c, true)
+}
+
+func MarkNotificationUnread(c *fiber.Ctx) error {
+	return setNotificationRead(c, false)
+}
+
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	err := db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now().UTC()).Error
+	if err != nil {
+		return response.InternalServerError(c, "Failed to update notifications.")
+	}
+
+	return response.Ok(c, "Successfully marked all notifications as read")
+}
+
+func DeleteNotification(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	result := db.Where("user_id = ?", userID).Delete(&model.Notification{}, id)
+	if result.Error != nil {
+		return response.InternalServerError(c, "Failed to delete notification.")
+	}
+
+	if result.RowsAffected == 0 {
+		return response.NotFound(c, "Notification not found")
+	}
+
+	return response.Ok(c, "Successfully deleted notification.")
+}
+
+func GetNotificationPreferences(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	prefs, err := notify.LoadPreferences(db, userID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve notification preferences.")
+	}
+
+	return response.Ok(c, "Successfully retrieved notification preferences", prefs)
+}
+
+func UpdateNotificationPreferences(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	requestPayload := NotificationPreferencesPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	if len(requestPayload.Channels) == 0 {
+		return response.BadRequest(c, "At least one channel must be enabled")
+	}
+
+	channels := make([]string, 0, len(requestPayload.Channels))
+	seen := map[string]bool{}
+	for _, channel := range requestPayload.Channels {
+		if !model.IsValidChannel(channel) {
+			return response.BadRequest(c, "Channels must be any of 'email', 'webhook' and 'in_app'")
+		}
+		if !seen[channel] {
+			seen[channel] = true
+			channels = append(channels, channel)
+		}
+	}
+
+	if seen[model.ChannelWebhook] || requestPayload.WebhookURL != "" {
+		isURLValid, urlValFeedback := utils.ValidateWebhookURL(requestPayload.WebhookURL)
+		if !isURLValid {
+			return response.BadRequest(c, urlValFeedback)
+		}
+	}
+
+	if (requestPayload.QuietHoursStart == nil) != (requestPayload.QuietHoursEnd == nil) {
+		return response.BadRequest(c, "Quiet hours need both a start and an end")
+	}
+	for _, hour := range []*int{requestPayload.QuietHoursStart, requestPayload.QuietHoursEnd} {
+		if hour != nil && (*hour < 0 || *hour > 23) {
+			return response.BadRequest(c, "Quiet hours must be between 0 and 23")
+		}
+	}
+
+	prefs := model.NotificationPreferences{
+		UserID:          userID,
+		Channels:        strings.Join(channels, ","),
+		WebhookURL:      requestPayload.WebhookURL,
+		QuietHoursStart: requestPayload.QuietHoursStart,
+		QuietHoursEnd:   requestPayload.QuietHoursEnd,
+	}
+
+	if err := db.Save(&prefs).Error; err != nil {
+		return response.InternalServerError(c, "Failed to update notification preferences.")
+	}
+
+	return response.Ok(c, "Successfully updated notification preferences", prefs)
+}
+
+func setNotificationRead(c *fiber.Ctx, read bool) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	var notification model.Notification
+	result := db.Where("user_id = ?", userID).First(&notification, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Notification not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve notification.")
+	}
+
+	if read && notification.ReadAt == nil {
+		readAt := time.Now().UTC()
+		notification.ReadAt = &readAt
+	} else if !read {
+		notification.ReadAt = nil
+	}
+
+	if err := db.Save(&notification).Error; err != nil {
+		return response.InternalServerError(c, "Failed to update notification.")
+	}
+
+	return response.Ok(c, "Successfully updated notification", notification)
+}
@@ -1,14 +1,446 @@
 package handler
 
 import (
+	"errors"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/outbox"
 	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/utils"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
+var errOverlappingSession = errors.New("session overlaps an existing session")
+
+type SessionPayload struct {
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	SubjectID *uint  `json:"subject_id"`
+}
+
+type StartPomodoroPayload struct {
+	SubjectID *uint `json:"subject_id"`
+}
+
+type SessionList struct {
+	Sessions []model.PomodoroSession `json:"sessions"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"page_size"`
+	Total    int64                   `json:"total"`
+}
+
+type InterruptionPayload struct {
+	Kind       string `json:"kind"`
+	Note       string `json:"note"`
+	OccurredAt string `json:"occurred_at"`
+}
+
 func StartPomodoro(c *fiber.Ctx) error {
-	return response.Ok(c, "")
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	requestPayload := StartPomodoroPayload{}
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&requestPayload); err != nil {
+			return response.BadRequest(c, "Invalid request payload")
+		}
+	}
+
+	ownsRequestedSubject, err := ownsSubject(db, userID, requestPayload.SubjectID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve subject.")
+	}
+	if !ownsRequestedSubject {
+		return response.BadRequest(c, "Subject not found")
+	}
+
+	session := model.PomodoroSession{
+		StartTime: time.Now().UTC(),
+		SubjectID: requestPayload.SubjectID,
+		UserID:    userID,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := checkSessionOverlap(tx, session); err != nil {
+			return err
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, userID, outbox.EventPomodoroStarted, session)
+	})
+	if errors.Is(err, errOverlappingSession) {
+		return response.BadRequest(c, "A Pomodoro session is already running")
+	}
+	if err != nil {
+		return response.InternalServerError(c, "Failed to start Pomodoro session.")
+	}
+
+	return response.Created(c, "Successfully started Pomodoro session.", session)
 }
 
 func StopPomodoro(c *fiber.Ctx) error {
-	return response.Ok(c, "")
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return stopPomodoro(c, db, userID)
+}
+
+func stopPomodoro(c *fiber.Ctx, db *gorm.DB, userID string) error {
+	var session model.PomodoroSession
+	result := db.Where("user_id = ? AND end_time IS NULL", userID).First(&session)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "No running Pomodoro session")
+		}
+		return response.InternalServerError(c, "Failed to retrieve Pomodoro session.")
+	}
+
+	now := time.Now().UTC()
+	session.EndTime = &now
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&session).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, userID, outbox.EventPomodoroStopped, session)
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to stop Pomodoro session.")
+	}
+
+	touchRollups(db, userID, session.StartTime)
+
+	return response.Ok(c, "Successfully stopped Pomodoro session", session)
+}
+
+func GetActivePomodoro(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	var session model.PomodoroSession
+	result := db.Where("user_id = ? AND end_time IS NULL", userID).First(&session)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "No running Pomodoro session")
+		}
+		return response.InternalServerError(c, "Failed to retrieve Pomodoro session.")
+	}
+
+	return response.Ok(c, "Successfully retrieved running Pomodoro session", session)
+}
+
+func LogInterruption(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	requestPayload := InterruptionPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	if !model.IsValidInterruptionKind(requestPayload.Kind) {
+		return response.BadRequest(c, "Interruption kind must be either 'internal' or 'external'")
+	}
+
+	occurredAt := time.Now().UTC()
+	if requestPayload.OccurredAt != "" {
+		isTimeValid, timeValFeedback, parsed := utils.ValidateTime(requestPayload.OccurredAt)
+		if !isTimeValid {
+			return response.BadRequest(c, timeValFeedback)
+		}
+		occurredAt = parsed.UTC()
+	}
+
+	var session model.PomodoroSession
+	result := db.Where("user_id = ? AND end_time IS NULL", userID).First(&session)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "No running Pomodoro session")
+		}
+		return response.InternalServerError(c, "Failed to retrieve Pomodoro session.")
+	}
+
+	if occurredAt.Before(session.StartTime) || occurredAt.After(time.Now()) {
+		return response.BadRequest(c, "Interruption must occur during the running session")
+	}
+
+	interruption := model.Interruption{
+		SessionID:  session.ID,
+		OccurredAt: occurredAt,
+		Kind:       requestPayload.Kind,
+		Note:       requestPayload.Note,
+		UserID:     userID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&interruption).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, userID, outbox.EventPomodoroInterrupted, interruption)
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to log interruption.")
+	}
+
+	return response.Created(c, "Successfully logged interruption.", interruption)
+}
+
+func CreateSession(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	requestPayload := SessionPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	isRangeValid, rangeValFeedback, start, end := utils.ValidateTimeRange(requestPayload.StartTime, requestPayload.EndTime)
+	if !isRangeValid {
+		return response.BadRequest(c, rangeValFeedback)
+	}
+
+	if end.After(time.Now()) {
+		return response.BadRequest(c, "Manually logged sessions must have ended already")
+	}
+
+	ownsRequestedSubject, err := ownsSubject(db, userID, requestPayload.SubjectID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve subject.")
+	}
+	if !ownsRequestedSubject {
+		return response.BadRequest(c, "Subject not found")
+	}
+
+	end = end.UTC()
+	session := model.PomodoroSession{
+		StartTime: start.UTC(),
+		EndTime:   &end,
+		IsManual:  true,
+		SubjectID: requestPayload.SubjectID,
+		UserID:    userID,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := checkSessionOverlap(tx, session); err != nil {
+			return err
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, userID, outbox.EventSessionCreated, session)
+	})
+	if errors.Is(err, errOverlappingSession) {
+		return response.BadRequest(c, "Session overlaps an existing session")
+	}
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create session.")
+	}
+
+	touchRollups(db, userID, session.StartTime)
+
+	return response.Created(c, "Successfully created session.", session)
+}
+
+func GetAllSessions(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	isPaginationValid, paginationValFeedback, page, pageSize := utils.ValidatePagination(c.Query("page"), c.Query("page_size"))
+	if !isPaginationValid {
+		return response.BadRequest(c, paginationValFeedback)
+	}
+
+	query := db.Model(&model.PomodoroSession{}).Where("user_id = ?", userID)
+
+	if from := c.Query("from"); from != "" {
+		isFromValid, _, fromTime := utils.ValidateTime(from)
+		if !isFromValid {
+			return response.BadRequest(c, "From time format is invalid")
+		}
+		query = query.Where("start_time >= ?", fromTime.UTC())
+	}
+
+	if to := c.Query("to"); to != "" {
+		isToValid, _, toTime := utils.ValidateTime(to)
+		if !isToValid {
+			return response.BadRequest(c, "To time format is invalid")
+		}
+		query = query.Where("start_time < ?", toTime.UTC())
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve sessions.")
+	}
+
+	sessions := []model.PomodoroSession{}
+	result := query.Order("start_time DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&sessions)
+	if result.Error != nil {
+		return response.InternalServerError(c, "Failed to retrieve sessions.")
+	}
+
+	return response.Ok(c, "Successfully retrieved sessions", SessionList{
+		Sessions: sessions,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	})
+}
+
+func UpdateSession(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	requestPayload := SessionPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	isRangeValid, rangeValFeedback, start, end := utils.ValidateTimeRange(requestPayload.StartTime, requestPayload.EndTime)
+	if !isRangeValid {
+		return response.BadRequest(c, rangeValFeedback)
+	}
+
+	if end.After(time.Now()) {
+		return response.BadRequest(c, "Only past sessions can be edited")
+	}
+
+	ownsRequestedSubject, err := ownsSubject(db, userID, requestPayload.SubjectID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve subject.")
+	}
+	if !ownsRequestedSubject {
+		return response.BadRequest(c, "Subject not found")
+	}
+
+	var session model.PomodoroSession
+	var previousStart time.Time
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND end_time IS NOT NULL", userID).First(&session, id).Error; err != nil {
+			return err
+		}
+		previousStart = session.StartTime
+
+		end = end.UTC()
+		session.StartTime = start.UTC()
+		session.EndTime = &end
+		session.SubjectID = requestPayload.SubjectID
+
+		if err := checkSessionOverlap(tx, session); err != nil {
+			return err
+		}
+		if err := tx.Save(&session).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, userID, outbox.EventSessionUpdated, session)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.NotFound(c, "Session not found")
+	}
+	if errors.Is(err, errOverlappingSession) {
+		return response.BadRequest(c, "Session overlaps an existing session")
+	}
+	if err != nil {
+		return response.InternalServerError(c, "Failed to update session.")
+	}
+
+	touchRollups(db, userID, previousStart, session.StartTime)
+
+	return response.Ok(c, "Successfully updated session", session)
+}
+
+func DeleteSession(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	var session model.PomodoroSession
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).First(&session, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", session.ID).Delete(&model.Interruption{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&session).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, userID, outbox.EventSessionDeleted, session)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.NotFound(c, "Session not found")
+	}
+	if err != nil {
+		return response.InternalServerError(c, "Failed to delete session.")
+	}
+
+	touchRollups(db, userID, session.StartTime)
+
+	return response.Ok(c, "Successfully deleted session.")
+}
+
+// checkSessionOverlap returns errOverlappingSession if the user already has a
+// session intersecting the given one. Running sessions, and the session being
+// checked if it has no end time yet, are treated as open-ended. It takes a
+// per-user advisory lock for the rest of the transaction so that concurrent
+// writes cannot both pass.
+func checkSessionOverlap(tx *gorm.DB, session model.PomodoroSession) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", session.UserID).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	query := tx.Model(&model.PomodoroSession{}).
+		Where("user_id = ?", session.UserID).
+		Where("(end_time IS NULL OR end_time > ?)", session.StartTime)
+	if session.EndTime != nil {
+		query = query.Where("start_time < ?", *session.EndTime)
+	}
+	if session.ID != 0 {
+		query = query.Where("id <> ?", session.ID)
+	}
+
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errOverlappingSession
+	}
+
+	return nil
 }
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/notify"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	maxRemindersPerTask   = 5
+	maxReminderOffsetDays = 30
+)
+
+type RemindersPayload struct {
+	OffsetsMinutes []int `json:"offsets_minutes"`
+}
+
+func GetTaskReminders(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	var task model.Task
+	result := db.Where("user_id = ?", userID).First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Task not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve task.")
+	}
+
+	reminders := []model.Reminder{}
+	if err := db.Where("task_id = ?", task.ID).Order("fire_at").Find(&reminders).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve reminders.")
+	}
+
+	return response.Ok(c, "Successfully retrieved reminders", reminders)
+}
+
+func SetTaskReminders(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	requestPayload := RemindersPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	if len(requestPayload.OffsetsMinutes) > maxRemindersPerTask {
+		return response.BadRequest(c, "A task can have at most 5 reminders")
+	}
+
+	seen := map[int]bool{}
+	for _, offset := range requestPayload.OffsetsMinutes {
+		if offset <= 0 || offset > maxReminderOffsetDays*24*60 {
+			return response.BadRequest(c, "Reminder offsets must be between 1 minute and 30 days")
+		}
+		if seen[offset] {
+			return response.BadRequest(c, "Reminder offsets must be unique")
+		}
+		seen[offset] = true
+	}
+
+	var task model.Task
+	result := db.Where("user_id = ?", userID).First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Task not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve task.")
+	}
+
+	reminders, err := notify.SetReminders(db, task, requestPayload.OffsetsMinutes, time.Now())
+	if err != nil {
+		return response.InternalServerError(c, "Failed to update reminders.")
+	}
+
+	return response.Ok(c, "Successfully updated reminders", reminders)
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/report"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func PreviewWeeklyReport(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	location, err := userLocation(db, userID, c.Query("tz"))
+	if err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	weekly, err := report.Build(db, userID, time.Now(), location)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to build weekly report.")
+	}
+
+	format := c.Query("format", "json")
+	if format == "json" {
+		return response.Ok(c, "Successfully built weekly report", weekly)
+	}
+
+	msg, err := report.Render(weekly)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to render weekly report.")
+	}
+
+	switch format {
+	case "html":
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.SendString(msg.HTML)
+	case "text":
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendString(msg.Text)
+	default:
+		return response.BadRequest(c, "Format must be one of 'json', 'html' or 'text'")
+	}
+}
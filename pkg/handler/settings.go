@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/rollup"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SettingsPayload updates the settings that are present and leaves the
+// others as they are.
+type SettingsPayload struct {
+	TimeZone            *string `json:"time_zone"`
+	WeeklyReportEnabled *bool   `json:"weekly_report_enabled"`
+	WeeklyReportDay     *string `json:"weekly_report_day"`
+	WeeklyReportHour    *int    `json:"weekly_report_hour"`
+}
+
+func GetSettings(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	settings := defaultSettings(userID)
+	err := db.Where("user_id = ?", userID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.InternalServerError(c, "Failed to retrieve settings.")
+	}
+
+	return response.Ok(c, "Successfully retrieved settings", settings)
+}
+
+func UpdateSettings(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	requestPayload := SettingsPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	var location *time.Location
+	if requestPayload.TimeZone != nil {
+		isTimeZoneValid, timeZoneValFeedback, parsed := utils.ValidateTimeZone(*requestPayload.TimeZone)
+		if !isTimeZoneValid {
+			return response.BadRequest(c, timeZoneValFeedback)
+		}
+		location = parsed
+	}
+
+	var reportDay string
+	if requestPayload.WeeklyReportDay != nil && *requestPayload.WeeklyReportDay != "" {
+		day, isWeekday := model.ParseWeekday(*requestPayload.WeeklyReportDay)
+		if !isWeekday {
+			return response.BadRequest(c, "Weekly report day must be a weekday name such as 'sunday'")
+		}
+		reportDay = strings.ToLower(day.String())
+	}
+
+	if requestPayload.WeeklyReportHour != nil {
+		if reportHour := *requestPayload.WeeklyReportHour; reportHour < 0 || reportHour > 23 {
+			return response.BadRequest(c, "Weekly report hour must be between 0 and 23")
+		}
+	}
+
+	settings := defaultSettings(userID)
+	err := db.Where("user_id = ?", userID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.InternalServerError(c, "Failed to retrieve settings.")
+	}
+
+	timeZoneChanged := location != nil && settings.TimeZone != location.String()
+	if location != nil {
+		settings.TimeZone = location.String()
+	}
+	if requestPayload.WeeklyReportEnabled != nil {
+		settings.WeeklyReportEnabled = *requestPayload.WeeklyReportEnabled
+	}
+	if reportDay != "" {
+		settings.WeeklyReportDay = reportDay
+	}
+	if requestPayload.WeeklyReportHour != nil {
+		settings.WeeklyReportHour = *requestPayload.WeeklyReportHour
+	}
+
+	// Rollups are bucketed by local day, so they have to be recomputed in
+	// the new time zone. That can take a while for long histories, so the
+	// days are only marked here, along with the change itself, and the
+	// rollup worker recomputes them.
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&settings).Error; err != nil {
+			return err
+		}
+		if timeZoneChanged {
+			return rollup.Invalidate(tx, userID, time.Time{}, time.Time{})
+		}
+		return nil
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to update settings.")
+	}
+
+	return response.Ok(c, "Successfully updated settings", settings)
+}
+
+func defaultSettings(userID string) model.UserSettings {
+	return model.UserSettings{
+		UserID:           userID,
+		TimeZone:         "UTC",
+		WeeklyReportDay:  "sunday",
+		WeeklyReportHour: 18,
+	}
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxWeeklyTargetHours = 7 * 24
+
+type SubjectPayload struct {
+	Name              string  `json:"name"`
+	Color             string  `json:"color"`
+	TermStart         string  `json:"term_start"`
+	TermEnd           string  `json:"term_end"`
+	WeeklyTargetHours float64 `json:"weekly_target_hours"`
+}
+
+func CreateSubject(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	requestPayload := SubjectPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	subject := model.Subject{UserID: userID}
+	if isValid, feedback := applySubjectPayload(&subject, requestPayload); !isValid {
+		return response.BadRequest(c, feedback)
+	}
+
+	if err := db.Create(&subject).Error; err != nil {
+		return response.InternalServerError(c, "Failed to create subject.")
+	}
+
+	return response.Created(c, "Successfully created subject.", subject)
+}
+
+func GetAllSubjects(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	subjects := []model.Subject{}
+	if err := db.Where("user_id = ?", userID).Order("name").Find(&subjects).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve subjects.")
+	}
+
+	return response.Ok(c, "Successfully retrieved subjects", subjects)
+}
+
+func GetSubject(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	var subject model.Subject
+	result := db.Where("user_id = ?", userID).First(&subject, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Subject not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve subject.")
+	}
+
+	return response.Ok(c, "Successfully retrieved subject", subject)
+}
+
+func UpdateSubject(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	requestPayload := SubjectPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	var subject model.Subject
+	result := db.Where("user_id = ?", userID).First(&subject, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Subject not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve subject.")
+	}
+
+	if isValid, feedback := applySubjectPayload(&subject, requestPayload); !isValid {
+		return response.BadRequest(c, feedback)
+	}
+
+	if err := db.Save(&subject).Error; err != nil {
+		return response.InternalServerError(c, "Failed to update subject.")
+	}
+
+	return response.Ok(c, "Successfully updated subject", subject)
+}
+
+func DeleteSubject(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var subject model.Subject
+		if err := tx.Where("user_id = ?", userID).First(&subject, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Task{}).Where("subject_id = ?", subject.ID).Update("subject_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.PomodoroSession{}).Where("subject_id = ?", subject.ID).Update("subject_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&subject).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.NotFound(c, "Subject not found")
+	}
+	if err != nil {
+		return response.InternalServerError(c, "Failed to delete subject.")
+	}
+
+	return response.Ok(c, "Successfully deleted subject.")
+}
+
+func applySubjectPayload(subject *model.Subject, payload SubjectPayload) (bool, string) {
+	if payload.Name == "" || len(payload.Name) > 100 {
+		return false, "Name must be between 1 and 100 characters"
+	}
+
+	if payload.Color != "" {
+		if isColorValid, colorValFeedback := utils.ValidateColor(payload.Color); !isColorValid {
+			return false, colorValFeedback
+		}
+	}
+
+	if payload.WeeklyTargetHours < 0 || payload.WeeklyTargetHours > maxWeeklyTargetHours {
+		return false, "Weekly target hours must be between 0 and 168"
+	}
+
+	var termStart, termEnd *time.Time
+	if payload.TermStart != "" {
+		isTimeValid, _, parsed := utils.ValidateTime(payload.TermStart)
+		if !isTimeValid {
+			return false, "Term start format is invalid"
+		}
+		parsed = parsed.UTC()
+		termStart = &parsed
+	}
+	if payload.TermEnd != "" {
+		isTimeValid, _, parsed := utils.ValidateTime(payload.TermEnd)
+		if !isTimeValid {
+			return false, "Term end format is invalid"
+		}
+		parsed = parsed.UTC()
+		termEnd = &parsed
+	}
+	if termStart != nil && termEnd != nil && !termEnd.After(*termStart) {
+		return false, "Term end must be after term start"
+	}
+
+	subject.Name = payload.Name
+	subject.Color = payload.Color
+	subject.TermStart = termStart
+	subject.TermEnd = termEnd
+	subject.WeeklyTargetHours = payload.WeeklyTargetHours
+
+	return true, "Subject is valid"
+}
+
+// ownsSubject reports whether the subject referenced by id belongs to the
+// given user. A nil id means no subject and is always allowed.
+func ownsSubject(db *gorm.DB, userID string, id *uint) (bool, error) {
+	if id == nil {
+		return true, nil
+	}
+
+	var count int64
+	if err := db.Model(&model.Subject{}).Where("id = ? AND user_id = ?", *id, userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
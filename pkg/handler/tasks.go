@@ -3,12 +3,14 @@ package handler
 import (
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/notify"
+	"github.com/abyan-dev/productivity/pkg/outbox"
 	"github.com/abyan-dev/productivity/pkg/response"
 	"github.com/abyan-dev/productivity/pkg/utils"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
 
@@ -16,6 +18,7 @@ type CreateTaskPayload struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	DueDate     string `json:"due_date"`
+	SubjectID   *uint  `json:"subject_id"`
 }
 
 type UpdateTaskPayload struct {
@@ -23,92 +26,88 @@ type UpdateTaskPayload struct {
 	Description string `json:"description"`
 	DueDate     string `json:"due_date"`
 	IsComplete  bool   `json:"is_complete"`
+	SubjectID   *uint  `json:"subject_id"`
 }
 
 func CreateTask(c *fiber.Ctx) error {
 	db := c.Locals("db").(*gorm.DB)
-	claims, ok := c.Locals("user").(jwt.MapClaims)
+	userID, ok := callerID(c)
 	if !ok {
 		return response.Unauthorized(c, "Invalid user claims")
 	}
 
-	email, emailOk := claims["email"].(string)
-	if !emailOk {
-		return response.Unauthorized(c, "Invalid email claim")
-	}
-
 	requestPayload := CreateTaskPayload{}
 
 	if err := c.BodyParser(&requestPayload); err != nil {
 		return response.BadRequest(c, "Invalid request payload")
 	}
 
-	isEmailValid, emailVadFeedback := utils.ValidateEmail(email)
-	if !isEmailValid {
-		return response.BadRequest(c, emailVadFeedback)
-	}
-
 	isDueDateValid, dueDateValFeedback, dueDate := utils.ValidateTime(requestPayload.DueDate)
 	if !isDueDateValid {
 		return response.BadRequest(c, dueDateValFeedback)
 	}
 
+	ownsRequestedSubject, err := ownsSubject(db, userID, requestPayload.SubjectID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve subject.")
+	}
+	if !ownsRequestedSubject {
+		return response.BadRequest(c, "Subject not found")
+	}
+
 	task := model.Task{
 		Title:       requestPayload.Title,
 		Description: requestPayload.Description,
 		DueDate:     dueDate,
 		IsComplete:  false,
-		UserEmail:   email,
+		SubjectID:   requestPayload.SubjectID,
+		UserID:      userID,
 	}
 
-	if err := db.Create(&task).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, userID, outbox.EventTaskCreated, task)
+	})
+	if err != nil {
 		return response.InternalServerError(c, "Failed to create task.")
 	}
 
+	touchRollups(db, userID, task.CreatedAt)
+
 	return response.Created(c, "Successfully created task.")
 }
 
 func GetAllTasks(c *fiber.Ctx) error {
 	db := c.Locals("db").(*gorm.DB)
-	claims, ok := c.Locals("user").(jwt.MapClaims)
+	userID, ok := callerID(c)
 	if !ok {
-		slog.Error("Invalid user claims")
 		return response.Unauthorized(c, "Invalid user claims")
 	}
 
-	email, emailOk := claims["email"].(string)
-	if !emailOk {
-		slog.Error("Invalid email claim", "claims", claims)
-		return response.Unauthorized(c, "Invalid email claim")
-	}
-
-	slog.Debug("Email claim extracted", slog.String("email", email))
-
-	isEmailValid, emailValFeedback := utils.ValidateEmail(email)
-	if !isEmailValid {
-		slog.Error("Email validation failed", slog.String("email", email), slog.String("feedback", emailValFeedback))
-		return response.BadRequest(c, emailValFeedback)
-	}
-
-	slog.Debug("Email validation succeeded", slog.String("email", email))
-
 	var tasks []model.Task
-	result := db.Where("user_email = ?", email).Find(&tasks)
+	result := db.Where("user_id = ?", userID).Find(&tasks)
 	if result.Error != nil {
-		slog.Error("Failed to retrieve tasks", slog.String("email", email), slog.String("error", result.Error.Error()))
+		slog.Error("Failed to retrieve tasks", slog.String("user_id", userID), slog.String("error", result.Error.Error()))
 		return response.InternalServerError(c, "Failed to retrieve tasks.")
 	}
 
-	slog.Debug("Tasks retrieved successfully", slog.String("email", email), slog.Int("task_count", len(tasks)))
-	return response.Ok(c, fmt.Sprintf("Successfully retrieved all tasks for user %s", email), tasks)
+	slog.Debug("Tasks retrieved successfully", slog.String("user_id", userID), slog.Int("task_count", len(tasks)))
+	return response.Ok(c, fmt.Sprintf("Successfully retrieved all tasks for user %s", userID), tasks)
 }
 
 func GetTask(c *fiber.Ctx) error {
 	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
 	id := c.Params("id")
 
 	var task model.Task
-	result := db.First(&task, id)
+	result := db.Where("user_id = ?", userID).First(&task, id)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return response.NotFound(c, "Task not found")
@@ -121,6 +120,11 @@ func GetTask(c *fiber.Ctx) error {
 
 func UpdateTask(c *fiber.Ctx) error {
 	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
 	id := c.Params("id")
 
 	requestPayload := UpdateTaskPayload{}
@@ -134,8 +138,16 @@ func UpdateTask(c *fiber.Ctx) error {
 		return response.BadRequest(c, dueDateValFeedback)
 	}
 
+	ownsRequestedSubject, err := ownsSubject(db, userID, requestPayload.SubjectID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to retrieve subject.")
+	}
+	if !ownsRequestedSubject {
+		return response.BadRequest(c, "Subject not found")
+	}
+
 	var task model.Task
-	result := db.First(&task, id)
+	result := db.Where("user_id = ?", userID).First(&task, id)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return response.NotFound(c, "Task not found")
@@ -143,28 +155,97 @@ func UpdateTask(c *fiber.Ctx) error {
 		return response.InternalServerError(c, "Failed to retrieve task.")
 	}
 
+	dueDateChanged := !task.DueDate.Equal(dueDate)
+	completedNow := requestPayload.IsComplete && !task.IsComplete
+
 	task.Title = requestPayload.Title
 	task.Description = requestPayload.Description
 	task.DueDate = dueDate
+	task.SubjectID = requestPayload.SubjectID
+	if dueDateChanged {
+		task.OverdueNotifiedAt = nil
+	}
+
+	var previousCompletedAt time.Time
+	if task.CompletedAt != nil {
+		previousCompletedAt = *task.CompletedAt
+	}
+
+	if completedNow {
+		completedAt := time.Now().UTC()
+		task.CompletedAt = &completedAt
+	} else if !requestPayload.IsComplete {
+		task.CompletedAt = nil
+	}
 	task.IsComplete = requestPayload.IsComplete
 
-	db.Save(&task)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&task).Error; err != nil {
+			return err
+		}
+		if err := outbox.Record(tx, task.UserID, outbox.EventTaskUpdated, task); err != nil {
+			return err
+		}
+		if completedNow {
+			return outbox.Record(tx, task.UserID, outbox.EventTaskCompleted, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to update task.")
+	}
+
+	touched := []time.Time{previousCompletedAt}
+	if task.CompletedAt != nil {
+		touched = append(touched, *task.CompletedAt)
+	}
+	touchRollups(db, task.UserID, touched...)
+
+	if dueDateChanged {
+		if err := notify.RescheduleReminders(db, task, time.Now()); err != nil {
+			slog.Error("Failed to reschedule reminders", slog.Uint64("task_id", uint64(task.ID)), slog.String("error", err.Error()))
+		}
+	}
 
 	return response.Ok(c, "Successfully updated task", task)
 }
 
 func DeleteTask(c *fiber.Ctx) error {
 	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
 	id := c.Params("id")
 
-	result := db.Delete(&model.Task{}, id)
+	var task model.Task
+	result := db.Where("user_id = ?", userID).First(&task, id)
 	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Task not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve task.")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", task.ID).Delete(&model.Reminder{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&task).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, task.UserID, outbox.EventTaskDeleted, task)
+	})
+	if err != nil {
 		return response.InternalServerError(c, "Failed to delete task.")
 	}
 
-	if result.RowsAffected == 0 {
-		return response.NotFound(c, "Task not found")
+	touched := []time.Time{task.CreatedAt}
+	if task.CompletedAt != nil {
+		touched = append(touched, *task.CompletedAt)
 	}
+	touchRollups(db, task.UserID, touched...)
 
 	return response.Ok(c, "Successfully deleted task.")
 }
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/rbac"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const maxPersonalAccessTokensPerUser = 20
+
+type PersonalAccessTokenPayload struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+// CreatedPersonalAccessToken is the only response that includes the token.
+type CreatedPersonalAccessToken struct {
+	model.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// CreatePersonalAccessToken creates a token limited to the given scopes,
+// each of which must be a permission of the caller's role. Tokens can only
+// be created by signing in, not with another token.
+func CreatePersonalAccessToken(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	if madeWithToken(c) {
+		return response.Forbidden(c, "Personal access tokens cannot create other tokens")
+	}
+
+	claims := c.Locals("user").(jwt.MapClaims)
+	role, _ := claims["role"].(string)
+
+	requestPayload := PersonalAccessTokenPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	if requestPayload.Name == "" || len(requestPayload.Name) > 100 {
+		return response.BadRequest(c, "Name must be between 1 and 100 characters")
+	}
+
+	if len(requestPayload.Scopes) == 0 {
+		return response.BadRequest(c, "At least one scope is required")
+	}
+	scopes := []string{}
+	for _, name := range requestPayload.Scopes {
+		scope := rbac.ParsePermission(name)
+		if !rbac.Can(role, scope) {
+			return response.BadRequest(c, "Scope "+name+" is unknown or not granted to your role")
+		}
+		if !slices.Contains(scopes, string(scope)) {
+			scopes = append(scopes, string(scope))
+		}
+	}
+
+	now := time.Now().UTC()
+
+	var expiresAt *time.Time
+	if requestPayload.ExpiresAt != "" {
+		isTimeValid, timeValFeedback, parsed := utils.ValidateTime(requestPayload.ExpiresAt)
+		if !isTimeValid {
+			return response.BadRequest(c, timeValFeedback)
+		}
+		parsed = parsed.UTC()
+		if !parsed.After(now) {
+			return response.BadRequest(c, "Expiry must be in the future")
+		}
+		expiresAt = &parsed
+	}
+
+	var count int64
+	if err := db.Model(&model.PersonalAccessToken{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve personal access tokens.")
+	}
+	if count >= maxPersonalAccessTokensPerUser {
+		return response.BadRequest(c, "A user can have at most 20 personal access tokens")
+	}
+
+	token, tokenHash, err := utils.NewSecretToken(model.PersonalAccessTokenPrefix)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create personal access token.")
+	}
+
+	pat := model.PersonalAccessToken{
+		Name:      requestPayload.Name,
+		TokenHash: tokenHash,
+		Scopes:    strings.Join(scopes, " "),
+		Role:      role,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+		UserID:    userID,
+	}
+
+	if err := db.Create(&pat).Error; err != nil {
+		return response.InternalServerError(c, "Failed to create personal access token.")
+	}
+
+	return response.Created(c, "Successfully created personal access token.", CreatedPersonalAccessToken{PersonalAccessToken: pat, Token: token})
+}
+
+func GetAllPersonalAccessTokens(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	pats := []model.PersonalAccessToken{}
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&pats).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve personal access tokens.")
+	}
+
+	return response.Ok(c, "Successfully retrieved personal access tokens", pats)
+}
+
+// DeletePersonalAccessToken revokes a token, effective immediately.
+func DeletePersonalAccessToken(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	id := c.Params("id")
+
+	result := db.Where("user_id = ?", userID).Delete(&model.PersonalAccessToken{}, id)
+	if result.Error != nil {
+		return response.InternalServerError(c, "Failed to delete personal access token.")
+	}
+
+	if result.RowsAffected == 0 {
+		return response.NotFound(c, "Personal access token not found")
+	}
+
+	return response.Ok(c, "Successfully deleted personal access token.")
+}
+
+// madeWithToken reports whether the request was authenticated with a
+// personal access token. Such requests cannot create other long-lived
+// credentials, which would escape the token's scopes and expiry.
+func madeWithToken(c *fiber.Ctx) bool {
+	_, isToken := c.Locals("scopes").([]rbac.Permission)
+	return isToken
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// callerID returns the ID of the authenticated caller, by which their data is
+// keyed, as set by middleware.ResolveUser.
+func callerID(c *fiber.Ctx) (string, bool) {
+	id, ok := c.Locals("userID").(string)
+	return id, ok && id != ""
+}
+
+// userEmail returns the email of the authenticated caller, as set in the JWT
+// claims by middleware.RequireAuthenticated.
+func userEmail(c *fiber.Ctx) (string, bool) {
+	claims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return "", false
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return "", false
+	}
+
+	if isEmailValid, _ := utils.ValidateEmail(email); !isEmailValid {
+		return "", false
+	}
+
+	return email, true
+}
+
+// userLocation returns the time zone the caller's data should be evaluated
+// in: the one named by override if set, otherwise the one in their settings,
+// otherwise UTC.
+func userLocation(db *gorm.DB, userID string, override string) (*time.Location, error) {
+	name := override
+	if name == "" {
+		var settings model.UserSettings
+		err := db.Where("user_id = ?", userID).First(&settings).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		name = settings.TimeZone
+	}
+
+	isTimeZoneValid, timeZoneValFeedback, location := utils.ValidateTimeZone(name)
+	if !isTimeZoneValid {
+		return nil, errors.New(timeZoneValFeedback)
+	}
+
+	return location, nil
+}
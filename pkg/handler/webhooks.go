@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/abyan-dev/productivity/pkg/webhook"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxWebhooksPerUser also limits the service-wide endpoints.
+const maxWebhooksPerUser = 10
+
+type WebhookPayload struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active"`
+}
+
+// CreatedWebhook is the only response that includes the signing secret.
+type CreatedWebhook struct {
+	model.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+type DeliveryList struct {
+	Deliveries []model.WebhookDelivery `json:"deliveries"`
+	Page       int                     `json:"page"`
+	PageSize   int                     `json:"page_size"`
+	Total      int64                   `json:"total"`
+}
+
+func CreateWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return createWebhook(c, db, userID)
+}
+
+func GetAllWebhooks(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return getAllWebhooks(c, db, userID)
+}
+
+func GetWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return getWebhook(c, db, userID)
+}
+
+func UpdateWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return updateWebhook(c, db, userID)
+}
+
+func DeleteWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return deleteWebhook(c, db, userID)
+}
+
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return getWebhookDeliveries(c, db, userID)
+}
+
+// SendTestWebhook queues a ping event for the endpoint. Like every other
+// delivery, it is sent in the background and shows up in the delivery log.
+func SendTestWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	userID, ok := callerID(c)
+	if !ok {
+		return response.Unauthorized(c, "Invalid user claims")
+	}
+
+	return sendTestWebhook(c, db, userID)
+}
+
+// AdminCreateWebhook registers a service-wide endpoint, which receives
+// the events of every user it subscribes to.
+func AdminCreateWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	return createWebhook(c, db, model.ServiceWideWebhook)
+}
+
+func AdminGetAllWebhooks(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	return getAllWebhooks(c, db, model.ServiceWideWebhook)
+}
+
+func AdminGetWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	return getWebhook(c, db, model.ServiceWideWebhook)
+}
+
+func AdminUpdateWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	return updateWebhook(c, db, model.ServiceWideWebhook)
+}
+
+func AdminDeleteWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	return deleteWebhook(c, db, model.ServiceWideWebhook)
+}
+
+func AdminGetWebhookDeliveries(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	return getWebhookDeliveries(c, db, model.ServiceWideWebhook)
+}
+
+func AdminSendTestWebhook(c *fiber.Ctx) error {
+	db := c.Locals("db").(*gorm.DB)
+	return sendTestWebhook(c, db, model.ServiceWideWebhook)
+}
+
+func createWebhook(c *fiber.Ctx, db *gorm.DB, userID string) error {
+	requestPayload := WebhookPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	var count int64
+	if err := db.Model(&model.WebhookEndpoint{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve webhooks.")
+	}
+	if count >= maxWebhooksPerUser {
+		return response.BadRequest(c, "At most 10 webhooks can be registered")
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return response.InternalServerError(c, "Failed to create webhook.")
+	}
+
+	endpoint := model.WebhookEndpoint{
+		Secret:    secret,
+		Active:    true,
+		CreatedAt: time.Now().UTC(),
+		UserID:    userID,
+	}
+	if isValid, feedback := applyWebhookPayload(&endpoint, requestPayload); !isValid {
+		return response.BadRequest(c, feedback)
+	}
+
+	if err := db.Create(&endpoint).Error; err != nil {
+		return response.InternalServerError(c, "Failed to create webhook.")
+	}
+
+	return response.Created(c, "Successfully created webhook.", CreatedWebhook{WebhookEndpoint: endpoint, Secret: secret})
+}
+
+func getAllWebhooks(c *fiber.Ctx, db *gorm.DB, userID string) error {
+	endpoints := []model.WebhookEndpoint{}
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&endpoints).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve webhooks.")
+	}
+
+	return response.Ok(c, "Successfully retrieved webhooks", endpoints)
+}
+
+func getWebhook(c *fiber.Ctx, db *gorm.DB, userID string) error {
+	endpoint, err := findWebhook(db, userID, c.Params("id"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Webhook not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve webhook.")
+	}
+
+	return response.Ok(c, "Successfully retrieved webhook", endpoint)
+}
+
+func updateWebhook(c *fiber.Ctx, db *gorm.DB, userID string) error {
+	requestPayload := WebhookPayload{}
+
+	if err := c.BodyParser(&requestPayload); err != nil {
+		return response.BadRequest(c, "Invalid request payload")
+	}
+
+	endpoint, err := findWebhook(db, userID, c.Params("id"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Webhook not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve webhook.")
+	}
+
+	if isValid, feedback := applyWebhookPayload(&endpoint, requestPayload); !isValid {
+		return response.BadRequest(c, feedback)
+	}
+
+	if err := db.Save(&endpoint).Error; err != nil {
+		return response.InternalServerError(c, "Failed to update webhook.")
+	}
+
+	return response.Ok(c, "Successfully updated webhook", endpoint)
+}
+
+func deleteWebhook(c *fiber.Ctx, db *gorm.DB, userID string) error {
+	id := c.Params("id")
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		endpoint, err := findWebhook(tx, userID, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&endpoint).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.NotFound(c, "Webhook not found")
+	}
+	if err != nil {
+		return response.InternalServerError(c, "Failed to delete webhook.")
+	}
+
+	return response.Ok(c, "Successfully deleted webhook.")
+}
+
+func getWebhookDeliveries(c *fiber.Ctx, db *gorm.DB, userID string) error {
+	isPaginationValid, paginationValFeedback, page, pageSize := utils.ValidatePagination(c.Query("page"), c.Query("page_size"))
+	if !isPaginationValid {
+		return response.BadRequest(c, paginationValFeedback)
+	}
+
+	endpoint, err := findWebhook(db, userID, c.Params("id"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Webhook not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve webhook.")
+	}
+
+	query := db.Model(&model.WebhookDelivery{}).Where("endpoint_id = ?", endpoint.ID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return response.InternalServerError(c, "Failed to retrieve deliveries.")
+	}
+
+	deliveries := []model.WebhookDelivery{}
+	result := query.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&deliveries)
+	if result.Error != nil {
+		return response.InternalServerError(c, "Failed to retrieve deliveries.")
+	}
+
+	return response.Ok(c, "Successfully retrieved deliveries", DeliveryList{
+		Deliveries: deliveries,
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+	})
+}
+
+func sendTestWebhook(c *fiber.Ctx, db *gorm.DB, userID string) error {
+	endpoint, err := findWebhook(db, userID, c.Params("id"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound(c, "Webhook not found")
+		}
+		return response.InternalServerError(c, "Failed to retrieve webhook.")
+	}
+
+	event, err := webhook.NewEvent(webhook.EventPing, fiber.Map{"webhook_id": endpoint.ID}, time.Now())
+	if err != nil {
+		return response.InternalServerError(c, "Failed to send test event.")
+	}
+
+	delivery, err := webhook.EnqueueTo(db, endpoint, userID, event)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to send test event.")
+	}
+
+	return response.Accepted(c, "Successfully queued test event", delivery)
+}
+
+// findWebhook finds an endpoint of the given owner, which is a user ID or
+// model.ServiceWideWebhook.
+func findWebhook(db *gorm.DB, userID string, id string) (model.WebhookEndpoint, error) {
+	var endpoint model.WebhookEndpoint
+	err := db.Where("user_id = ?", userID).First(&endpoint, id).Error
+	return endpoint, err
+}
+
+func applyWebhookPayload(endpoint *model.WebhookEndpoint, payload WebhookPayload) (bool, string) {
+	if isURLValid, urlValFeedback := utils.ValidateWebhookURL(payload.URL); !isURLValid {
+		return false, urlValFeedback
+	}
+
+	if len(payload.EventTypes) == 0 {
+		return false, "At least one event type must be subscribed to"
+	}
+
+	eventTypes := make([]string, 0, len(payload.EventTypes))
+	seen := map[string]bool{}
+	for _, eventType := range payload.EventTypes {
+		if !webhook.IsValidEventType(eventType) {
+			return false, "Event types must be any of " + strings.Join(webhook.EventTypes, ", ")
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+
+	endpoint.URL = payload.URL
+	endpoint.EventTypes = strings.Join(eventTypes, ",")
+	if payload.Active != nil {
+		endpoint.Active = *payload.Active
+	}
+
+	return true, "Webhook is valid"
+}
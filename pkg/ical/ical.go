@@ -0,0 +1,182 @@
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+)
+
+const (
+	timestampLayout = "20060102T150405Z"
+	maxLineOctets   = 75
+	uidDomain       = "productivity.abyan.dev"
+)
+
+// Options controls which components a calendar is built from.
+type Options struct {
+	// TasksAsTodos renders tasks as VTODO items instead of VEVENT items.
+	// Calendar apps such as Google Calendar only show VEVENT items.
+	TasksAsTodos bool
+	// SubjectNames maps subject IDs to names, to title focus sessions by.
+	SubjectNames map[uint]string
+}
+
+// Calendar renders tasks with a due date, and focus sessions, as an RFC 5545
+// iCalendar object.
+func Calendar(name string, tasks []model.Task, sessions []model.PomodoroSession, options Options, now time.Time) string {
+	w := &writer{}
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//abyan-dev//productivity//EN")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	w.line("X-WR-CALNAME", Escape(name))
+
+	stamp := now.UTC().Format(timestampLayout)
+
+	for _, task := range tasks {
+		if task.DueDate.IsZero() {
+			continue
+		}
+		due := task.DueDate.UTC().Format(timestampLayout)
+
+		if options.TasksAsTodos {
+			w.line("BEGIN", "VTODO")
+			w.todo(task, stamp)
+			continue
+		}
+
+		summary := task.Title
+		if task.IsComplete {
+			summary = "Done: " + summary
+		}
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", Escape(TaskUID(task)))
+		w.line("DTSTAMP", stamp)
+		w.line("DTSTART", due)
+		w.line("SUMMARY", Escape(summary))
+		if task.Description != "" {
+			w.line("DESCRIPTION", Escape(task.Description))
+		}
+		w.line("TRANSP", "TRANSPARENT")
+		w.line("END", "VEVENT")
+	}
+
+	for _, session := range sessions {
+		if session.EndTime == nil {
+			continue
+		}
+
+		summary := "Focus session"
+		if session.SubjectID != nil {
+			if subject, ok := options.SubjectNames[*session.SubjectID]; ok {
+				summary = "Focus: " + subject
+			}
+		}
+
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", fmt.Sprintf("session-%d@%s", session.ID, uidDomain))
+		w.line("DTSTAMP", stamp)
+		w.line("DTSTART", session.StartTime.UTC().Format(timestampLayout))
+		w.line("DTEND", session.EndTime.UTC().Format(timestampLayout))
+		w.line("SUMMARY", Escape(summary))
+		w.line("END", "VEVENT")
+	}
+
+	w.line("END", "VCALENDAR")
+	return w.String()
+}
+
+// Todo renders a single task as an iCalendar object holding one VTODO, as
+// served to CalDAV clients.
+func Todo(task model.Task, now time.Time) string {
+	w := &writer{}
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//abyan-dev//productivity//EN")
+	w.todo(task, now.UTC().Format(timestampLayout))
+	w.line("END", "VCALENDAR")
+	return w.String()
+}
+
+// TaskUID returns the iCalendar UID of a task. Tasks created by calendar
+// clients keep the UID the client chose.
+func TaskUID(task model.Task) string {
+	if task.UID != "" {
+		return task.UID
+	}
+	return fmt.Sprintf("task-%d@%s", task.ID, uidDomain)
+}
+
+// Escape escapes a TEXT property value.
+func Escape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(text)
+}
+
+type writer struct {
+	strings.Builder
+}
+
+func (w *writer) todo(task model.Task, stamp string) {
+	w.line("BEGIN", "VTODO")
+	w.line("UID", Escape(TaskUID(task)))
+	w.line("DTSTAMP", stamp)
+	if !task.CreatedAt.IsZero() {
+		w.line("CREATED", task.CreatedAt.UTC().Format(timestampLayout))
+	}
+	if !task.UpdatedAt.IsZero() {
+		w.line("LAST-MODIFIED", task.UpdatedAt.UTC().Format(timestampLayout))
+	}
+	w.line("SUMMARY", Escape(task.Title))
+	if task.Description != "" {
+		w.line("DESCRIPTION", Escape(task.Description))
+	}
+	if !task.DueDate.IsZero() {
+		w.line("DUE", task.DueDate.UTC().Format(timestampLayout))
+	}
+	if task.IsComplete {
+		w.line("STATUS", "COMPLETED")
+		if task.CompletedAt != nil {
+			w.line("COMPLETED", task.CompletedAt.UTC().Format(timestampLayout))
+		}
+	} else {
+		w.line("STATUS", "NEEDS-ACTION")
+	}
+	w.line("END", "VTODO")
+}
+
+// line writes a content line, folded so that no line exceeds 75 octets,
+// without splitting UTF-8 sequences.
+func (w *writer) line(name string, value string) {
+	content := name + ":" + value
+
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(content[cut]) {
+			cut--
+		}
+		w.WriteString(content[:cut])
+		w.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space, which counts towards
+		// their length.
+		limit = maxLineOctets - 1
+	}
+
+	w.WriteString(content)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
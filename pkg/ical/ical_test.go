@@ -0,0 +1,191 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Essay", "Essay"},
+		{"Read ch. 1, 2; then summarize", `Read ch. 1\, 2\; then summarize`},
+		{`C:\notes`, `C:\\notes`},
+		{"line one\nline two\r\nline three", `line one\nline two\nline three`},
+	}
+
+	for _, test := range tests {
+		if result := Escape(test.input); result != test.expected {
+			t.Errorf("Escape(%q) = %q, want %q", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestLineFolding(t *testing.T) {
+	w := &writer{}
+	w.line("SUMMARY", strings.Repeat("é", 100))
+
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\r\n"), "\r\n")
+	if len(lines) < 3 {
+		t.Fatalf("got %d lines, want the value folded over at least 3", len(lines))
+	}
+
+	unfolded := ""
+	for i, line := range lines {
+		if len(line) > maxLineOctets {
+			t.Errorf("line %d has %d octets, want at most %d", i, len(line), maxLineOctets)
+		}
+		if i > 0 {
+			if !strings.HasPrefix(line, " ") {
+				t.Errorf("continuation line %d does not start with a space", i)
+			}
+			line = line[1:]
+		}
+		unfolded += line
+	}
+
+	if unfolded != "SUMMARY:"+strings.Repeat("é", 100) {
+		t.Errorf("unfolded line = %q, want the original content", unfolded)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	completedAt := time.Date(2024, 7, 1, 7, 0, 0, 0, time.UTC)
+	subjectID := uint(4)
+	sessionEnd := time.Date(2024, 6, 30, 10, 25, 0, 0, time.UTC)
+
+	tasks := []model.Task{
+		{ID: 1, Title: "Essay, draft", DueDate: time.Date(2024, 7, 2, 17, 0, 0, 0, jakarta)},
+		{ID: 2, Title: "Quiz", DueDate: time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC), IsComplete: true, CompletedAt: &completedAt},
+		{ID: 3, Title: "Someday"},
+	}
+	sessions := []model.PomodoroSession{
+		{ID: 9, StartTime: time.Date(2024, 6, 30, 10, 0, 0, 0, time.UTC), EndTime: &sessionEnd, SubjectID: &subjectID},
+		{ID: 10, StartTime: now},
+	}
+	options := Options{SubjectNames: map[uint]string{4: "Calculus"}}
+
+	events := Calendar("Tasks", tasks, sessions, options, now)
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:task-1@productivity.abyan.dev\r\nDTSTAMP:20240701T080000Z\r\nDTSTART:20240702T100000Z\r\nSUMMARY:Essay\\, draft\r\n",
+		"SUMMARY:Done: Quiz\r\n",
+		"UID:session-9@productivity.abyan.dev\r\nDTSTAMP:20240701T080000Z\r\nDTSTART:20240630T100000Z\r\nDTEND:20240630T102500Z\r\nSUMMARY:Focus: Calculus\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(events, expected) {
+			t.Errorf("calendar does not contain %q:\n%s", expected, events)
+		}
+	}
+	if strings.Contains(events, "Someday") || strings.Contains(events, "session-10") {
+		t.Errorf("calendar contains tasks without due date or running sessions:\n%s", events)
+	}
+	if strings.Contains(events, "VTODO") {
+		t.Errorf("calendar contains VTODO items although tasks should be events:\n%s", events)
+	}
+
+	options.TasksAsTodos = true
+	todos := Calendar("Tasks", tasks, nil, options, now)
+	for _, expected := range []string{
+		"BEGIN:VTODO\r\nUID:task-1@productivity.abyan.dev\r\nDTSTAMP:20240701T080000Z\r\nSUMMARY:Essay\\, draft\r\nDUE:20240702T100000Z\r\nSTATUS:NEEDS-ACTION\r\nEND:VTODO\r\n",
+		"DUE:20240701T090000Z\r\nSTATUS:COMPLETED\r\nCOMPLETED:20240701T070000Z\r\n",
+	} {
+		if !strings.Contains(todos, expected) {
+			t.Errorf("calendar does not contain %q:\n%s", expected, todos)
+		}
+	}
+}
+
+func TestParseTodo(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Berlin",
+		"END:VTIMEZONE",
+		"BEGIN:VTODO",
+		"UID:3F2504E0-4F89-11D3",
+		`SUMMARY:Read ch. 1\, 2\; then`,
+		"  summarize",
+		"DESCRIPTION:first\\nsecond",
+		"DUE;TZID=\"Europe/Berlin\":20240702T170000",
+		"STATUS:COMPLETED",
+		"COMPLETED:20240701T070000Z",
+		"BEGIN:VALARM",
+		"DESCRIPTION:Alarm",
+		"END:VALARM",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	todo, err := ParseTodo(data, jakarta)
+	if err != nil {
+		t.Fatalf("ParseTodo() error = %v", err)
+	}
+
+	if todo.UID != "3F2504E0-4F89-11D3" || todo.Summary != "Read ch. 1, 2; then summarize" || todo.Description != "first\nsecond" {
+		t.Errorf("ParseTodo() = %+v, want the text fields unescaped", todo)
+	}
+	if todo.Due == nil || !todo.Due.Equal(time.Date(2024, 7, 2, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseTodo() due = %v, want 2024-07-02 15:00 UTC", todo.Due)
+	}
+	if !todo.Completed || todo.CompletedAt == nil || !todo.CompletedAt.Equal(time.Date(2024, 7, 1, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseTodo() completion = (%v, %v), want completed at 2024-07-01 07:00 UTC", todo.Completed, todo.CompletedAt)
+	}
+
+	tests := []struct {
+		due      string
+		expected time.Time
+	}{
+		{"DUE:20240702T170000Z", time.Date(2024, 7, 2, 17, 0, 0, 0, time.UTC)},
+		{"DUE:20240702T170000", time.Date(2024, 7, 2, 17, 0, 0, 0, jakarta)},
+		{"DUE;VALUE=DATE:20240702", time.Date(2024, 7, 2, 0, 0, 0, 0, jakarta)},
+	}
+	for _, test := range tests {
+		todo, err := ParseTodo("BEGIN:VCALENDAR\nBEGIN:VTODO\n"+test.due+"\nEND:VTODO\nEND:VCALENDAR\n", jakarta)
+		if err != nil || todo.Due == nil || !todo.Due.Equal(test.expected) {
+			t.Errorf("ParseTodo(%q) due = (%v, %v), want %v", test.due, todo.Due, err, test.expected)
+		}
+	}
+
+	if _, err := ParseTodo("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", jakarta); err != ErrNoTodo {
+		t.Errorf("ParseTodo() of an event error = %v, want ErrNoTodo", err)
+	}
+}
+
+func TestTodoRoundTrip(t *testing.T) {
+	completedAt := time.Date(2024, 7, 1, 7, 0, 0, 0, time.UTC)
+	task := model.Task{
+		ID:          5,
+		Title:       "Lab report; part 2",
+		Description: "Graphs,\ntables",
+		DueDate:     time.Date(2024, 7, 2, 10, 0, 0, 0, time.UTC),
+		IsComplete:  true,
+		CompletedAt: &completedAt,
+	}
+
+	todo, err := ParseTodo(Todo(task, completedAt), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseTodo() error = %v", err)
+	}
+
+	if todo.UID != "task-5@productivity.abyan.dev" || todo.Summary != task.Title || todo.Description != task.Description ||
+		todo.Due == nil || !todo.Due.Equal(task.DueDate) || !todo.Completed || !todo.CompletedAt.Equal(completedAt) {
+		t.Errorf("round trip = %+v, want the fields of %+v", todo, task)
+	}
+}
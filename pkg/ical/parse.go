@@ -0,0 +1,167 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrNoTodo = errors.New("calendar object contains no VTODO")
+
+// ParsedTodo holds the fields of a VTODO that map onto a task.
+type ParsedTodo struct {
+	UID         string
+	Summary     string
+	Description string
+	Due         *time.Time
+	Completed   bool
+	CompletedAt *time.Time
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseTodo parses the first VTODO of an iCalendar object. Floating times,
+// and times in unknown time zones, are interpreted in location.
+func ParseTodo(data string, location *time.Location) (ParsedTodo, error) {
+	var todo ParsedTodo
+	found := false
+	depth := 0
+
+	for _, line := range unfold(data) {
+		if line == "" {
+			continue
+		}
+		prop, err := parseProperty(line)
+		if err != nil {
+			return ParsedTodo{}, err
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			if depth == 0 && strings.EqualFold(prop.value, "VTODO") && !found {
+				found = true
+				depth = 1
+			} else if depth > 0 {
+				depth++
+			}
+			continue
+		case "END":
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					return todo, nil
+				}
+			}
+			continue
+		}
+
+		// Only properties of the VTODO itself, not of nested components
+		// such as VALARM, are relevant.
+		if depth != 1 {
+			continue
+		}
+
+		switch prop.name {
+		case "UID":
+			todo.UID = prop.value
+		case "SUMMARY":
+			todo.Summary = Unescape(prop.value)
+		case "DESCRIPTION":
+			todo.Description = Unescape(prop.value)
+		case "DUE":
+			due, err := parseTime(prop, location)
+			if err != nil {
+				return ParsedTodo{}, err
+			}
+			todo.Due = &due
+		case "STATUS":
+			todo.Completed = strings.EqualFold(prop.value, "COMPLETED")
+		case "COMPLETED":
+			completedAt, err := parseTime(prop, location)
+			if err != nil {
+				return ParsedTodo{}, err
+			}
+			todo.CompletedAt = &completedAt
+		}
+	}
+
+	if !found {
+		return ParsedTodo{}, ErrNoTodo
+	}
+	return ParsedTodo{}, errors.New("unterminated VTODO")
+}
+
+// Unescape reverses Escape.
+func Unescape(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i == len(text)-1 {
+			b.WriteByte(text[i])
+			continue
+		}
+		i++
+		switch text[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(text[i])
+		}
+	}
+	return b.String()
+}
+
+func unfold(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+	return strings.Split(data, "\n")
+}
+
+// parseProperty splits a content line into its name, parameters and value.
+// Parameter values may be quoted and contain colons.
+func parseProperty(line string) (property, error) {
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, errors.New("malformed content line: " + line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop := property{name: strings.ToUpper(parts[0]), params: map[string]string{}, value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return prop, nil
+}
+
+func parseTime(prop property, location *time.Location) (time.Time, error) {
+	if tzid, ok := prop.params["TZID"]; ok {
+		if zone, err := time.LoadLocation(tzid); err == nil {
+			location = zone
+		}
+	}
+
+	value := prop.value
+	switch {
+	case strings.EqualFold(prop.params["VALUE"], "DATE") || len(value) == len("20060102"):
+		return time.ParseInLocation("20060102", value, location)
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse(timestampLayout, value)
+	default:
+		return time.ParseInLocation("20060102T150405", value, location)
+	}
+}
@@ -0,0 +1,162 @@
+package importer
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// Item is a validated row, ready to be created as a task.
+type Item struct {
+	Line        int        `json:"line"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	DueDate     time.Time  `json:"due_date"`
+	IsComplete  bool       `json:"is_complete"`
+	CompletedAt *time.Time `json:"completed_at"`
+	Duplicate   bool       `json:"duplicate"`
+}
+
+// RowError lists why a row cannot be imported.
+type RowError struct {
+	Line   int      `json:"line"`
+	Errors []string `json:"errors"`
+}
+
+// Preview is what an import would do, without doing it.
+type Preview struct {
+	Total      int        `json:"total"`
+	Valid      int        `json:"valid"`
+	Duplicates int        `json:"duplicates"`
+	Invalid    int        `json:"invalid"`
+	Items      []Item     `json:"items"`
+	Errors     []RowError `json:"errors"`
+}
+
+// Validate checks the rows against the rules tasks created through the API
+// follow, and returns the valid ones as items along with the errors of the
+// others.
+func Validate(rows []Row) ([]Item, []RowError) {
+	items := []Item{}
+	rowErrors := []RowError{}
+
+	for _, row := range rows {
+		item, errs := validateRow(row)
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, RowError{Line: row.Line, Errors: errs})
+			continue
+		}
+		items = append(items, item)
+	}
+
+	return items, rowErrors
+}
+
+func validateRow(row Row) (Item, []string) {
+	errs := []string{}
+	item := Item{Line: row.Line, Title: strings.TrimSpace(row.Title), Description: row.Description}
+
+	if item.Title == "" {
+		errs = append(errs, "Title is required")
+	} else if utf8.RuneCountInString(item.Title) > 100 {
+		errs = append(errs, "Title must be at most 100 characters")
+	}
+
+	if row.DueDate == "" {
+		errs = append(errs, "Due date is required")
+	} else if isDueDateValid, dueDateValFeedback, dueDate := utils.ValidateTime(row.DueDate); !isDueDateValid {
+		errs = append(errs, dueDateValFeedback)
+	} else {
+		item.DueDate = dueDate.UTC()
+	}
+
+	completed, ok := parseCompleted(row.Completed)
+	if !ok {
+		errs = append(errs, "Completed must be true or false")
+	}
+	item.IsComplete = completed
+
+	if completed {
+		// Completion times are kept where the export has them, so that
+		// the metrics of the day the task was done are right; otherwise
+		// the due date is the best guess.
+		completedAt := item.DueDate
+		if row.CompletedAt != "" {
+			isCompletedAtValid, completedAtValFeedback, parsed := utils.ValidateTime(row.CompletedAt)
+			if !isCompletedAtValid {
+				errs = append(errs, completedAtValFeedback)
+			}
+			completedAt = parsed.UTC()
+		}
+		item.CompletedAt = &completedAt
+	}
+
+	return item, errs
+}
+
+func parseCompleted(value string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "no", "n":
+		return false, true
+	case "yes", "y", "x", "done", "completed":
+		return true, true
+	}
+	completed, err := strconv.ParseBool(value)
+	return completed, err == nil
+}
+
+// DedupKey identifies tasks that are taken to be the same: those with the
+// same title, ignoring case, due at the same time.
+func DedupKey(title string, dueDate time.Time) string {
+	return strings.ToLower(strings.TrimSpace(title)) + "|" + strconv.FormatInt(dueDate.Unix(), 10)
+}
+
+// ExistingKeys returns the dedup keys of the user's tasks.
+func ExistingKeys(db *gorm.DB, userID string) (map[string]bool, error) {
+	var tasks []model.Task
+	if err := db.Select("title", "due_date").Where("user_id = ?", userID).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for _, task := range tasks {
+		keys[DedupKey(task.Title, task.DueDate)] = true
+	}
+	return keys, nil
+}
+
+// MarkDuplicates flags the items matching an existing task or an earlier
+// item, and returns how many it flagged. Keys of the other items are added
+// to existing.
+func MarkDuplicates(items []Item, existing map[string]bool) int {
+	duplicates := 0
+	for i := range items {
+		key := DedupKey(items[i].Title, items[i].DueDate)
+		items[i].Duplicate = existing[key]
+		if items[i].Duplicate {
+			duplicates++
+		}
+		existing[key] = true
+	}
+	return duplicates
+}
+
+// NewPreview validates the rows and flags duplicates of existing tasks.
+func NewPreview(rows []Row, existing map[string]bool) Preview {
+	items, rowErrors := Validate(rows)
+	duplicates := MarkDuplicates(items, existing)
+
+	return Preview{
+		Total:      len(rows),
+		Valid:      len(items) - duplicates,
+		Duplicates: duplicates,
+		Invalid:    len(rowErrors),
+		Items:      items,
+		Errors:     rowErrors,
+	}
+}
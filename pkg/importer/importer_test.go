@@ -0,0 +1,182 @@
+package importer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCSV(t *testing.T) {
+	data := []byte("\xef\xbb\xbfName,Notes,Due,Done\n" +
+		"Essay,\"Draft, then edit\",2024-07-02T17:00:00+07:00,no\n" +
+		"\n" +
+		"Quiz,,2024-07-01T09:00:00Z,yes\n")
+
+	rows, err := ParseCSV(data, Mapping{Title: "Name", Description: "Notes", DueDate: "Due", Completed: "Done"})
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+
+	expected := []Row{
+		{Line: 2, Title: "Essay", Description: "Draft, then edit", DueDate: "2024-07-02T17:00:00+07:00", Completed: "no"},
+		{Line: 4, Title: "Quiz", DueDate: "2024-07-01T09:00:00Z", Completed: "yes"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("ParseCSV() = %+v, want %+v", rows, expected)
+	}
+	for i := range expected {
+		if rows[i] != expected[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], expected[i])
+		}
+	}
+
+	if _, err := ParseCSV(data, Mapping{Title: "Title"}); err == nil {
+		t.Error("ParseCSV() with an unknown column succeeded, want an error")
+	}
+	if _, err := ParseCSV(data, Mapping{DueDate: "Due"}); err == nil {
+		t.Error("ParseCSV() without a title column succeeded, want an error")
+	}
+}
+
+func TestParseTodoist(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup := []byte(`{"items": [
+		{"content": "Essay", "description": "Draft", "checked": false, "due": {"date": "2024-07-02T17:00:00", "timezone": "Europe/Berlin"}},
+		{"content": "Quiz", "checked": 1, "completed_at": "2024-07-01T07:00:00Z", "due": {"date": "2024-07-01"}},
+		{"content": "Gone", "is_deleted": true},
+		{"content": "Someday", "due": null}
+	]}`)
+
+	rows, err := ParseTodoist(backup, jakarta)
+	if err != nil {
+		t.Fatalf("ParseTodoist() error = %v", err)
+	}
+
+	expected := []Row{
+		{Line: 1, Title: "Essay", Description: "Draft", DueDate: "2024-07-02T17:00:00+02:00", Completed: "false"},
+		{Line: 2, Title: "Quiz", DueDate: "2024-07-01T00:00:00+07:00", Completed: "true", CompletedAt: "2024-07-01T07:00:00Z"},
+		{Line: 4, Title: "Someday", Completed: "false"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("ParseTodoist() = %+v, want %+v", rows, expected)
+	}
+	for i := range expected {
+		if rows[i] != expected[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], expected[i])
+		}
+	}
+
+	template := []byte("TYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,AUTHOR,RESPONSIBLE,DATE,DATE_LANG,TIMEZONE\n" +
+		"section,Week 1,,,,,,,,\n" +
+		"task,Read chapter 1,,4,1,,,2024-07-02,en,Asia/Jakarta\n" +
+		"task,Water plants,,4,1,,,every day,en,Asia/Jakarta\n")
+
+	rows, err = ParseTodoist(template, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseTodoist() of a CSV template error = %v", err)
+	}
+	if len(rows) != 2 || rows[0].Line != 3 || rows[0].DueDate != "2024-07-02T00:00:00+07:00" || rows[1].DueDate != "every day" {
+		t.Errorf("ParseTodoist() of a CSV template = %+v, want the two tasks", rows)
+	}
+}
+
+func TestParseTrello(t *testing.T) {
+	board := []byte(`{
+		"lists": [{"id": "l1", "closed": false}, {"id": "l2", "closed": true}],
+		"cards": [
+			{"name": "Essay", "desc": "Draft", "due": "2024-07-02T10:00:00.000Z", "dueComplete": true, "idList": "l1"},
+			{"name": "Archived", "closed": true, "idList": "l1"},
+			{"name": "In archived list", "idList": "l2"},
+			{"name": "No due date", "due": null, "idList": "l1"}
+		]
+	}`)
+
+	rows, err := ParseTrello(board)
+	if err != nil {
+		t.Fatalf("ParseTrello() error = %v", err)
+	}
+
+	expected := []Row{
+		{Line: 1, Title: "Essay", Description: "Draft", DueDate: "2024-07-02T10:00:00.000Z", Completed: "true"},
+		{Line: 4, Title: "No due date", Completed: "false"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("ParseTrello() = %+v, want %+v", rows, expected)
+	}
+	for i := range expected {
+		if rows[i] != expected[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], expected[i])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	rows := []Row{
+		{Line: 2, Title: " Essay ", DueDate: "2024-07-02T17:00:00+07:00", Completed: "no"},
+		{Line: 3, Title: "Quiz", DueDate: "2024-07-01T09:00:00Z", Completed: "true", CompletedAt: "2024-07-01T07:00:00Z"},
+		{Line: 4, Title: "Lab", DueDate: "2024-07-03T09:00:00Z", Completed: "x"},
+		{Line: 5, Title: "", DueDate: "tomorrow", Completed: "maybe"},
+		{Line: 6, Title: "No due date"},
+	}
+
+	items, rowErrors := Validate(rows)
+
+	if len(items) != 3 {
+		t.Fatalf("Validate() items = %+v, want 3", items)
+	}
+	if items[0].Title != "Essay" || !items[0].DueDate.Equal(time.Date(2024, 7, 2, 10, 0, 0, 0, time.UTC)) || items[0].IsComplete {
+		t.Errorf("item 0 = %+v, want the trimmed open task due at 10:00 UTC", items[0])
+	}
+	if !items[1].IsComplete || !items[1].CompletedAt.Equal(time.Date(2024, 7, 1, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("item 1 = %+v, want the task completed at the exported time", items[1])
+	}
+	if !items[2].IsComplete || !items[2].CompletedAt.Equal(items[2].DueDate) {
+		t.Errorf("item 2 = %+v, want the task completed at its due date", items[2])
+	}
+
+	expected := []RowError{
+		{Line: 5, Errors: []string{"Title is required", "Time format is invalid", "Completed must be true or false"}},
+		{Line: 6, Errors: []string{"Due date is required"}},
+	}
+	if len(rowErrors) != len(expected) {
+		t.Fatalf("Validate() errors = %+v, want %+v", rowErrors, expected)
+	}
+	for i := range expected {
+		if rowErrors[i].Line != expected[i].Line || len(rowErrors[i].Errors) != len(expected[i].Errors) {
+			t.Errorf("errors %d = %+v, want %+v", i, rowErrors[i], expected[i])
+			continue
+		}
+		for j := range expected[i].Errors {
+			if rowErrors[i].Errors[j] != expected[i].Errors[j] {
+				t.Errorf("errors %d = %+v, want %+v", i, rowErrors[i], expected[i])
+			}
+		}
+	}
+}
+
+func TestNewPreview(t *testing.T) {
+	due := time.Date(2024, 7, 2, 10, 0, 0, 0, time.UTC)
+	existing := map[string]bool{DedupKey("Essay", due): true}
+
+	rows := []Row{
+		{Line: 1, Title: "essay", DueDate: "2024-07-02T10:00:00Z"},
+		{Line: 2, Title: "Quiz", DueDate: "2024-07-02T10:00:00Z"},
+		{Line: 3, Title: "QUIZ ", DueDate: "2024-07-02T17:00:00+07:00"},
+		{Line: 4, Title: "Quiz", DueDate: "2024-07-03T10:00:00Z"},
+		{Line: 5, Title: "Broken"},
+	}
+
+	preview := NewPreview(rows, existing)
+
+	if preview.Total != 5 || preview.Valid != 2 || preview.Duplicates != 2 || preview.Invalid != 1 {
+		t.Errorf("NewPreview() = %+v, want 2 valid, 2 duplicates and 1 invalid of 5", preview)
+	}
+	for i, duplicate := range []bool{true, false, true, false} {
+		if preview.Items[i].Duplicate != duplicate {
+			t.Errorf("item %d duplicate = %v, want %v", i, preview.Items[i].Duplicate, duplicate)
+		}
+	}
+}
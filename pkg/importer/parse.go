@@ -0,0 +1,345 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	SourceCSV     = "csv"
+	SourceTodoist = "todoist"
+	SourceTrello  = "trello"
+)
+
+// MaxRows bounds the size of a single import.
+const MaxRows = 5000
+
+var ErrTooManyRows = fmt.Errorf("imports are limited to %d rows", MaxRows)
+
+// Mapping names the CSV columns holding each task field. Only Title is
+// required.
+type Mapping struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+	Completed   string `json:"completed"`
+}
+
+// Row is a task as found in an export, before validation. Line is the
+// position in the export the row is reported under: the line of a CSV
+// file, or the position among the tasks of a JSON export.
+type Row struct {
+	Line        int
+	Title       string
+	Description string
+	DueDate     string
+	Completed   string
+	CompletedAt string
+}
+
+// IsValidSource reports whether tasks can be imported from the source.
+func IsValidSource(source string) bool {
+	return source == SourceCSV || source == SourceTodoist || source == SourceTrello
+}
+
+// Parse reads the rows of an export. Dates without a time zone are taken to
+// be in location.
+func Parse(source string, data []byte, mapping Mapping, location *time.Location) ([]Row, error) {
+	var rows []Row
+	var err error
+
+	switch source {
+	case SourceCSV:
+		rows, err = ParseCSV(data, mapping)
+	case SourceTodoist:
+		rows, err = ParseTodoist(data, location)
+	case SourceTrello:
+		rows, err = ParseTrello(data)
+	default:
+		return nil, fmt.Errorf("unknown source %q", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) > MaxRows {
+		return nil, ErrTooManyRows
+	}
+	return rows, nil
+}
+
+// ParseCSV reads a CSV file with a header line, taking the fields from the
+// columns named by the mapping.
+func ParseCSV(data []byte, mapping Mapping) ([]Row, error) {
+	if mapping.Title == "" {
+		return nil, errors.New("the title column must be mapped")
+	}
+
+	records, header, err := readCSV(data)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	index := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := columns[name]
+		if !ok {
+			return -1, fmt.Errorf("column %q not found", name)
+		}
+		return i, nil
+	}
+
+	title, err := index(mapping.Title)
+	if err != nil {
+		return nil, err
+	}
+	description, err := index(mapping.Description)
+	if err != nil {
+		return nil, err
+	}
+	dueDate, err := index(mapping.DueDate)
+	if err != nil {
+		return nil, err
+	}
+	completed, err := index(mapping.Completed)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []Row{}
+	for _, record := range records {
+		rows = append(rows, Row{
+			Line:        record.line,
+			Title:       record.field(title),
+			Description: record.field(description),
+			DueDate:     record.field(dueDate),
+			Completed:   record.field(completed),
+		})
+	}
+	return rows, nil
+}
+
+type todoistBackup struct {
+	Items []struct {
+		Content     string      `json:"content"`
+		Description string      `json:"description"`
+		Checked     flexBool    `json:"checked"`
+		CompletedAt string      `json:"completed_at"`
+		IsDeleted   flexBool    `json:"is_deleted"`
+		Due         *todoistDue `json:"due"`
+	} `json:"items"`
+}
+
+type todoistDue struct {
+	Date     string `json:"date"`
+	Timezone string `json:"timezone"`
+}
+
+// flexBool accepts the booleans older Todoist backups encode as 0 and 1.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", "1":
+		*b = true
+	case "false", "0", "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
+
+// ParseTodoist reads a Todoist backup, either the JSON of a full backup or
+// the CSV template of a single project.
+func ParseTodoist(data []byte, location *time.Location) ([]Row, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		return parseTodoistCSV(data, location)
+	}
+
+	var backup todoistBackup
+	if err := json.Unmarshal(trimmed, &backup); err != nil {
+		return nil, err
+	}
+
+	rows := []Row{}
+	for i, item := range backup.Items {
+		if item.IsDeleted {
+			continue
+		}
+
+		row := Row{
+			Line:        i + 1,
+			Title:       item.Content,
+			Description: item.Description,
+			Completed:   fmt.Sprint(bool(item.Checked)),
+			CompletedAt: item.CompletedAt,
+		}
+		if item.Due != nil {
+			row.DueDate = todoistDate(item.Due.Date, item.Due.Timezone, location)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseTodoistCSV reads Todoist's CSV template, which lists sections and
+// notes besides the tasks. It only holds open tasks.
+func parseTodoistCSV(data []byte, location *time.Location) ([]Row, error) {
+	records, header, err := readCSV(data)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{"TYPE": -1, "CONTENT": -1, "DESCRIPTION": -1, "DATE": -1, "TIMEZONE": -1}
+	for i, name := range header {
+		if _, ok := columns[strings.ToUpper(strings.TrimSpace(name))]; ok {
+			columns[strings.ToUpper(strings.TrimSpace(name))] = i
+		}
+	}
+	if columns["TYPE"] < 0 || columns["CONTENT"] < 0 {
+		return nil, errors.New("not a Todoist CSV template")
+	}
+
+	rows := []Row{}
+	for _, record := range records {
+		if record.field(columns["TYPE"]) != "task" {
+			continue
+		}
+		rows = append(rows, Row{
+			Line:        record.line,
+			Title:       record.field(columns["CONTENT"]),
+			Description: record.field(columns["DESCRIPTION"]),
+			DueDate:     todoistDate(record.field(columns["DATE"]), record.field(columns["TIMEZONE"]), location),
+			Completed:   "false",
+		})
+	}
+	return rows, nil
+}
+
+// todoistDate converts the dates Todoist uses, which may lack a time or a
+// time zone, to RFC 3339. Anything else, such as the natural language dates
+// of CSV templates, is returned as is to be rejected by validation.
+func todoistDate(date string, timezone string, location *time.Location) string {
+	if timezone != "" {
+		if loc, err := time.LoadLocation(timezone); err == nil {
+			location = loc
+		}
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, date, location); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return date
+}
+
+type trelloBoard struct {
+	Lists []struct {
+		ID     string `json:"id"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Cards []struct {
+		Name        string  `json:"name"`
+		Desc        string  `json:"desc"`
+		Due         *string `json:"due"`
+		DueComplete bool    `json:"dueComplete"`
+		Closed      bool    `json:"closed"`
+		IDList      string  `json:"idList"`
+	} `json:"cards"`
+}
+
+// ParseTrello reads the JSON export of a Trello board. Archived cards and
+// the cards of archived lists are left out.
+func ParseTrello(data []byte) ([]Row, error) {
+	var board trelloBoard
+	if err := json.Unmarshal(data, &board); err != nil {
+		return nil, err
+	}
+
+	closedLists := map[string]bool{}
+	for _, list := range board.Lists {
+		if list.Closed {
+			closedLists[list.ID] = true
+		}
+	}
+
+	rows := []Row{}
+	for i, card := range board.Cards {
+		if card.Closed || closedLists[card.IDList] {
+			continue
+		}
+
+		row := Row{
+			Line:        i + 1,
+			Title:       card.Name,
+			Description: card.Desc,
+			Completed:   fmt.Sprint(card.DueComplete),
+		}
+		if card.Due != nil {
+			row.DueDate = *card.Due
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+type csvRecord struct {
+	line   int
+	fields []string
+}
+
+func (r csvRecord) field(i int) string {
+	if i < 0 || i >= len(r.fields) {
+		return ""
+	}
+	return strings.TrimSpace(r.fields[i])
+}
+
+// readCSV reads a CSV file, skipping blank lines, and returns its records
+// after the header along with the header itself.
+func readCSV(data []byte) ([]csvRecord, []string, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records := []csvRecord{}
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		records = append(records, csvRecord{line: line, fields: fields})
+		if len(records) > MaxRows {
+			return nil, nil, ErrTooManyRows
+		}
+	}
+	return records, header, nil
+}
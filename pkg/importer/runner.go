@@ -0,0 +1,235 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/outbox"
+	"github.com/abyan-dev/productivity/pkg/rollup"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotFinished is returned when rolling back an import that is still
+// running or was already rolled back.
+var ErrNotFinished = errors.New("import is not finished")
+
+// Runner creates the tasks of pending imports. Jobs are claimed with SKIP
+// LOCKED and their progress is saved after every chunk, so a job whose
+// runner died is picked up again where it stopped once it goes stale.
+type Runner struct {
+	DB         *gorm.DB
+	Interval   time.Duration
+	ChunkSize  int
+	StaleAfter time.Duration
+}
+
+func NewRunner(db *gorm.DB) *Runner {
+	return &Runner{
+		DB:         db,
+		Interval:   2 * time.Second,
+		ChunkSize:  100,
+		StaleAfter: 5 * time.Minute,
+	}
+}
+
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if job, err := r.RunOnce(time.Now()); err != nil {
+				slog.Error("Failed to run import", slog.String("error", err.Error()))
+			} else if job != nil {
+				slog.Info("Finished import", slog.Uint64("job_id", uint64(job.ID)), slog.String("status", job.Status))
+			}
+		}
+	}
+}
+
+// RunOnce runs the next pending import to the end and returns it, or nil if
+// there was none.
+func (r *Runner) RunOnce(now time.Time) (*model.ImportJob, error) {
+	job, err := r.claim(now)
+	if err != nil || job == nil {
+		return nil, err
+	}
+
+	if err := r.process(job); err != nil {
+		finishedAt := time.Now().UTC()
+		job.Status = model.ImportFailed
+		job.LastError = err.Error()
+		job.FinishedAt = &finishedAt
+		if saveErr := r.DB.Model(job).Updates(map[string]interface{}{
+			"status": job.Status, "last_error": job.LastError, "finished_at": finishedAt, "updated_at": finishedAt,
+		}).Error; saveErr != nil {
+			return nil, saveErr
+		}
+	}
+
+	return job, nil
+}
+
+func (r *Runner) claim(now time.Time) (*model.ImportJob, error) {
+	var job model.ImportJob
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND updated_at < ?)", model.ImportPending, model.ImportRunning, now.Add(-r.StaleAfter).UTC()).
+			Order("id").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Status = model.ImportRunning
+		job.UpdatedAt = now.UTC()
+		return tx.Model(&job).Updates(map[string]interface{}{"status": job.Status, "updated_at": job.UpdatedAt}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *Runner) process(job *model.ImportJob) error {
+	var items []Item
+	if err := json.Unmarshal([]byte(job.Items), &items); err != nil {
+		return err
+	}
+
+	// Tasks created by earlier chunks already count as existing, which
+	// leaves the items before them to dedup against.
+	existing, err := ExistingKeys(r.DB, job.UserID)
+	if err != nil {
+		return err
+	}
+	for _, item := range items[:job.Processed] {
+		existing[DedupKey(item.Title, item.DueDate)] = true
+	}
+
+	for job.Processed < len(items) {
+		end := min(job.Processed+r.ChunkSize, len(items))
+		chunk := items[job.Processed:end]
+		touched := []time.Time{}
+
+		err := r.DB.Transaction(func(tx *gorm.DB) error {
+			created, duplicates := 0, 0
+			for _, item := range chunk {
+				key := DedupKey(item.Title, item.DueDate)
+				if existing[key] {
+					duplicates++
+					continue
+				}
+
+				task := model.Task{
+					Title:       item.Title,
+					Description: item.Description,
+					DueDate:     item.DueDate,
+					IsComplete:  item.IsComplete,
+					CompletedAt: item.CompletedAt,
+					ImportJobID: &job.ID,
+					UserID:      job.UserID,
+				}
+				if err := tx.Create(&task).Error; err != nil {
+					return err
+				}
+				if err := outbox.Record(tx, job.UserID, outbox.EventTaskCreated, task); err != nil {
+					return err
+				}
+
+				touched = append(touched, task.CreatedAt)
+				if task.CompletedAt != nil {
+					touched = append(touched, *task.CompletedAt)
+				}
+				created++
+			}
+
+			job.Processed = end
+			job.Created += created
+			job.Duplicates += duplicates
+			job.UpdatedAt = time.Now().UTC()
+			return tx.Model(job).Updates(map[string]interface{}{
+				"processed": job.Processed, "created": job.Created, "duplicates": job.Duplicates, "updated_at": job.UpdatedAt,
+			}).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, item := range chunk {
+			existing[DedupKey(item.Title, item.DueDate)] = true
+		}
+		if err := rollup.Touch(r.DB, job.UserID, touched...); err != nil {
+			slog.Error("Failed to refresh rollups", slog.String("user_id", job.UserID), slog.String("error", err.Error()))
+		}
+	}
+
+	finishedAt := time.Now().UTC()
+	job.Status = model.ImportCompleted
+	job.FinishedAt = &finishedAt
+	job.Items = "[]"
+	return r.DB.Model(job).Updates(map[string]interface{}{
+		"status": job.Status, "finished_at": finishedAt, "items": job.Items, "updated_at": finishedAt,
+	}).Error
+}
+
+// Rollback deletes the tasks an import created, including any changes made
+// to them since, and returns them. Only finished imports can be rolled back.
+func Rollback(db *gorm.DB, job *model.ImportJob, now time.Time) ([]model.Task, error) {
+	var tasks []model.Task
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(job, job.ID).Error; err != nil {
+			return err
+		}
+		if job.Status != model.ImportCompleted && job.Status != model.ImportFailed {
+			return ErrNotFinished
+		}
+
+		if err := tx.Where("import_job_id = ? AND user_id = ?", job.ID, job.UserID).Find(&tasks).Error; err != nil {
+			return err
+		}
+
+		if len(tasks) > 0 {
+			ids := make([]uint, len(tasks))
+			for i, task := range tasks {
+				ids[i] = task.ID
+			}
+			if err := tx.Where("task_id IN ?", ids).Delete(&model.Reminder{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&model.Task{}).Error; err != nil {
+				return err
+			}
+			for _, task := range tasks {
+				if err := outbox.Record(tx, job.UserID, outbox.EventTaskDeleted, task); err != nil {
+					return err
+				}
+			}
+		}
+
+		rolledBackAt := now.UTC()
+		job.Status = model.ImportRolledBack
+		job.RolledBackAt = &rolledBackAt
+		job.UpdatedAt = rolledBackAt
+		return tx.Model(job).Updates(map[string]interface{}{
+			"status": job.Status, "rolled_back_at": rolledBackAt, "updated_at": rolledBackAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComposePlainText(t *testing.T) {
+	msg := Message{To: "test@example.com", Subject: "Hello", Text: "Line one\nLine two"}
+
+	body, err := Compose("from@example.com", msg, time.Date(2024, 7, 27, 14, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to compose message: %v", err)
+	}
+
+	s := string(body)
+	for _, want := range []string{
+		"From: from@example.com\r\n",
+		"To: test@example.com\r\n",
+		"Subject: Hello\r\n",
+		"Date: Sat, 27 Jul 2024 14:30:00 +0000\r\n",
+		"Content-Type: text/plain; charset=\"utf-8\"\r\n",
+		"\r\n\r\nLine one\r\nLine two",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Composed message does not contain %q:\n%s", want, s)
+		}
+	}
+}
+
+func TestComposeMultipart(t *testing.T) {
+	msg := Message{To: "test@example.com", Subject: "Your week ✓", Text: "Plain", HTML: "<p>Rich</p>"}
+
+	body, err := Compose("from@example.com", msg, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to compose message: %v", err)
+	}
+
+	s := string(body)
+	if !strings.Contains(s, "Content-Type: multipart/alternative; boundary=") {
+		t.Errorf("Composed message is not multipart:\n%s", s)
+	}
+	if !strings.Contains(s, "Subject: =?utf-8?q?Your_week_=E2=9C=93?=") {
+		t.Errorf("Subject is not encoded:\n%s", s)
+	}
+	textIndex := strings.Index(s, "Content-Type: text/plain")
+	htmlIndex := strings.Index(s, "Content-Type: text/html")
+	if textIndex < 0 || htmlIndex < 0 || textIndex > htmlIndex {
+		t.Errorf("Expected a text part followed by an HTML part:\n%s", s)
+	}
+	if !strings.Contains(s, "<p>Rich</p>") {
+		t.Errorf("HTML body is missing:\n%s", s)
+	}
+}
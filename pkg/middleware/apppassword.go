@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// RequireAppPassword authenticates requests with HTTP Basic auth, using the
+// user's email and one of their app passwords. The caller is exposed in the
+// same way as by RequireAuthenticated, with the role the password was
+// created with, so handlers and RequirePermission work with either, and the
+// user's ID in the userID local, as by ResolveUser.
+func RequireAppPassword() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		email, password, ok := basicAuth(c)
+		if !ok {
+			return challenge(c)
+		}
+
+		db := c.Locals("db").(*gorm.DB)
+
+		var user model.User
+		err := db.Where("email = ?", email).First(&user).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return challenge(c)
+			}
+			return response.InternalServerError(c, "Database error")
+		}
+
+		var appPassword model.AppPassword
+		err = db.Where("password_hash = ? AND user_id = ?", utils.HashToken(password), user.ID).First(&appPassword).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return challenge(c)
+			}
+			return response.InternalServerError(c, "Database error")
+		}
+
+		// App passwords are revoked along with all of the user's tokens, as
+		// by logging out everywhere.
+		revoked, err := account.IssuedBeforeRevocation(db, appPassword.UserID, appPassword.CreatedAt)
+		if err != nil {
+			return response.InternalServerError(c, "Database error")
+		}
+		if revoked {
+			return challenge(c)
+		}
+
+		now := time.Now().UTC()
+		db.Model(&appPassword).UpdateColumn("last_used_at", now)
+
+		c.Locals("user", jwt.MapClaims{"email": user.Email, "role": appPassword.Role})
+		c.Locals("userID", user.ID)
+		return c.Next()
+	}
+}
+
+func basicAuth(c *fiber.Ctx) (string, string, bool) {
+	auth := c.Get(fiber.HeaderAuthorization)
+	if len(auth) < 6 || !strings.EqualFold(auth[:6], "basic ") {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(auth[6:]))
+	if err != nil {
+		return "", "", false
+	}
+
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok || username == "" || password == "" {
+		return "", "", false
+	}
+	return username, password, true
+}
+
+func challenge(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="Productivity", charset="UTF-8"`)
+	return response.Unauthorized(c, "Invalid app password")
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// maxAuditPayload is how much of a request body an audit entry keeps.
+const maxAuditPayload = 4096
+
+// AuditAdmin writes every request to the routes after it to the admin
+// audit log, once it has been handled, along with the response status.
+// Requests refused for lack of permission are recorded too.
+func AuditAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		handlerErr := c.Next()
+
+		claims, _ := c.Locals("user").(jwt.MapClaims)
+		actor, _ := claims["email"].(string)
+
+		payload := string(c.Body())
+		if len(payload) > maxAuditPayload {
+			payload = payload[:maxAuditPayload]
+		}
+
+		status := c.Response().StatusCode()
+		if handlerErr != nil {
+			if fiberErr, ok := handlerErr.(*fiber.Error); ok {
+				status = fiberErr.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+
+		entry := model.AdminAuditEntry{
+			ActorEmail:  actor,
+			Action:      c.Method() + " " + c.Route().Path,
+			TargetEmail: c.Params("email"),
+			Payload:     payload,
+			Status:      status,
+			CreatedAt:   time.Now().UTC(),
+		}
+
+		db := c.Locals("db").(*gorm.DB)
+		if err := db.Create(&entry).Error; err != nil {
+			slog.Error("Failed to write admin audit entry",
+				slog.String("action", entry.Action),
+				slog.String("actor", actor),
+				slog.String("error", err.Error()))
+		}
+
+		return handlerErr
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/url"
+	"strings"
+
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// RequireCSRF protects requests authenticated with the access token cookie
+// from cross-site request forgery, since browsers send the cookie along
+// with requests from any site. Requests with unsafe methods must come from
+// one of the allowed origins, judged by their Origin or Referer header, and
+// repeat the csrf_token cookie in the X-CSRF-Token header, which other
+// sites cannot read. Requests authenticated with a Bearer header or a
+// personal access token are let through, as browsers never add those on
+// their own. It must run after RequireAuthenticated.
+func RequireCSRF(allowedOrigins []string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if safeMethod(c.Method()) {
+			return c.Next()
+		}
+		if cookieAuth, _ := c.Locals("cookieAuth").(bool); !cookieAuth {
+			return c.Next()
+		}
+
+		if !allowedOrigin(c, allowedOrigins) {
+			return response.Forbidden(c, "Cross-origin request not allowed")
+		}
+
+		cookie := c.Cookies(CSRFCookie)
+		header := c.Get(CSRFHeader)
+		if cookie == "" || header == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			return response.Forbidden(c, "Missing or invalid CSRF token")
+		}
+
+		return c.Next()
+	}
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// allowedOrigin checks the Origin header, or the origin of the Referer when
+// there is none. Requests with neither, which some browsers and privacy
+// extensions send, still need the token.
+func allowedOrigin(c *fiber.Ctx, allowedOrigins []string) bool {
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" {
+		referer := c.Get(fiber.HeaderReferer)
+		if referer == "" {
+			return true
+		}
+		parsed, err := url.Parse(referer)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return false
+		}
+		origin = parsed.Scheme + "://" + parsed.Host
+	}
+
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireCSRF(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		cookieAuth bool
+		origin     string
+		referer    string
+		cookie     string
+		header     string
+		expected   int
+	}{
+		{"safe method", fiber.MethodGet, true, "https://evil.example.com", "", "", "", fiber.StatusOK},
+		{"bearer token", fiber.MethodPost, false, "https://evil.example.com", "", "", "", fiber.StatusOK},
+		{"matching token", fiber.MethodPost, true, "http://localhost:3000", "", "abc", "abc", fiber.StatusOK},
+		{"no origin or referer", fiber.MethodDelete, true, "", "", "abc", "abc", fiber.StatusOK},
+		{"allowed referer", fiber.MethodPut, true, "", "http://localhost:3000/tasks", "abc", "abc", fiber.StatusOK},
+		{"missing header", fiber.MethodPost, true, "http://localhost:3000", "", "abc", "", fiber.StatusForbidden},
+		{"missing cookie", fiber.MethodPost, true, "http://localhost:3000", "", "", "abc", fiber.StatusForbidden},
+		{"mismatched token", fiber.MethodPost, true, "http://localhost:3000", "", "abc", "abd", fiber.StatusForbidden},
+		{"other origin", fiber.MethodPost, true, "https://evil.example.com", "", "abc", "abc", fiber.StatusForbidden},
+		{"null origin", fiber.MethodPost, true, "null", "", "abc", "abc", fiber.StatusForbidden},
+		{"other referer", fiber.MethodPost, true, "", "https://evil.example.com/", "abc", "abc", fiber.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("cookieAuth", test.cookieAuth)
+			return c.Next()
+		}, RequireCSRF([]string{"http://localhost:3000"}))
+		app.All("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+		req := httptest.NewRequest(test.method, "/", nil)
+		if test.origin != "" {
+			req.Header.Set(fiber.HeaderOrigin, test.origin)
+		}
+		if test.referer != "" {
+			req.Header.Set(fiber.HeaderReferer, test.referer)
+		}
+		if test.cookie != "" {
+			req.Header.Set(fiber.HeaderCookie, CSRFCookie+"="+test.cookie)
+		}
+		if test.header != "" {
+			req.Header.Set(CSRFHeader, test.header)
+		}
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", test.name, err)
+		}
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s: got status %d, want %d", test.name, resp.StatusCode, test.expected)
+		}
+	}
+}
@@ -1,48 +1,101 @@
 package middleware
 
 import (
-	"fmt"
-	"os"
+	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/auth"
 	"github.com/abyan-dev/productivity/pkg/model"
 	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/abyan-dev/productivity/pkg/revocation"
 	"github.com/abyan-dev/productivity/pkg/utils"
 	jwtware "github.com/gofiber/contrib/jwt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"gorm.io/gorm"
 )
 
-func RequireAuthenticated() fiber.Handler {
-	return jwtware.New(jwtware.Config{
-		SigningKey:     jwtware.SigningKey{Key: []byte(os.Getenv("JWT_SECRET"))},
-		ErrorHandler:   checkForRefresh,
-		SuccessHandler: checkForRevocation,
-		TokenLookup:    "cookie:access_token",
+// RequireAuthenticated lets through requests with a valid access token,
+// as verified by the verifier, sent either as an Authorization: Bearer
+// header or as the access_token cookie. When a request has both, the one
+// named by precedence is used. An expired access token cookie is replaced
+// from the refresh token cookie, but only when tokens are signed with the
+// shared secret; with asymmetric keys only the auth service can issue them.
+// Bearer tokens are never refreshed, since their callers keep no cookies.
+// Personal access tokens are accepted as Bearer tokens too.
+func RequireAuthenticated(verifier *auth.Verifier, precedence string) fiber.Handler {
+	lookup := "header:Authorization,cookie:access_token"
+	if precedence == auth.PrecedenceCookie {
+		lookup = "cookie:access_token,header:Authorization"
+	}
+
+	authenticateJWT := jwtware.New(jwtware.Config{
+		KeyFunc: verifier.Keyfunc,
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			if token, fromCookie := accessToken(c, precedence); token != "" && !fromCookie {
+				return response.Unauthorized(c, "Invalid or expired token")
+			}
+			return checkForRefresh(c, verifier, err)
+		},
+		SuccessHandler: func(c *fiber.Ctx) error {
+			token, fromCookie := accessToken(c, precedence)
+			return checkForRevocation(c, verifier, token, fromCookie)
+		},
+		AuthScheme:  "Bearer",
+		TokenLookup: lookup,
 	})
+
+	return func(c *fiber.Ctx) error {
+		token, fromCookie := accessToken(c, precedence)
+		if !fromCookie && strings.HasPrefix(token, model.PersonalAccessTokenPrefix) {
+			return authenticatePAT(c, token)
+		}
+		return authenticateJWT(c)
+	}
+}
+
+// accessToken returns the access token of the request the way
+// RequireAuthenticated looks it up, and whether it came from the cookie.
+func accessToken(c *fiber.Ctx, precedence string) (string, bool) {
+	cookie := c.Cookies("access_token")
+	if precedence == auth.PrecedenceCookie && cookie != "" {
+		return cookie, true
+	}
+
+	header := c.Get(fiber.HeaderAuthorization)
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer")], "Bearer") {
+		return strings.TrimSpace(header[len("Bearer"):]), false
+	}
+
+	return cookie, cookie != ""
 }
 
-func checkForRefresh(c *fiber.Ctx, err error) error {
+// checkForRefresh issues a new access token from the refresh token cookie
+// when the access token cookie is missing or has expired, but not when it
+// is invalid. The refresh token is exchanged for the next one in its
+// family on every use; see revocation.Store.Rotate.
+func checkForRefresh(c *fiber.Ctx, verifier *auth.Verifier, err error) error {
+	if !verifier.Symmetric() {
+		return response.Unauthorized(c, "Missing, malformed or expired token")
+	}
+
+	if !errors.Is(err, jwtware.ErrJWTMissingOrMalformed) && !errors.Is(err, jwt.ErrTokenExpired) {
+		return response.Unauthorized(c, "Invalid access token")
+	}
+
 	refreshToken := c.Cookies("refresh_token")
 	if refreshToken == "" {
 		return response.Unauthorized(c, "Missing or malformed token")
 	}
 
-	token, parseErr := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-
-	if parseErr != nil || !token.Valid {
+	claims, parseErr := verifier.Parse(refreshToken)
+	if parseErr != nil {
 		return response.Unauthorized(c, "Invalid refresh token")
 	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return response.Unauthorized(c, "Invalid refresh token claims")
+	if claims["typ"] != utils.TokenTypeRefresh {
+		return response.Unauthorized(c, "Invalid refresh token")
 	}
 
 	email, emailOk := claims["email"].(string)
@@ -53,7 +106,39 @@ func checkForRefresh(c *fiber.Ctx, err error) error {
 		return response.Unauthorized(c, "Invalid refresh token claims")
 	}
 
-	accessToken, createErr := utils.CreateJWT(email, name, role, 5)
+	revocations := c.Locals("revocations").(*revocation.Store)
+	now := time.Now()
+
+	revoked, revokedErr := revocations.IsRevoked(refreshToken, claims, now)
+	if revokedErr != nil {
+		return response.InternalServerError(c, "Database error")
+	}
+	if revoked {
+		return response.Unauthorized(c, "Token is revoked")
+	}
+
+	userID, userErr := account.TokenUserID(revocations.DB, claims)
+	if userErr != nil {
+		return response.InternalServerError(c, "Database error")
+	}
+
+	rotation, rotateErr := revocations.Rotate(refreshToken, claims, userID, now)
+	if rotateErr != nil {
+		switch {
+		case errors.Is(rotateErr, revocation.ErrRefreshReused):
+			fid, _ := claims["fid"].(string)
+			slog.Warn("Refresh token reuse detected, revoked its family", slog.String("family", fid), slog.String("user_id", userID))
+			return response.Unauthorized(c, "Refresh token was already used")
+		case errors.Is(rotateErr, revocation.ErrFamilyRevoked):
+			return response.Unauthorized(c, "Token is revoked")
+		default:
+			return response.InternalServerError(c, "Database error")
+		}
+	}
+
+	subject, _ := claims["sub"].(string)
+
+	accessToken, createErr := utils.CreateFamilyJWT(subject, email, name, role, utils.TokenTypeAccess, rotation.FamilyID, "", 5)
 	if createErr != nil {
 		return response.InternalServerError(c, "Something went wrong")
 	}
@@ -61,43 +146,58 @@ func checkForRefresh(c *fiber.Ctx, err error) error {
 	accessCookie := utils.CreateSecureCookie("access_token", accessToken, 5*time.Minute)
 	c.Cookie(accessCookie)
 
+	// Without a next token ID, a concurrent request already exchanged the
+	// refresh token and set the cookie to the next one.
+	if rotation.NextTokenID != "" {
+		nextRefreshToken, createErr := utils.CreateFamilyJWT(subject, email, name, role, utils.TokenTypeRefresh, rotation.FamilyID, rotation.NextTokenID, 7*24*60)
+		if createErr != nil {
+			return response.InternalServerError(c, "Something went wrong")
+		}
+
+		refreshCookie := utils.CreateSecureCookie("refresh_token", nextRefreshToken, 7*24*time.Hour)
+		c.Cookie(refreshCookie)
+	}
+
 	c.Locals("user", claims)
+	c.Locals("cookieAuth", true)
 	return c.Next()
 }
 
-func checkForRevocation(c *fiber.Ctx) error {
-	token := c.Cookies("access_token")
+func checkForRevocation(c *fiber.Ctx, verifier *auth.Verifier, token string, fromCookie bool) error {
 	if token == "" {
 		return response.Unauthorized(c, "Missing or malformed token")
 	}
 
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	// The token's signature has been checked, but not its issuer and
+	// audience.
+	claims, err := verifier.Parse(token)
+	if err != nil {
+		if !fromCookie {
+			return response.Unauthorized(c, "Invalid or expired token")
 		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-
-	if err != nil || !parsedToken.Valid {
-		return checkForRefresh(c, err)
+		return checkForRefresh(c, verifier, err)
 	}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok || !parsedToken.Valid {
-		return response.Unauthorized(c, "Invalid access token claims")
+	// Refresh tokens live for days, and are only good for getting an access
+	// token from checkForRefresh, where reuse is detected. Tokens without a
+	// typ claim, from before it existed or from the auth service, are taken
+	// for access tokens.
+	if claims["typ"] == utils.TokenTypeRefresh {
+		return response.Unauthorized(c, "Refresh tokens cannot be used as access tokens")
 	}
 
-	db := c.Locals("db").(*gorm.DB)
+	revocations := c.Locals("revocations").(*revocation.Store)
 
-	var revokedToken model.RevokedToken
-	err = db.Where("token = ?", token).First(&revokedToken).Error
+	revoked, err := revocations.IsRevoked(token, claims, time.Now())
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.Locals("user", claims)
-			return c.Next()
-		}
 		return response.InternalServerError(c, "Database error")
 	}
+	if revoked {
+		return response.Unauthorized(c, "Token is revoked")
+	}
 
-	return response.Unauthorized(c, "Token is revoked")
+	c.Locals("user", claims)
+	c.Locals("token", token)
+	c.Locals("cookieAuth", fromCookie)
+	return c.Next()
 }
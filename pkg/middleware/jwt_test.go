@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/abyan-dev/productivity/pkg/auth"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func TestAccessToken(t *testing.T) {
+	tests := []struct {
+		header             string
+		cookie             string
+		precedence         string
+		expectedToken      string
+		expectedFromCookie bool
+	}{
+		{"Bearer header-token", "", auth.PrecedenceHeader, "header-token", false},
+		{"bearer header-token", "", auth.PrecedenceHeader, "header-token", false},
+		{"", "cookie-token", auth.PrecedenceHeader, "cookie-token", true},
+		{"Bearer header-token", "cookie-token", auth.PrecedenceHeader, "header-token", false},
+		{"Bearer header-token", "cookie-token", auth.PrecedenceCookie, "cookie-token", true},
+		{"Bearer header-token", "", auth.PrecedenceCookie, "header-token", false},
+		{"Basic dXNlcjpwYXNz", "cookie-token", auth.PrecedenceHeader, "cookie-token", true},
+		{"Bearer ", "", auth.PrecedenceHeader, "", false},
+		{"", "", auth.PrecedenceHeader, "", false},
+	}
+
+	app := fiber.New()
+	for _, test := range tests {
+		c := app.AcquireCtx(&fasthttp.RequestCtx{})
+		if test.header != "" {
+			c.Request().Header.Set(fiber.HeaderAuthorization, test.header)
+		}
+		if test.cookie != "" {
+			c.Request().Header.SetCookie("access_token", test.cookie)
+		}
+
+		token, fromCookie := accessToken(c, test.precedence)
+		if token != test.expectedToken || fromCookie != test.expectedFromCookie {
+			t.Errorf("accessToken(%q, %q, %q) = %q, %v, want %q, %v", test.header, test.cookie, test.precedence, token, fromCookie, test.expectedToken, test.expectedFromCookie)
+		}
+		app.ReleaseCtx(c)
+	}
+}
+
+func TestRequireAuthenticatedTokenType(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	verifier, err := auth.NewVerifier(&auth.Config{Algorithm: auth.AlgorithmHS256, Secret: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	// A refresh token that has since been exchanged for the next one in
+	// its family.
+	refreshToken, err := utils.CreateFamilyJWT("", "test@example.com", "Test User", "user", utils.TokenTypeRefresh, "family", "rotated", 7*24*60)
+	if err != nil {
+		t.Fatalf("Failed to create refresh token: %v", err)
+	}
+	accessToken, err := utils.CreateFamilyJWT("", "test@example.com", "Test User", "user", utils.TokenTypeAccess, "family", "", 5)
+	if err != nil {
+		t.Fatalf("Failed to create access token: %v", err)
+	}
+
+	app := fiber.New()
+	app.Use(RequireAuthenticated(verifier, auth.PrecedenceHeader))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+	}{
+		{"refresh token as Bearer", "Bearer " + refreshToken, ""},
+		{"refresh token as access token cookie", "", "access_token=" + refreshToken},
+		{"access token as refresh token cookie", "", "refresh_token=" + accessToken},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		if test.header != "" {
+			req.Header.Set(fiber.HeaderAuthorization, test.header)
+		}
+		if test.cookie != "" {
+			req.Header.Set(fiber.HeaderCookie, test.cookie)
+		}
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", test.name, err)
+		}
+		if resp.StatusCode != fiber.StatusUnauthorized {
+			t.Errorf("%s: got status %d, want %d", test.name, resp.StatusCode, fiber.StatusUnauthorized)
+		}
+	}
+}
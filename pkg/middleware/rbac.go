@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"slices"
+
+	"github.com/abyan-dev/productivity/pkg/rbac"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RequirePermission only lets through users whose role, taken from the
+// role claim, has the given permission. Requests made with a personal
+// access token also need the permission among the token's scopes. It must
+// come after RequireAuthenticated.
+func RequirePermission(permission rbac.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals("user").(jwt.MapClaims)
+		if !ok {
+			return response.Unauthorized(c, "Invalid user claims")
+		}
+
+		role, _ := claims["role"].(string)
+		if !rbac.Can(role, permission) {
+			return response.Forbidden(c, "Insufficient permissions")
+		}
+
+		if scopes, ok := c.Locals("scopes").([]rbac.Permission); ok && !slices.Contains(scopes, permission) {
+			return response.Forbidden(c, "Token is missing the "+string(permission)+" scope")
+		}
+
+		return c.Next()
+	}
+}
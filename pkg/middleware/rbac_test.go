@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abyan-dev/productivity/pkg/rbac"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		role       string
+		scopes     []rbac.Permission
+		permission rbac.Permission
+		expected   int
+	}{
+		{rbac.RoleUser, nil, rbac.WriteTasks, fiber.StatusOK},
+		{rbac.RoleViewer, nil, rbac.WriteTasks, fiber.StatusForbidden},
+		{rbac.RoleUser, []rbac.Permission{rbac.ReadTasks, rbac.WriteTasks}, rbac.WriteTasks, fiber.StatusOK},
+		{rbac.RoleUser, []rbac.Permission{rbac.ReadTasks}, rbac.WriteTasks, fiber.StatusForbidden},
+		{rbac.RoleUser, []rbac.Permission{}, rbac.ReadTasks, fiber.StatusForbidden},
+		{rbac.RoleViewer, []rbac.Permission{rbac.WriteTasks}, rbac.WriteTasks, fiber.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("user", jwt.MapClaims{"email": "test@example.com", "role": test.role})
+			if test.scopes != nil {
+				c.Locals("scopes", test.scopes)
+			}
+			return c.Next()
+		})
+		app.Get("/", RequirePermission(test.permission), func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if resp.StatusCode != test.expected {
+			t.Errorf("RequirePermission(%q) for role %q with scopes %v = %d, want %d", test.permission, test.role, test.scopes, resp.StatusCode, test.expected)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/response"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// ResolveUser finds the user a token belongs to, through the stable ID in
+// its sub claim or, for legacy tokens, through its email, and exposes their
+// ID in the userID local, by which all of their data is keyed. The email
+// claim is replaced with the user's current email, so tokens issued before
+// an email change show the new one. Callers authenticated by a personal
+// access token already have their ID set and are let through. It must come
+// after RequireAuthenticated.
+func ResolveUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("userID").(string); ok {
+			return c.Next()
+		}
+
+		claims, ok := c.Locals("user").(jwt.MapClaims)
+		if !ok {
+			return response.Unauthorized(c, "Invalid user claims")
+		}
+
+		subject, _ := claims["sub"].(string)
+		email, _ := claims["email"].(string)
+		if subject == "" && email == "" {
+			return response.Unauthorized(c, "Invalid user claims")
+		}
+
+		db := c.Locals("db").(*gorm.DB)
+
+		user, err := account.Resolve(db, subject, email)
+		if err != nil {
+			if errors.Is(err, account.ErrEmailTaken) {
+				return response.Forbidden(c, "Email is linked to another user")
+			}
+			return response.InternalServerError(c, "Database error")
+		}
+
+		claims["email"] = user.Email
+		c.Locals("userID", user.ID)
+		return c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// AdminAuditEntry records a request made to the admin API. Action is the
+// method and route pattern, so the email of the user acted on is only kept
+// in TargetEmail and the payload, which erasing that user clears.
+type AdminAuditEntry struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ActorEmail  string    `gorm:"type:varchar(100);not null;index" json:"actor_email"`
+	Action      string    `gorm:"type:varchar(255);not null" json:"action"`
+	TargetEmail string    `gorm:"type:varchar(100);not null;default:'';index" json:"target_email,omitempty"`
+	Payload     string    `gorm:"type:text;not null;default:''" json:"payload,omitempty"`
+	Status      int       `gorm:"not null" json:"status"`
+	CreatedAt   time.Time `gorm:"type:timestamp;not null;index" json:"created_at"`
+}
+
+// TokenRevocation revokes every token issued to a user before
+// RevokedBefore, whether or not it is known to this service. RevokedBefore
+// is kept to the second, the precision of the iat claim. Once ExpiresAt has
+// passed every token it revoked has expired too, and it can be deleted.
+type TokenRevocation struct {
+	UserID        string    `gorm:"type:varchar(100);primaryKey" json:"user_id"`
+	RevokedBefore time.Time `gorm:"type:timestamp;not null" json:"revoked_before"`
+	ExpiresAt     time.Time `gorm:"type:timestamp;not null;index" json:"expires_at"`
+}
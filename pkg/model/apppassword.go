@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// AppPassword lets clients that cannot use the JWT cookies, such as CalDAV
+// clients, authenticate with HTTP Basic auth. Only the SHA-256 hash of the
+// password is stored. Role is the role of the user who created it, which
+// bounds what it can do; passwords from before roles existed were made by
+// regular users.
+type AppPassword struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Name         string     `gorm:"type:varchar(100);not null" json:"name"`
+	PasswordHash string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	Role         string     `gorm:"type:varchar(20);not null;default:'user'" json:"role"`
+	CreatedAt    time.Time  `gorm:"type:timestamp;not null" json:"created_at"`
+	LastUsedAt   *time.Time `gorm:"type:timestamp" json:"last_used_at"`
+	UserID       string     `gorm:"type:varchar(100);not null;index" json:"user_id"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// CalendarFeed is a user's subscribable iCalendar feed. The feed is
+// authenticated by a secret token in its URL, of which only the SHA-256 hash
+// is stored.
+type CalendarFeed struct {
+	UserID          string    `gorm:"primaryKey;type:varchar(100)" json:"user_id"`
+	TokenHash       string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	IncludeSessions bool      `gorm:"not null;default:false" json:"include_sessions"`
+	TasksAsTodos    bool      `gorm:"not null;default:false" json:"tasks_as_todos"`
+	CreatedAt       time.Time `gorm:"type:timestamp;not null" json:"created_at"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+const (
+	ErasureRequestedByUser  = "user"
+	ErasureRequestedByAdmin = "admin"
+)
+
+// ErasureRequest schedules the erasure of a user's data once the grace
+// period ends. Until then it can be cancelled by deleting it.
+type ErasureRequest struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	RequestedBy string    `gorm:"type:varchar(10);not null" json:"requested_by"`
+	RequestedAt time.Time `gorm:"type:timestamp;not null" json:"requested_at"`
+	EraseAfter  time.Time `gorm:"type:timestamp;not null;index" json:"erase_after"`
+	UserID      string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"user_id"`
+}
+
+// ErasureAudit records that an erasure took place. It deliberately holds no
+// personal data, not even the email; Deleted is a JSON object of the number
+// of rows deleted per table.
+type ErasureAudit struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	RequestID   uint      `gorm:"not null" json:"request_id"`
+	RequestedBy string    `gorm:"type:varchar(10);not null" json:"requested_by"`
+	RequestedAt time.Time `gorm:"type:timestamp;not null" json:"requested_at"`
+	ErasedAt    time.Time `gorm:"type:timestamp;not null" json:"erased_at"`
+	Deleted     string    `gorm:"type:text;not null" json:"deleted"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+const (
+	ExportPending   = "pending"
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+	ExportExpired   = "expired"
+)
+
+// ExportJob is an export of a user's data, generated in the background.
+// Archive holds the ZIP file until ExpiresAt.
+type ExportJob struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Status     string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Archive    []byte     `gorm:"type:bytea" json:"-"`
+	Size       int64      `gorm:"not null;default:0" json:"size"`
+	LastError  string     `gorm:"type:text" json:"last_error"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;not null" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"type:timestamp;not null" json:"updated_at"`
+	FinishedAt *time.Time `gorm:"type:timestamp" json:"finished_at"`
+	ExpiresAt  *time.Time `gorm:"type:timestamp" json:"expires_at"`
+	UserID     string     `gorm:"type:varchar(100);not null;index" json:"user_id"`
+}
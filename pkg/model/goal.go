@@ -0,0 +1,50 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	GoalFocusMinutes   = "focus_minutes"
+	GoalTasksCompleted = "tasks_completed"
+
+	GoalDaily  = "daily"
+	GoalWeekly = "weekly"
+)
+
+type Goal struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Kind      string    `gorm:"type:varchar(20);not null" json:"kind"`
+	Period    string    `gorm:"type:varchar(10);not null" json:"period"`
+	Target    float64   `gorm:"not null" json:"target"`
+	RestDays  string    `gorm:"type:varchar(70)" json:"rest_days"`
+	CreatedAt time.Time `gorm:"type:timestamp;not null" json:"created_at"`
+	UserID    string    `gorm:"type:varchar(100);not null;index" json:"user_id"`
+
+	// MetNotifiedFor is the start of the latest period the user was notified
+	// about meeting the goal in.
+	MetNotifiedFor *time.Time `gorm:"type:timestamp" json:"-"`
+}
+
+func IsValidGoalKind(kind string) bool {
+	return kind == GoalFocusMinutes || kind == GoalTasksCompleted
+}
+
+func IsValidGoalPeriod(period string) bool {
+	return period == GoalDaily || period == GoalWeekly
+}
+
+// IsRestDay reports whether the given weekday is one of the goal's rest days.
+// Rest days are stored as a comma-separated list of lowercase weekday names.
+func (g Goal) IsRestDay(day time.Weekday) bool {
+	if g.RestDays == "" {
+		return false
+	}
+	for _, name := range strings.Split(g.RestDays, ",") {
+		if name == strings.ToLower(day.String()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+const (
+	ImportPending    = "pending"
+	ImportRunning    = "running"
+	ImportCompleted  = "completed"
+	ImportFailed     = "failed"
+	ImportRolledBack = "rolled_back"
+)
+
+// ImportJob is an import of tasks from another to-do app, run in the
+// background. Items holds the validated rows still to be created and
+// RowErrors the rows that were rejected, both as JSON.
+type ImportJob struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Source       string     `gorm:"type:varchar(20);not null" json:"source"`
+	Status       string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Total        int        `gorm:"not null;default:0" json:"total"`
+	Processed    int        `gorm:"not null;default:0" json:"processed"`
+	Created      int        `gorm:"not null;default:0" json:"created"`
+	Duplicates   int        `gorm:"not null;default:0" json:"duplicates"`
+	Invalid      int        `gorm:"not null;default:0" json:"invalid"`
+	Items        string     `gorm:"type:text;not null" json:"-"`
+	RowErrors    string     `gorm:"type:text;not null" json:"-"`
+	LastError    string     `gorm:"type:text" json:"last_error"`
+	CreatedAt    time.Time  `gorm:"type:timestamp;not null" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"type:timestamp;not null" json:"updated_at"`
+	FinishedAt   *time.Time `gorm:"type:timestamp" json:"finished_at"`
+	RolledBackAt *time.Time `gorm:"type:timestamp" json:"rolled_back_at"`
+	UserID       string     `gorm:"type:varchar(100);not null;index" json:"user_id"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+const (
+	InterruptionInternal = "internal"
+	InterruptionExternal = "external"
+)
+
+type Interruption struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	SessionID  uint      `gorm:"not null;index" json:"session_id"`
+	OccurredAt time.Time `gorm:"type:timestamp;not null" json:"occurred_at"`
+	Kind       string    `gorm:"type:varchar(20);not null" json:"kind"`
+	Note       string    `gorm:"type:text" json:"note"`
+	UserID     string    `gorm:"type:varchar(100);not null;index" json:"user_id"`
+}
+
+func IsValidInterruptionKind(kind string) bool {
+	return kind == InterruptionInternal || kind == InterruptionExternal
+}
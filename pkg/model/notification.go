@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Notification is an entry in a user's in-app notification inbox.
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Kind      string     `gorm:"type:varchar(50);not null" json:"kind"`
+	Title     string     `gorm:"type:varchar(200);not null" json:"title"`
+	Body      string     `gorm:"type:text" json:"body"`
+	TaskID    *uint      `json:"task_id"`
+	ReadAt    *time.Time `gorm:"type:timestamp" json:"read_at"`
+	CreatedAt time.Time  `gorm:"type:timestamp;not null;index:idx_notifications_user_created,priority:2" json:"created_at"`
+	UserID    string     `gorm:"type:varchar(100);not null;index:idx_notifications_user_created,priority:1;index:idx_notifications_unread,where:read_at IS NULL" json:"user_id"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// OutboxEvent is a domain event written in the same transaction as the
+// change it describes, and published to the event sinks afterwards. Events
+// of a user are published in ID order. PublishedSinks is a comma-separated
+// list of the sinks that have accepted the event so far, so that a retry
+// only goes to the others. An event that keeps failing is dead-lettered
+// rather than holding back the user's later events forever.
+type OutboxEvent struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Type           string     `gorm:"type:varchar(50);not null" json:"type"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;not null" json:"created_at"`
+	PublishedAt    *time.Time `gorm:"type:timestamp" json:"published_at"`
+	PublishedSinks string     `gorm:"type:text;not null;default:''" json:"published_sinks"`
+	DeadLetteredAt *time.Time `gorm:"type:timestamp" json:"dead_lettered_at"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"type:timestamp;not null" json:"next_attempt_at"`
+	LastError      string     `gorm:"type:text" json:"last_error"`
+	UserID         string     `gorm:"type:varchar(100);not null;index:idx_outbox_events_unpublished,where:published_at IS NULL" json:"user_id"`
+}
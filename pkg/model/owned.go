@@ -0,0 +1,35 @@
+package model
+
+// OwnedTable is a table whose rows belong to a user, keyed by their ID.
+type OwnedTable struct {
+	Table string
+	Model interface{}
+}
+
+// OwnedTables lists every table keyed by the user's ID, children before
+// their parents. Erasing a user or adopting a legacy user goes through this
+// list, so new tables with a UserID column must be added to it.
+var OwnedTables = []OwnedTable{
+	{"interruptions", &Interruption{}},
+	{"reminders", &Reminder{}},
+	{"notifications", &Notification{}},
+	{"webhook_deliveries", &WebhookDelivery{}},
+	{"webhook_endpoints", &WebhookEndpoint{}},
+	{"tasks", &Task{}},
+	{"pomodoro_sessions", &PomodoroSession{}},
+	{"subjects", &Subject{}},
+	{"goals", &Goal{}},
+	{"user_settings", &UserSettings{}},
+	{"notification_preferences", &NotificationPreferences{}},
+	{"daily_rollups", &DailyRollup{}},
+	{"pending_rollups", &PendingRollup{}},
+	{"outbox_events", &OutboxEvent{}},
+	{"calendar_feeds", &CalendarFeed{}},
+	{"app_passwords", &AppPassword{}},
+	{"import_jobs", &ImportJob{}},
+	{"export_jobs", &ExportJob{}},
+	{"token_revocations", &TokenRevocation{}},
+	{"personal_access_tokens", &PersonalAccessToken{}},
+	{"revoked_tokens", &RevokedToken{}},
+	{"refresh_families", &RefreshFamily{}},
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestEveryOwnedModelIsListed guards against new tables keyed by the user's
+// ID being left out of erasure and the adoption of legacy users.
+func TestEveryOwnedModelIsListed(t *testing.T) {
+	packages, err := parser.ParseDir(token.NewFileSet(), ".", func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed := map[string]bool{}
+	for _, owned := range OwnedTables {
+		listed[reflect.TypeOf(owned.Model).Elem().Name()] = true
+	}
+
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			ast.Inspect(file, func(node ast.Node) bool {
+				spec, ok := node.(*ast.TypeSpec)
+				if !ok {
+					return true
+				}
+				fields, ok := spec.Type.(*ast.StructType)
+				if !ok {
+					return false
+				}
+				for _, field := range fields.Fields.List {
+					for _, name := range field.Names {
+						if name.Name == "UserID" && !listed[spec.Name.Name] && spec.Name.Name != "ErasureRequest" {
+							t.Errorf("%s is keyed by the user's ID but not in OwnedTables", spec.Name.Name)
+						}
+					}
+				}
+				return false
+			})
+		}
+	}
+}
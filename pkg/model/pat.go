@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// PersonalAccessTokenPrefix starts every personal access token, which is how
+// they are told apart from JWTs in the Authorization header.
+const PersonalAccessTokenPrefix = "pat_"
+
+// PersonalAccessToken lets scripts and integrations authenticate with a
+// long-lived Bearer token, limited to Scopes, a space-separated list of
+// permissions. Role is the role of the user who created it, which bounds
+// the scopes. Only the SHA-256 hash of the token is stored.
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	TokenHash  string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"type:varchar(255);not null" json:"scopes"`
+	Role       string     `gorm:"type:varchar(20);not null" json:"role"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;not null" json:"created_at"`
+	ExpiresAt  *time.Time `gorm:"type:timestamp" json:"expires_at"`
+	LastUsedAt *time.Time `gorm:"type:timestamp" json:"last_used_at"`
+	UserID     string     `gorm:"type:varchar(100);not null;index" json:"user_id"`
+}
@@ -5,8 +5,24 @@ import (
 )
 
 type PomodoroSession struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	StartTime time.Time `gorm:"type:timestamp;not null" json:"start_time"`
-	EndTime   time.Time `gorm:"type:timestamp;not null" json:"end_time"`
-	UserEmail string    `gorm:"type:varchar(100);not null" json:"user_email"`
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	StartTime time.Time  `gorm:"type:timestamp;not null;index:idx_pomodoro_sessions_user_start,priority:2" json:"start_time"`
+	EndTime   *time.Time `gorm:"type:timestamp" json:"end_time"`
+	IsManual  bool       `gorm:"type:boolean;not null;default:false" json:"is_manual"`
+	SubjectID *uint      `gorm:"index" json:"subject_id"`
+	UserID    string     `gorm:"type:varchar(100);not null;index:idx_pomodoro_sessions_user_start,priority:1" json:"user_id"`
+}
+
+// IsRunning reports whether the session has been started but not yet stopped.
+func (s PomodoroSession) IsRunning() bool {
+	return s.EndTime == nil
+}
+
+// Duration returns how long the session lasted, or how long it has been
+// running so far if it has not been stopped yet.
+func (s PomodoroSession) Duration() time.Duration {
+	if s.EndTime == nil {
+		return time.Since(s.StartTime)
+	}
+	return s.EndTime.Sub(s.StartTime)
 }
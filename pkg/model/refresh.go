@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// RefreshFamily follows a chain of refresh tokens, each issued in exchange
+// for the one before. Only CurrentTokenID may be exchanged; presenting an
+// earlier token means it was stolen, and revokes the whole family along
+// with the access tokens issued to it. PreviousTokenID is kept to tell
+// concurrent refreshes with the same token apart from reuse.
+type RefreshFamily struct {
+	ID              string     `gorm:"type:varchar(64);primaryKey" json:"id"`
+	CurrentTokenID  string     `gorm:"type:varchar(64);not null" json:"-"`
+	PreviousTokenID string     `gorm:"type:varchar(64);not null;default:'';index" json:"-"`
+	RotatedAt       *time.Time `gorm:"type:timestamp" json:"rotated_at"`
+	CreatedAt       time.Time  `gorm:"type:timestamp;not null" json:"created_at"`
+	ExpiresAt       time.Time  `gorm:"type:timestamp;not null;index" json:"expires_at"`
+	RevokedAt       *time.Time `gorm:"type:timestamp" json:"revoked_at"`
+	UserID          string     `gorm:"type:varchar(100);not null;index" json:"user_id"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+const (
+	ReminderPending = "pending"
+	ReminderSending = "sending"
+	ReminderSent    = "sent"
+	ReminderSkipped = "skipped"
+	ReminderFailed  = "failed"
+)
+
+// Reminder fires a notification a given number of minutes before the due
+// date of a task. DeliveredChannels is a comma-separated list of the
+// channels that have delivered it, so that a retry only goes to the others.
+type Reminder struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	TaskID            uint       `gorm:"not null;index" json:"task_id"`
+	OffsetMinutes     int        `gorm:"not null" json:"offset_minutes"`
+	FireAt            time.Time  `gorm:"type:timestamp;not null;index:idx_reminders_status_fire_at,priority:2" json:"fire_at"`
+	Status            string     `gorm:"type:varchar(10);not null;default:'pending';index:idx_reminders_status_fire_at,priority:1" json:"status"`
+	Attempts          int        `gorm:"not null;default:0" json:"attempts"`
+	LastError         string     `gorm:"type:text" json:"last_error"`
+	DeliveredChannels string     `gorm:"type:text;not null;default:''" json:"delivered_channels"`
+	SentAt            *time.Time `gorm:"type:timestamp" json:"sent_at"`
+	UserID            string     `gorm:"type:varchar(100);not null;index" json:"user_id"`
+}
+
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelInApp   = "in_app"
+)
+
+// NotificationPreferences controls how a user is notified. Channels is a
+// comma-separated list of channel names. During quiet hours, given as local
+// hours of day and possibly wrapping around midnight, reminders are held back
+// until the quiet hours end.
+type NotificationPreferences struct {
+	UserID          string `gorm:"primaryKey;type:varchar(100)" json:"user_id"`
+	Channels        string `gorm:"type:varchar(50);not null;default:'in_app'" json:"channels"`
+	WebhookURL      string `gorm:"type:varchar(2048)" json:"webhook_url"`
+	QuietHoursStart *int   `json:"quiet_hours_start"`
+	QuietHoursEnd   *int   `json:"quiet_hours_end"`
+}
+
+func IsValidChannel(channel string) bool {
+	return channel == ChannelEmail || channel == ChannelWebhook || channel == ChannelInApp
+}
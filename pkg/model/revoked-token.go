@@ -1,6 +1,15 @@
 package model
 
+import "time"
+
+// RevokedToken revokes a single token before it expires. TokenID is the
+// token's jti claim, or the SHA-256 hash of the token if it has none, so
+// tokens themselves are never stored. Rows can be deleted once ExpiresAt
+// has passed, as the token is no longer accepted anyway.
 type RevokedToken struct {
-	ID    uint   `json:"id" gorm:"primaryKey"`
-	Token string `json:"token"`
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TokenID   string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"token_id"`
+	RevokedAt time.Time `gorm:"type:timestamp;not null" json:"revoked_at"`
+	ExpiresAt time.Time `gorm:"type:timestamp;not null;index" json:"expires_at"`
+	UserID    string    `gorm:"type:varchar(100);not null;default:'';index" json:"user_id"`
 }
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// DailyRollup holds pre-aggregated counters for one user and one day in the
+// user's time zone. Sessions, and the interruptions logged during them, count
+// towards the day they started on.
+type DailyRollup struct {
+	UserID                string    `gorm:"primaryKey;type:varchar(100)" json:"user_id"`
+	Day                   time.Time `gorm:"primaryKey;type:date" json:"day"`
+	FocusMinutes          float64   `gorm:"not null;default:0" json:"focus_minutes"`
+	ManualFocusMinutes    float64   `gorm:"not null;default:0" json:"manual_focus_minutes"`
+	SessionCount          int       `gorm:"not null;default:0" json:"session_count"`
+	ManualSessionCount    int       `gorm:"not null;default:0" json:"manual_session_count"`
+	InternalInterruptions int       `gorm:"not null;default:0" json:"internal_interruptions"`
+	ExternalInterruptions int       `gorm:"not null;default:0" json:"external_interruptions"`
+	TasksCompleted        int       `gorm:"not null;default:0" json:"tasks_completed"`
+	TasksCreated          int       `gorm:"not null;default:0" json:"tasks_created"`
+	UpdatedAt             time.Time `gorm:"type:timestamp" json:"updated_at"`
+}
+
+// Add accumulates the counters of other into r.
+func (r *DailyRollup) Add(other DailyRollup) {
+	r.FocusMinutes += other.FocusMinutes
+	r.ManualFocusMinutes += other.ManualFocusMinutes
+	r.SessionCount += other.SessionCount
+	r.ManualSessionCount += other.ManualSessionCount
+	r.InternalInterruptions += other.InternalInterruptions
+	r.ExternalInterruptions += other.ExternalInterruptions
+	r.TasksCompleted += other.TasksCompleted
+	r.TasksCreated += other.TasksCreated
+}
+
+// IsEmpty reports whether no activity was recorded for the day.
+func (r DailyRollup) IsEmpty() bool {
+	return r.SessionCount == 0 && r.InternalInterruptions == 0 && r.ExternalInterruptions == 0 &&
+		r.TasksCompleted == 0 && r.TasksCreated == 0
+}
+
+// PendingRollup marks a user's day whose rollup is out of date and still has
+// to be refreshed by the catch-up worker.
+type PendingRollup struct {
+	UserID    string    `gorm:"primaryKey;type:varchar(100)" json:"user_id"`
+	Day       time.Time `gorm:"primaryKey;type:date" json:"day"`
+	CreatedAt time.Time `gorm:"type:timestamp" json:"created_at"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+type UserSettings struct {
+	UserID              string     `gorm:"primaryKey;type:varchar(100)" json:"user_id"`
+	TimeZone            string     `gorm:"type:varchar(64);not null;default:'UTC'" json:"time_zone"`
+	WeeklyReportEnabled bool       `gorm:"not null;default:false" json:"weekly_report_enabled"`
+	WeeklyReportDay     string     `gorm:"type:varchar(10);not null;default:'sunday'" json:"weekly_report_day"`
+	WeeklyReportHour    int        `gorm:"not null;default:18" json:"weekly_report_hour"`
+	LastWeeklyReportAt  *time.Time `gorm:"type:timestamp" json:"last_weekly_report_at"`
+}
+
+// Location returns the user's configured time zone, falling back to UTC if it
+// is unset or unknown.
+func (s UserSettings) Location() *time.Location {
+	if s.TimeZone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// ParseWeekday returns the weekday with the given case-insensitive English
+// name, such as "sunday".
+func ParseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(name, day.String()) {
+			return day, true
+		}
+	}
+	return time.Sunday, false
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type Subject struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	Name              string     `gorm:"type:varchar(100);not null" json:"name"`
+	Color             string     `gorm:"type:varchar(7)" json:"color"`
+	TermStart         *time.Time `gorm:"type:timestamp" json:"term_start"`
+	TermEnd           *time.Time `gorm:"type:timestamp" json:"term_end"`
+	WeeklyTargetHours float64    `gorm:"not null;default:0" json:"weekly_target_hours"`
+	UserID            string     `gorm:"type:varchar(100);not null;index" json:"user_id"`
+}
@@ -3,10 +3,28 @@ package model
 import "time"
 
 type Task struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Title       string    `gorm:"type:varchar(100);not null" json:"title"`
-	Description string    `gorm:"type:text" json:"description"`
-	DueDate     time.Time `gorm:"type:timestamp" json:"due_date"`
-	IsComplete  bool      `gorm:"type:boolean" json:"is_complete"`
-	UserEmail   string    `gorm:"type:varchar(100);not null" json:"user_email"`
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Title       string     `gorm:"type:varchar(100);not null" json:"title"`
+	Description string     `gorm:"type:text" json:"description"`
+	DueDate     time.Time  `gorm:"type:timestamp" json:"due_date"`
+	IsComplete  bool       `gorm:"type:boolean" json:"is_complete"`
+	CompletedAt *time.Time `gorm:"type:timestamp" json:"completed_at"`
+	SubjectID   *uint      `gorm:"index" json:"subject_id"`
+	CreatedAt   time.Time  `gorm:"type:timestamp" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"type:timestamp;not null;default:(now() at time zone 'utc')" json:"updated_at"`
+	UserID      string     `gorm:"type:varchar(100);not null;index:idx_tasks_user_uid,unique,where:uid <> '';index:idx_tasks_user_dav_name,unique,where:dav_name <> ''" json:"user_id"`
+
+	// UID and DAVName are the iCalendar UID and the CalDAV resource name of
+	// tasks created by calendar clients, each unique per user. Other tasks
+	// get derived ones.
+	UID     string `gorm:"type:varchar(255);not null;default:'';index:idx_tasks_user_uid,unique,where:uid <> ''" json:"uid,omitempty"`
+	DAVName string `gorm:"type:varchar(255);not null;default:'';index:idx_tasks_user_dav_name,unique,where:dav_name <> ''" json:"dav_name,omitempty"`
+
+	// ImportJobID is the import that created the task, if any, so that the
+	// import can be rolled back.
+	ImportJobID *uint `gorm:"index" json:"import_job_id,omitempty"`
+
+	// OverdueNotifiedAt is when the user was notified that the task is past
+	// its due date. It is cleared whenever the due date changes.
+	OverdueNotifiedAt *time.Time `gorm:"type:timestamp" json:"-"`
 }
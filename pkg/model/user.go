@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// User links the stable ID that the rest of the tables are keyed by to the
+// user's current email. The ID is the one the auth service puts in the JWT
+// sub claim, or, for users only seen with legacy tokens that have none, one
+// derived from their email until a token with a sub claim arrives.
+type User struct {
+	ID        string    `gorm:"primaryKey;type:varchar(100)" json:"id"`
+	Email     string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"email"`
+	CreatedAt time.Time `gorm:"type:timestamp;not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null" json:"updated_at"`
+
+	// PrunedTaskEventID is the ID of the latest of the user's task events
+	// that the outbox janitor has deleted. CalDAV sync tokens before it can
+	// no longer be answered.
+	PrunedTaskEventID uint `gorm:"not null;default:0" json:"-"`
+}
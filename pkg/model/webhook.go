@@ -0,0 +1,62 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// ServiceWideWebhook is the owner of the endpoints registered by admins,
+// which receive the events of every user.
+const ServiceWideWebhook = ""
+
+// WebhookEndpoint is a URL that receives the events it subscribes to.
+// EventTypes is a comma-separated list of event types. Secret signs each
+// delivery and is only revealed when the endpoint is created.
+type WebhookEndpoint struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	URL        string    `gorm:"type:varchar(2048);not null" json:"url"`
+	EventTypes string    `gorm:"type:text;not null" json:"event_types"`
+	Secret     string    `gorm:"type:varchar(100);not null" json:"-"`
+	Active     bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt  time.Time `gorm:"type:timestamp;not null" json:"created_at"`
+	UserID     string    `gorm:"type:varchar(100);not null;index" json:"user_id"`
+}
+
+// Subscribes reports whether the endpoint receives events of the given type.
+func (e WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, subscribed := range strings.Split(e.EventTypes, ",") {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent, or to be sent, to an endpoint, along
+// with the outcome of the latest attempt.
+type WebhookDelivery struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	EndpointID    uint       `gorm:"not null;uniqueIndex:idx_webhook_deliveries_endpoint_event,priority:1" json:"endpoint_id"`
+	EventID       string     `gorm:"type:varchar(40);not null;uniqueIndex:idx_webhook_deliveries_endpoint_event,priority:2" json:"event_id"`
+	EventType     string     `gorm:"type:varchar(50);not null" json:"event_type"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Status        string     `gorm:"type:varchar(10);not null;default:'pending';index:idx_webhook_deliveries_status_next,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"type:timestamp;not null;index:idx_webhook_deliveries_status_next,priority:2" json:"next_attempt_at"`
+	ResponseCode  *int       `json:"response_code"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	DeliveredAt   *time.Time `gorm:"type:timestamp" json:"delivered_at"`
+	CreatedAt     time.Time  `gorm:"type:timestamp;not null" json:"created_at"`
+	UserID        string     `gorm:"type:varchar(100);not null;index" json:"user_id"`
+
+	// LockedUntil is when the claim of the deliverer attempting the delivery
+	// lapses, so that a delivery whose deliverer died mid-send is attempted
+	// again.
+	LockedUntil *time.Time `gorm:"type:timestamp" json:"-"`
+}
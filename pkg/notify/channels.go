@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/mail"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+// EmailChannel sends messages to the user's email address.
+type EmailChannel struct {
+	DB     *gorm.DB
+	Sender mail.Sender
+}
+
+func (ch *EmailChannel) Name() string {
+	return model.ChannelEmail
+}
+
+func (ch *EmailChannel) Deliver(msg Message, prefs model.NotificationPreferences) error {
+	var user model.User
+	if err := ch.DB.Select("email").Where("id = ?", msg.UserID).First(&user).Error; err != nil {
+		return err
+	}
+
+	return ch.Sender.Send(mail.Message{
+		To:      user.Email,
+		Subject: msg.Title,
+		Text:    msg.Body,
+	})
+}
+
+// WebhookChannel posts messages as JSON to the URL configured by the user.
+type WebhookChannel struct {
+	Client *http.Client
+}
+
+func NewWebhookChannel() *WebhookChannel {
+	return &WebhookChannel{Client: utils.NewPublicHTTPClient(10 * time.Second)}
+}
+
+func (ch *WebhookChannel) Name() string {
+	return model.ChannelWebhook
+}
+
+func (ch *WebhookChannel) Deliver(msg Message, prefs model.NotificationPreferences) error {
+	if prefs.WebhookURL == "" {
+		return errors.New("no webhook URL configured")
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := ch.Client.Post(prefs.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// InAppChannel stores messages in the user's notification inbox.
+type InAppChannel struct {
+	DB *gorm.DB
+}
+
+func (ch *InAppChannel) Name() string {
+	return model.ChannelInApp
+}
+
+func (ch *InAppChannel) Deliver(msg Message, prefs model.NotificationPreferences) error {
+	notification := model.Notification{
+		Kind:      msg.Kind,
+		Title:     msg.Title,
+		Body:      msg.Body,
+		TaskID:    msg.TaskID,
+		CreatedAt: msg.CreatedAt,
+		UserID:    msg.UserID,
+	}
+	return ch.DB.Create(&notification).Error
+}
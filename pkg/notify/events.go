@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/goal"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/outbox"
+	"github.com/abyan-dev/productivity/pkg/rollup"
+	"gorm.io/gorm"
+)
+
+const (
+	KindTaskOverdue       = "task_overdue"
+	KindGoalMet           = "goal_met"
+	KindSessionAutoClosed = "session_auto_closed"
+)
+
+// overdueWindow bounds how long after its due date a task is still reported
+// as overdue, so that old tasks are not all reported at once.
+const overdueWindow = 24 * time.Hour
+
+// CheckGoals notifies a user about every goal whose current period they have
+// just met. Each goal is reported at most once per period, claimed by
+// atomically advancing its MetNotifiedFor.
+func CheckGoals(db *gorm.DB, notifier Notifier, userID string, now time.Time) error {
+	var goals []model.Goal
+	if err := db.Where("user_id = ?", userID).Find(&goals).Error; err != nil {
+		return err
+	}
+
+	location, err := rollup.UserLocation(db, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range goals {
+		periodStart := goal.PeriodStart(g, now, location).UTC()
+		if g.MetNotifiedFor != nil && !g.MetNotifiedFor.Before(periodStart) {
+			continue
+		}
+
+		progress, err := goal.Track(db, g, now, location)
+		if err != nil {
+			return err
+		}
+		if !progress.Current.Met {
+			continue
+		}
+
+		result := db.Model(&model.Goal{}).
+			Where("id = ? AND (met_notified_for IS NULL OR met_notified_for < ?)", g.ID, periodStart).
+			Update("met_notified_for", periodStart)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		if err := notifier.Notify(goalMetMessage(g, progress)); err != nil {
+			slog.Error("Failed to send goal notification", slog.Uint64("goal_id", uint64(g.ID)), slog.String("error", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+func goalMetMessage(g model.Goal, progress goal.Progress) Message {
+	period := "today's"
+	if g.Period == model.GoalWeekly {
+		period = "this week's"
+	}
+
+	target := fmt.Sprintf("%g focus minutes", g.Target)
+	if g.Kind == model.GoalTasksCompleted {
+		target = fmt.Sprintf("%g completed tasks", g.Target)
+	}
+
+	body := fmt.Sprintf("You reached %s goal of %s.", period, target)
+	if progress.CurrentStreak > 1 {
+		body += fmt.Sprintf(" That makes a streak of %d.", progress.CurrentStreak)
+	}
+
+	return Message{
+		UserID: g.UserID,
+		Kind:   KindGoalMet,
+		Title:  "Goal reached",
+		Body:   body,
+	}
+}
+
+// Sweeper periodically looks for events that are not caused by a request,
+// namely tasks becoming overdue and Pomodoro sessions that were left running
+// for too long, which it closes.
+type Sweeper struct {
+	DB             *gorm.DB
+	Notifier       Notifier
+	Interval       time.Duration
+	AutoCloseAfter time.Duration
+}
+
+func NewSweeper(db *gorm.DB, notifier Notifier) *Sweeper {
+	return &Sweeper{
+		DB:             db,
+		Notifier:       notifier,
+		Interval:       time.Minute,
+		AutoCloseAfter: 4 * time.Hour,
+	}
+}
+
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(time.Now()); err != nil {
+				slog.Error("Failed to sweep notification events", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+func (s *Sweeper) RunOnce(now time.Time) error {
+	if err := s.notifyOverdueTasks(now); err != nil {
+		return err
+	}
+	return s.closeStaleSessions(now)
+}
+
+// notifyOverdueTasks reports tasks that recently passed their due date. Each
+// task is claimed with a conditional update, so it is reported only once
+// even with several replicas running.
+func (s *Sweeper) notifyOverdueTasks(now time.Time) error {
+	var tasks []model.Task
+	err := s.DB.Where("is_complete = ? AND overdue_notified_at IS NULL AND due_date <= ? AND due_date > ?", false, now.UTC(), now.Add(-overdueWindow).UTC()).
+		Find(&tasks).Error
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		result := s.DB.Model(&model.Task{}).
+			Where("id = ? AND overdue_notified_at IS NULL", task.ID).
+			UpdateColumn("overdue_notified_at", now.UTC())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		location, err := rollup.UserLocation(s.DB, task.UserID)
+		if err != nil {
+			return err
+		}
+
+		taskID := task.ID
+		msg := Message{
+			UserID: task.UserID,
+			Kind:   KindTaskOverdue,
+			Title:  fmt.Sprintf("Overdue: %s", task.Title),
+			Body:   fmt.Sprintf("%q was due at %s.", task.Title, task.DueDate.In(location).Format("Mon, 02 Jan 2006 15:04 MST")),
+			TaskID: &taskID,
+		}
+		if err := s.Notifier.Notify(msg); err != nil {
+			slog.Error("Failed to send overdue notification", slog.Uint64("task_id", uint64(task.ID)), slog.String("error", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// closeStaleSessions ends Pomodoro sessions that have been running for longer
+// than AutoCloseAfter, as they were most likely forgotten, at the moment they
+// reached that length.
+func (s *Sweeper) closeStaleSessions(now time.Time) error {
+	var sessions []model.PomodoroSession
+	err := s.DB.Where("end_time IS NULL AND start_time <= ?", now.Add(-s.AutoCloseAfter).UTC()).
+		Find(&sessions).Error
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		endTime := session.StartTime.Add(s.AutoCloseAfter).UTC()
+		session.EndTime = &endTime
+
+		closed := false
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&model.PomodoroSession{}).
+				Where("id = ? AND end_time IS NULL", session.ID).
+				Update("end_time", endTime)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			closed = true
+			return outbox.Record(tx, session.UserID, outbox.EventPomodoroStopped, session)
+		})
+		if err != nil {
+			return err
+		}
+		if !closed {
+			continue
+		}
+
+		if err := rollup.Touch(s.DB, session.UserID, session.StartTime); err != nil {
+			slog.Error("Failed to refresh rollups", slog.String("user_id", session.UserID), slog.String("error", err.Error()))
+		}
+
+		msg := Message{
+			UserID: session.UserID,
+			Kind:   KindSessionAutoClosed,
+			Title:  "Pomodoro session stopped",
+			Body: fmt.Sprintf("Your Pomodoro session was still running after %g hours, so it was stopped automatically. You can adjust its end time in your session history.",
+				s.AutoCloseAfter.Hours()),
+		}
+		if err := s.Notifier.Notify(msg); err != nil {
+			slog.Error("Failed to send session notification", slog.Uint64("session_id", uint64(session.ID)), slog.String("error", err.Error()))
+		}
+	}
+
+	return nil
+}
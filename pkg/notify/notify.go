@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"gorm.io/gorm"
+)
+
+// Message is a notification addressed to a single user.
+type Message struct {
+	UserID    string    `json:"user_id"`
+	Kind      string    `json:"kind"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	TaskID    *uint     `json:"task_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Notifier sends messages to users.
+type Notifier interface {
+	Notify(msg Message) error
+	// NotifyExcept is Notify for retries: it skips the channels that
+	// already delivered msg, and returns those that have by now.
+	NotifyExcept(msg Message, delivered []string) ([]string, error)
+}
+
+// Channel delivers messages through one medium, such as email.
+type Channel interface {
+	Name() string
+	Deliver(msg Message, prefs model.NotificationPreferences) error
+}
+
+// Dispatcher delivers messages through every channel the recipient enabled.
+type Dispatcher struct {
+	DB       *gorm.DB
+	Channels map[string]Channel
+}
+
+func NewDispatcher(db *gorm.DB, channels ...Channel) *Dispatcher {
+	d := &Dispatcher{DB: db, Channels: map[string]Channel{}}
+	for _, channel := range channels {
+		d.Channels[channel.Name()] = channel
+	}
+	return d
+}
+
+// Notify delivers msg through the recipient's enabled channels. Delivery is
+// attempted on every channel even if some fail, and the failures are joined
+// into the returned error.
+func (d *Dispatcher) Notify(msg Message) error {
+	_, err := d.NotifyExcept(msg, nil)
+	return err
+}
+
+func (d *Dispatcher) NotifyExcept(msg Message, delivered []string) ([]string, error) {
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now().UTC()
+	}
+
+	prefs, err := LoadPreferences(d.DB, msg.UserID)
+	if err != nil {
+		return delivered, err
+	}
+
+	return d.deliver(msg, prefs, delivered)
+}
+
+func (d *Dispatcher) deliver(msg Message, prefs model.NotificationPreferences, delivered []string) ([]string, error) {
+	done := append([]string{}, delivered...)
+	var errs []error
+	for _, name := range EnabledChannels(prefs) {
+		channel, ok := d.Channels[name]
+		if !ok || slices.Contains(delivered, name) {
+			continue
+		}
+		if err := channel.Deliver(msg, prefs); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		done = append(done, name)
+	}
+
+	return done, errors.Join(errs...)
+}
+
+// LoadPreferences returns the notification preferences of a user, or the
+// defaults if they never changed them.
+func LoadPreferences(db *gorm.DB, userID string) (model.NotificationPreferences, error) {
+	prefs := DefaultPreferences(userID)
+	err := db.Where("user_id = ?", userID).First(&prefs).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.NotificationPreferences{}, err
+	}
+	return prefs, nil
+}
+
+func DefaultPreferences(userID string) model.NotificationPreferences {
+	return model.NotificationPreferences{UserID: userID, Channels: model.ChannelInApp}
+}
+
+func EnabledChannels(prefs model.NotificationPreferences) []string {
+	if prefs.Channels == "" {
+		return nil
+	}
+	return strings.Split(prefs.Channels, ",")
+}
+
+// QuietUntil reports whether t falls within the quiet hours of prefs in the
+// given location, and if so, when they end.
+func QuietUntil(prefs model.NotificationPreferences, t time.Time, location *time.Location) (time.Time, bool) {
+	if prefs.QuietHoursStart == nil || prefs.QuietHoursEnd == nil || *prefs.QuietHoursStart == *prefs.QuietHoursEnd {
+		return time.Time{}, false
+	}
+
+	start, end := *prefs.QuietHoursStart, *prefs.QuietHoursEnd
+	local := t.In(location)
+	hour := local.Hour()
+
+	var quiet bool
+	if start < end {
+		quiet = hour >= start && hour < end
+	} else {
+		quiet = hour >= start || hour < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end, 0, 0, 0, location)
+	if !until.After(t) {
+		until = time.Date(local.Year(), local.Month(), local.Day()+1, end, 0, 0, 0, location)
+	}
+	return until, true
+}
@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/goal"
+	"github.com/abyan-dev/productivity/pkg/model"
+)
+
+func TestQuietUntil(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hours := func(start, end int) model.NotificationPreferences {
+		return model.NotificationPreferences{QuietHoursStart: &start, QuietHoursEnd: &end}
+	}
+
+	tests := []struct {
+		name     string
+		prefs    model.NotificationPreferences
+		now      time.Time
+		quiet    bool
+		expected time.Time
+	}{
+		{"no quiet hours", model.NotificationPreferences{}, time.Date(2024, 7, 1, 23, 0, 0, 0, jakarta), false, time.Time{}},
+		{"equal bounds", hours(8, 8), time.Date(2024, 7, 1, 8, 30, 0, 0, jakarta), false, time.Time{}},
+		{"within same-day window", hours(12, 14), time.Date(2024, 7, 1, 13, 15, 0, 0, jakarta), true, time.Date(2024, 7, 1, 14, 0, 0, 0, jakarta)},
+		{"at end of same-day window", hours(12, 14), time.Date(2024, 7, 1, 14, 0, 0, 0, jakarta), false, time.Time{}},
+		{"before midnight in wrapping window", hours(22, 7), time.Date(2024, 7, 1, 23, 30, 0, 0, jakarta), true, time.Date(2024, 7, 2, 7, 0, 0, 0, jakarta)},
+		{"after midnight in wrapping window", hours(22, 7), time.Date(2024, 7, 2, 3, 0, 0, 0, jakarta), true, time.Date(2024, 7, 2, 7, 0, 0, 0, jakarta)},
+		{"outside wrapping window", hours(22, 7), time.Date(2024, 7, 2, 12, 0, 0, 0, jakarta), false, time.Time{}},
+		{"evaluated in local time", hours(22, 7), time.Date(2024, 7, 1, 16, 0, 0, 0, time.UTC), true, time.Date(2024, 7, 2, 7, 0, 0, 0, jakarta)},
+	}
+
+	for _, test := range tests {
+		until, quiet := QuietUntil(test.prefs, test.now, jakarta)
+		if quiet != test.quiet || !until.Equal(test.expected) {
+			t.Errorf("%s: QuietUntil() = (%v, %v), want (%v, %v)", test.name, until, quiet, test.expected, test.quiet)
+		}
+	}
+}
+
+type stubChannel struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (ch *stubChannel) Name() string {
+	return ch.name
+}
+
+func (ch *stubChannel) Deliver(msg Message, prefs model.NotificationPreferences) error {
+	ch.calls++
+	return ch.err
+}
+
+func TestDispatcherDeliverSkipsDeliveredChannels(t *testing.T) {
+	inApp := &stubChannel{name: "in_app"}
+	email := &stubChannel{name: "email", err: errors.New("smtp down")}
+	webhook := &stubChannel{name: "webhook"}
+	d := NewDispatcher(nil, inApp, email, webhook)
+	prefs := model.NotificationPreferences{Channels: "in_app,email,webhook"}
+
+	delivered, err := d.deliver(Message{}, prefs, nil)
+	if err == nil || !slices.Equal(delivered, []string{"in_app", "webhook"}) {
+		t.Fatalf("first deliver() = (%v, %v), want in_app and webhook delivered and an error", delivered, err)
+	}
+
+	email.err = nil
+	delivered, err = d.deliver(Message{}, prefs, delivered)
+	if err != nil || !slices.Equal(delivered, []string{"in_app", "webhook", "email"}) {
+		t.Fatalf("second deliver() = (%v, %v), want all channels delivered", delivered, err)
+	}
+	if inApp.calls != 1 || email.calls != 2 || webhook.calls != 1 {
+		t.Errorf("calls = (in_app %d, email %d, webhook %d), want (1, 2, 1)", inApp.calls, email.calls, webhook.calls)
+	}
+}
+
+func TestArmReminder(t *testing.T) {
+	now := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 7, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		due    time.Time
+		offset int
+		fireAt time.Time
+		status string
+	}{
+		{"one hour before", due, 60, time.Date(2024, 7, 2, 8, 0, 0, 0, time.UTC), model.ReminderPending},
+		{"one day before", due, 24 * 60, now, model.ReminderSkipped},
+		{"two days before", due, 2 * 24 * 60, time.Date(2024, 6, 30, 9, 0, 0, 0, time.UTC), model.ReminderSkipped},
+		{"no due date", time.Time{}, 60, time.Time{}.Add(-time.Hour), model.ReminderSkipped},
+	}
+
+	for _, test := range tests {
+		sentAt := now
+		r := model.Reminder{OffsetMinutes: test.offset, Status: model.ReminderFailed, Attempts: 3, LastError: "boom", DeliveredChannels: "in_app", SentAt: &sentAt}
+		ArmReminder(&r, test.due, now)
+		if !r.FireAt.Equal(test.fireAt) || r.Status != test.status {
+			t.Errorf("%s: ArmReminder() = (%v, %q), want (%v, %q)", test.name, r.FireAt, r.Status, test.fireAt, test.status)
+		}
+		if r.Attempts != 0 || r.LastError != "" || r.DeliveredChannels != "" || r.SentAt != nil {
+			t.Errorf("%s: ArmReminder() did not reset delivery state", test.name)
+		}
+	}
+}
+
+func TestReminderBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{7, time.Hour},
+		{20, time.Hour},
+	}
+
+	for _, test := range tests {
+		if result := ReminderBackoff(test.attempts); result != test.expected {
+			t.Errorf("ReminderBackoff(%d) = %v, want %v", test.attempts, result, test.expected)
+		}
+	}
+}
+
+func TestDescribeOffset(t *testing.T) {
+	tests := []struct {
+		minutes  int
+		expected string
+	}{
+		{1, "in 1 minute"},
+		{15, "in 15 minutes"},
+		{60, "in 1 hour"},
+		{90, "in 90 minutes"},
+		{180, "in 3 hours"},
+		{24 * 60, "in 1 day"},
+		{36 * 60, "in 36 hours"},
+		{7 * 24 * 60, "in 7 days"},
+	}
+
+	for _, test := range tests {
+		if result := DescribeOffset(test.minutes); result != test.expected {
+			t.Errorf("DescribeOffset(%d) = %q, want %q", test.minutes, result, test.expected)
+		}
+	}
+}
+
+func TestGoalMetMessage(t *testing.T) {
+	tests := []struct {
+		goal     model.Goal
+		streak   int
+		expected string
+	}{
+		{model.Goal{Kind: model.GoalFocusMinutes, Period: model.GoalDaily, Target: 90}, 1, "You reached today's goal of 90 focus minutes."},
+		{model.Goal{Kind: model.GoalTasksCompleted, Period: model.GoalWeekly, Target: 5}, 3, "You reached this week's goal of 5 completed tasks. That makes a streak of 3."},
+	}
+
+	for _, test := range tests {
+		msg := goalMetMessage(test.goal, goal.Progress{CurrentStreak: test.streak})
+		if msg.Kind != KindGoalMet || msg.Body != test.expected {
+			t.Errorf("goalMetMessage() = (%q, %q), want (%q, %q)", msg.Kind, msg.Body, KindGoalMet, test.expected)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/rollup"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	KindReminder = "reminder"
+
+	maxReminderAttempts = 5
+	maxReminderBackoff  = time.Hour
+	reminderClaimLease  = 5 * time.Minute
+)
+
+// ArmReminder computes when a reminder fires for a task due at due. A
+// reminder whose fire time has already passed is skipped rather than fired
+// late.
+func ArmReminder(r *model.Reminder, due time.Time, now time.Time) {
+	r.FireAt = due.Add(-time.Duration(r.OffsetMinutes) * time.Minute).UTC()
+	r.Attempts = 0
+	r.LastError = ""
+	r.DeliveredChannels = ""
+	r.SentAt = nil
+	if due.IsZero() || !r.FireAt.After(now) {
+		r.Status = model.ReminderSkipped
+	} else {
+		r.Status = model.ReminderPending
+	}
+}
+
+// SetReminders replaces the reminders of a task with one per offset.
+func SetReminders(db *gorm.DB, task model.Task, offsets []int, now time.Time) ([]model.Reminder, error) {
+	reminders := make([]model.Reminder, 0, len(offsets))
+	for _, offset := range offsets {
+		r := model.Reminder{TaskID: task.ID, OffsetMinutes: offset, UserID: task.UserID}
+		ArmReminder(&r, task.DueDate, now)
+		reminders = append(reminders, r)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", task.ID).Delete(&model.Reminder{}).Error; err != nil {
+			return err
+		}
+		if len(reminders) == 0 {
+			return nil
+		}
+		return tx.Create(&reminders).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+// RescheduleReminders re-arms the reminders of a task after its due date
+// changed, including ones that were already sent for the old due date.
+func RescheduleReminders(db *gorm.DB, task model.Task, now time.Time) error {
+	var reminders []model.Reminder
+	if err := db.Where("task_id = ?", task.ID).Find(&reminders).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range reminders {
+			ArmReminder(&reminders[i], task.DueDate, now)
+			if err := tx.Save(&reminders[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReminderBackoff returns how long to wait before retrying a reminder that
+// failed for the given number of times.
+func ReminderBackoff(attempts int) time.Duration {
+	backoff := time.Minute
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxReminderBackoff {
+			return maxReminderBackoff
+		}
+	}
+	return backoff
+}
+
+// DescribeOffset phrases a reminder offset for use in a message, such as
+// "in 1 day" or "in 90 minutes".
+func DescribeOffset(minutes int) string {
+	unit, count := "minute", minutes
+	switch {
+	case minutes >= 24*60 && minutes%(24*60) == 0:
+		unit, count = "day", minutes/(24*60)
+	case minutes >= 60 && minutes%60 == 0:
+		unit, count = "hour", minutes/60
+	}
+	if count != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("in %d %s", count, unit)
+}
+
+// ReminderScheduler fires due reminders. Reminders are stored in the
+// database, so none are lost across restarts, and each one is claimed under
+// a SKIP LOCKED row lock before it is sent, so several replicas can run a
+// scheduler without sending duplicates.
+type ReminderScheduler struct {
+	DB        *gorm.DB
+	Notifier  Notifier
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewReminderScheduler(db *gorm.DB, notifier Notifier) *ReminderScheduler {
+	return &ReminderScheduler{
+		DB:        db,
+		Notifier:  notifier,
+		Interval:  30 * time.Second,
+		BatchSize: 100,
+	}
+}
+
+func (s *ReminderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(time.Now()); err != nil {
+				slog.Error("Failed to fire reminders", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// RunOnce processes up to BatchSize reminders that are due at now.
+func (s *ReminderScheduler) RunOnce(now time.Time) error {
+	for i := 0; i < s.BatchSize; i++ {
+		processed, err := s.processNext(now)
+		if err != nil {
+			return err
+		}
+		if !processed {
+			return nil
+		}
+	}
+	return nil
+}
+
+// processNext claims the next due reminder and fires, defers or skips it.
+// The claim is committed before the reminder is sent, so that no row lock is
+// held during delivery, and lasts for reminderClaimLease, after which a
+// reminder whose scheduler died mid-send is picked up again.
+func (s *ReminderScheduler) processNext(now time.Time) (bool, error) {
+	processed := false
+	var (
+		r        model.Reminder
+		task     model.Task
+		location *time.Location
+		send     bool
+	)
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND fire_at <= ?", []string{model.ReminderPending, model.ReminderSending}, now.UTC()).
+			Order("fire_at").
+			First(&r).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		processed = true
+
+		err = tx.Where("user_id = ?", r.UserID).First(&task, r.TaskID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && task.IsComplete) {
+			r.Status = model.ReminderSkipped
+			return tx.Save(&r).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		prefs, err := LoadPreferences(tx, r.UserID)
+		if err != nil {
+			return err
+		}
+
+		location, err = rollup.UserLocation(tx, r.UserID)
+		if err != nil {
+			return err
+		}
+
+		if until, quiet := QuietUntil(prefs, now, location); quiet {
+			r.Status = model.ReminderPending
+			r.FireAt = until.UTC()
+			return tx.Save(&r).Error
+		}
+
+		send = true
+		r.Status = model.ReminderSending
+		r.FireAt = now.Add(reminderClaimLease).UTC()
+		return tx.Save(&r).Error
+	})
+	if err != nil || !send {
+		return processed, err
+	}
+
+	delivered, err := s.Notifier.NotifyExcept(reminderMessage(task, r, location), splitChannels(r.DeliveredChannels))
+	updates := map[string]any{"delivered_channels": strings.Join(delivered, ",")}
+	if err != nil {
+		r.Attempts++
+		updates["attempts"] = r.Attempts
+		updates["last_error"] = err.Error()
+		if r.Attempts >= maxReminderAttempts {
+			updates["status"] = model.ReminderFailed
+		} else {
+			updates["status"] = model.ReminderPending
+			updates["fire_at"] = now.Add(ReminderBackoff(r.Attempts)).UTC()
+		}
+		slog.Error("Failed to send reminder", slog.Uint64("reminder_id", uint64(r.ID)), slog.String("error", err.Error()))
+	} else {
+		updates["status"] = model.ReminderSent
+		updates["sent_at"] = now.UTC()
+		updates["last_error"] = ""
+	}
+
+	// The reminder may have been re-armed for a new due date while it was
+	// being sent, in which case the outcome no longer applies to it.
+	err = s.DB.Model(&model.Reminder{}).
+		Where("id = ? AND status = ?", r.ID, model.ReminderSending).
+		Updates(updates).Error
+
+	return processed, err
+}
+
+func splitChannels(channels string) []string {
+	if channels == "" {
+		return nil
+	}
+	return strings.Split(channels, ",")
+}
+
+func reminderMessage(task model.Task, r model.Reminder, location *time.Location) Message {
+	taskID := task.ID
+	return Message{
+		UserID: task.UserID,
+		Kind:   KindReminder,
+		Title:  fmt.Sprintf("Reminder: %s", task.Title),
+		Body: fmt.Sprintf("%q is due %s, at %s.", task.Title, DescribeOffset(r.OffsetMinutes),
+			task.DueDate.In(location).Format("Mon, 02 Jan 2006 15:04 MST")),
+		TaskID: &taskID,
+	}
+}
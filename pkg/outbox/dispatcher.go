@@ -0,0 +1,189 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"gorm.io/gorm"
+)
+
+const maxBackoff = time.Hour
+
+// MaxAttempts is how many times publishing an event is tried before it is
+// dead-lettered, which with the backoff capped at an hour is about nine
+// hours.
+const MaxAttempts = 20
+
+// Backoff returns how long to wait before retrying an event that failed to
+// publish for the given number of times.
+func Backoff(attempts int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
+
+// Dispatcher publishes outbox events to every sink. The events of a user are
+// published strictly in order: Record makes them visible in the order of
+// their IDs, a user is only processed by one replica at a time, holding an
+// advisory lock, and an event that fails to publish holds
+// back the user's later events until it succeeds or, after MaxAttempts, is
+// dead-lettered. A retry only goes to the sinks that have not accepted the
+// event yet, and since the sinks that did are recorded only after they
+// accepted it, delivery to each sink is at least once.
+type Dispatcher struct {
+	DB        *gorm.DB
+	Sinks     []Sink
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewDispatcher(db *gorm.DB, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{
+		DB:        db,
+		Sinks:     sinks,
+		Interval:  time.Second,
+		BatchSize: 100,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.RunOnce(ctx, time.Now()); err != nil {
+				slog.Error("Failed to dispatch outbox events", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// RunOnce publishes the pending events of every user that has any. A user
+// whose events cannot be published is logged and skipped, so that the
+// others are not held up.
+func (d *Dispatcher) RunOnce(ctx context.Context, now time.Time) error {
+	var userIDs []string
+	err := d.DB.Model(&model.OutboxEvent{}).
+		Where("published_at IS NULL AND dead_lettered_at IS NULL AND next_attempt_at <= ?", now.UTC()).
+		Distinct("user_id").
+		Pluck("user_id", &userIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err := d.dispatchUser(ctx, userID, now); err != nil {
+			slog.Error("Failed to dispatch outbox events of a user", slog.String("user_id", userID), slog.String("error", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) dispatchUser(ctx context.Context, userID string, now time.Time) error {
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(hashtext(?))", "outbox:"+userID).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var rows []model.OutboxEvent
+		err := tx.Where("user_id = ? AND published_at IS NULL AND dead_lettered_at IS NULL", userID).
+			Order("id").
+			Limit(d.BatchSize).
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range publishInOrder(rows, now, func(event Event, published []string) ([]string, error) {
+			return d.publish(ctx, event, published)
+		}) {
+			if err := tx.Save(&row).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// publish publishes the event to the sinks not among the published ones,
+// and returns the sinks that have accepted it by now.
+func (d *Dispatcher) publish(ctx context.Context, event Event, published []string) ([]string, error) {
+	accepted := append([]string{}, published...)
+	var errs []error
+	for _, sink := range d.Sinks {
+		if slices.Contains(published, sink.Name()) {
+			continue
+		}
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			continue
+		}
+		accepted = append(accepted, sink.Name())
+	}
+	return accepted, errors.Join(errs...)
+}
+
+// publishInOrder publishes rows, which must be ordered by ID, until one fails
+// or is not due yet, and returns the rows whose state changed. A row that
+// fails for the last time is dead-lettered, and the rows after it are
+// published anyway.
+func publishInOrder(rows []model.OutboxEvent, now time.Time, publish func(event Event, published []string) ([]string, error)) []model.OutboxEvent {
+	changed := []model.OutboxEvent{}
+
+	for _, row := range rows {
+		if row.NextAttemptAt.After(now) {
+			break
+		}
+
+		var published []string
+		if row.PublishedSinks != "" {
+			published = strings.Split(row.PublishedSinks, ",")
+		}
+
+		accepted, err := publish(eventFromRow(row), published)
+		row.PublishedSinks = strings.Join(accepted, ",")
+		if err != nil {
+			row.Attempts++
+			row.LastError = err.Error()
+			if row.Attempts >= MaxAttempts {
+				deadLetteredAt := now.UTC()
+				row.DeadLetteredAt = &deadLetteredAt
+				changed = append(changed, row)
+				slog.Error("Dead-lettered outbox event", slog.Uint64("event_id", uint64(row.ID)), slog.Int("attempts", row.Attempts), slog.String("error", err.Error()))
+				continue
+			}
+			row.NextAttemptAt = now.Add(Backoff(row.Attempts)).UTC()
+			changed = append(changed, row)
+			slog.Error("Failed to publish outbox event", slog.Uint64("event_id", uint64(row.ID)), slog.String("error", err.Error()))
+			break
+		}
+
+		publishedAt := now.UTC()
+		row.PublishedAt = &publishedAt
+		row.LastError = ""
+		changed = append(changed, row)
+	}
+
+	return changed
+}
@@ -0,0 +1,89 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Janitor deletes events that were published or dead-lettered longer than
+// Retention ago. The latest task event of every user is kept regardless, as
+// its ID is the user's current CalDAV sync token. The ID of the latest task
+// event deleted is kept with the user; sync tokens from before it are
+// refused, and the client syncs in full.
+type Janitor struct {
+	DB        *gorm.DB
+	Interval  time.Duration
+	Retention time.Duration
+}
+
+func NewJanitor(db *gorm.DB) *Janitor {
+	return &Janitor{
+		DB:        db,
+		Interval:  time.Hour,
+		Retention: 30 * 24 * time.Hour,
+	}
+}
+
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := j.RunOnce(time.Now()); err != nil {
+				slog.Error("Failed to delete old outbox events", slog.String("error", err.Error()))
+			} else if n > 0 {
+				slog.Info("Deleted old outbox events", slog.Int64("count", n))
+			}
+		}
+	}
+}
+
+// RunOnce deletes the events that are done with and older than the
+// retention at now, and returns how many it deleted.
+func (j *Janitor) RunOnce(now time.Time) (int64, error) {
+	latestTaskEvents := j.DB.Model(&model.OutboxEvent{}).
+		Select("MAX(id)").
+		Where("type LIKE ?", "task.%").
+		Group("user_id")
+
+	var deleted []model.OutboxEvent
+	err := j.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "type"}, {Name: "user_id"}}}).
+			Where("(published_at IS NOT NULL OR dead_lettered_at IS NOT NULL) AND created_at < ?", now.Add(-j.Retention).UTC()).
+			Where("id NOT IN (?)", latestTaskEvents).
+			Delete(&deleted).Error
+		if err != nil {
+			return err
+		}
+
+		pruned := map[string]uint{}
+		for _, event := range deleted {
+			if strings.HasPrefix(event.Type, "task.") && event.ID > pruned[event.UserID] {
+				pruned[event.UserID] = event.ID
+			}
+		}
+		for userID, id := range pruned {
+			err := tx.Model(&model.User{}).
+				Where("id = ? AND pruned_task_event_id < ?", userID, id).
+				UpdateColumn("pruned_task_event_id", id).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(deleted)), nil
+}
@@ -0,0 +1,81 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+)
+
+const (
+	EventTaskCreated         = "task.created"
+	EventTaskUpdated         = "task.updated"
+	EventTaskCompleted       = "task.completed"
+	EventTaskDeleted         = "task.deleted"
+	EventPomodoroStarted     = "pomodoro.started"
+	EventPomodoroStopped     = "pomodoro.stopped"
+	EventPomodoroInterrupted = "pomodoro.interrupted"
+	EventSessionCreated      = "session.created"
+	EventSessionUpdated      = "session.updated"
+	EventSessionDeleted      = "session.deleted"
+)
+
+// Event is a published domain event. Data holds the JSON encoding of the
+// affected row as of the change.
+type Event struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	UserID    string          `json:"user_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func eventFromRow(row model.OutboxEvent) Event {
+	return Event{
+		ID:        row.ID,
+		Type:      row.Type,
+		UserID:    row.UserID,
+		Data:      json.RawMessage(row.Payload),
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+// Sink receives published events. Publishing is at least once, so sinks
+// may see an event again after a failure and should be idempotent on the
+// event ID.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event Event) error
+}
+
+// Record writes an event to the outbox. It must be called with the
+// transaction that makes the change the event describes, so that the event
+// is stored if and only if the change is.
+//
+// Event IDs are taken when the event is written, not when the transaction
+// commits, so the transaction holds a lock on the user's events until it
+// ends. Another transaction writing events of the same user waits for it,
+// and the user's events thereby become visible in the order of their IDs.
+func Record(tx *gorm.DB, userID string, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "outbox-record:"+userID).Error; err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	row := model.OutboxEvent{
+		Type:          eventType,
+		Payload:       string(payload),
+		CreatedAt:     now,
+		NextAttemptAt: now,
+		UserID:        userID,
+	}
+
+	return tx.Create(&row).Error
+}
@@ -0,0 +1,198 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/goccy/go-json"
+)
+
+func TestPublishInOrder(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	rows := func() []model.OutboxEvent {
+		return []model.OutboxEvent{
+			{ID: 1, Type: EventTaskCreated, Payload: `{}`, NextAttemptAt: now},
+			{ID: 2, Type: EventTaskUpdated, Payload: `{}`, NextAttemptAt: now},
+			{ID: 3, Type: EventTaskDeleted, Payload: `{}`, NextAttemptAt: now},
+		}
+	}
+
+	t.Run("publishes all in order", func(t *testing.T) {
+		var published []uint
+		changed := publishInOrder(rows(), now, func(event Event, sinks []string) ([]string, error) {
+			published = append(published, event.ID)
+			return []string{"bus"}, nil
+		})
+		if len(published) != 3 || published[0] != 1 || published[1] != 2 || published[2] != 3 {
+			t.Fatalf("published %v, want [1 2 3]", published)
+		}
+		for _, row := range changed {
+			if row.PublishedAt == nil || !row.PublishedAt.Equal(now) {
+				t.Errorf("event %d PublishedAt = %v, want %v", row.ID, row.PublishedAt, now)
+			}
+		}
+	})
+
+	t.Run("stops at the first failure", func(t *testing.T) {
+		var published []uint
+		changed := publishInOrder(rows(), now, func(event Event, sinks []string) ([]string, error) {
+			if event.ID == 2 {
+				return []string{"bus"}, errors.New("sink unavailable")
+			}
+			published = append(published, event.ID)
+			return []string{"bus"}, nil
+		})
+		if len(published) != 1 || published[0] != 1 {
+			t.Fatalf("published %v, want [1]", published)
+		}
+		if len(changed) != 2 {
+			t.Fatalf("changed %d rows, want 2", len(changed))
+		}
+		failed := changed[1]
+		if failed.PublishedAt != nil || failed.Attempts != 1 || failed.LastError != "sink unavailable" || !failed.NextAttemptAt.Equal(now.Add(time.Second)) {
+			t.Errorf("failed row = %+v, want one attempt retried in a second", failed)
+		}
+		if failed.PublishedSinks != "bus" {
+			t.Errorf("failed row PublishedSinks = %q, want the sink that accepted it", failed.PublishedSinks)
+		}
+	})
+
+	t.Run("dead-letters after the last attempt", func(t *testing.T) {
+		pending := rows()
+		pending[0].Attempts = MaxAttempts - 1
+		var published []uint
+		changed := publishInOrder(pending, now, func(event Event, sinks []string) ([]string, error) {
+			if event.ID == 1 {
+				return nil, errors.New("sink unavailable")
+			}
+			published = append(published, event.ID)
+			return nil, nil
+		})
+		if len(published) != 2 || published[0] != 2 || published[1] != 3 {
+			t.Fatalf("published %v, want [2 3] after the first was dead-lettered", published)
+		}
+		if dead := changed[0]; dead.DeadLetteredAt == nil || dead.PublishedAt != nil || dead.Attempts != MaxAttempts {
+			t.Errorf("dead row = %+v, want it dead-lettered after %d attempts", dead, MaxAttempts)
+		}
+	})
+
+	t.Run("waits for a backed off event", func(t *testing.T) {
+		pending := rows()
+		pending[0].NextAttemptAt = now.Add(time.Minute)
+		changed := publishInOrder(pending, now, func(event Event, sinks []string) ([]string, error) {
+			t.Errorf("published event %d while the first one is backed off", event.ID)
+			return nil, nil
+		})
+		if len(changed) != 0 {
+			t.Errorf("changed %d rows, want 0", len(changed))
+		}
+	})
+}
+
+type countingSink struct {
+	name      string
+	err       error
+	published int
+}
+
+func (s *countingSink) Name() string {
+	return s.name
+}
+
+func (s *countingSink) Publish(ctx context.Context, event Event) error {
+	s.published++
+	return s.err
+}
+
+func TestDispatcherPublishRetriesFailedSinksOnly(t *testing.T) {
+	bus := &countingSink{name: "bus"}
+	webhooks := &countingSink{name: "webhooks", err: errors.New("database unavailable")}
+	d := NewDispatcher(nil, bus, webhooks)
+
+	accepted, err := d.publish(context.Background(), Event{ID: 1}, nil)
+	if err == nil || len(accepted) != 1 || accepted[0] != "bus" {
+		t.Fatalf("publish() = %v, %v, want only the bus to accept", accepted, err)
+	}
+
+	webhooks.err = nil
+	accepted, err = d.publish(context.Background(), Event{ID: 1}, accepted)
+	if err != nil || len(accepted) != 2 {
+		t.Fatalf("publish() = %v, %v, want both sinks to have accepted", accepted, err)
+	}
+	if bus.published != 1 || webhooks.published != 2 {
+		t.Errorf("bus published %d times and webhooks %d, want 1 and 2", bus.published, webhooks.published)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{6, 32 * time.Second},
+		{20, time.Hour},
+	}
+
+	for _, test := range tests {
+		if result := Backoff(test.attempts); result != test.expected {
+			t.Errorf("Backoff(%d) = %v, want %v", test.attempts, result, test.expected)
+		}
+	}
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	var received []string
+	bus.Subscribe(func(ctx context.Context, event Event) error {
+		received = append(received, "first:"+event.Type)
+		return errors.New("first failed")
+	}, EventTaskCreated, EventTaskDeleted)
+	bus.Subscribe(func(ctx context.Context, event Event) error {
+		received = append(received, "second:"+event.Type)
+		return nil
+	}, EventTaskCreated)
+
+	if err := bus.Publish(context.Background(), Event{Type: EventTaskCreated}); err == nil {
+		t.Error("Publish() did not return the failing handler's error")
+	}
+	if err := bus.Publish(context.Background(), Event{Type: EventPomodoroStarted}); err != nil {
+		t.Errorf("Publish() without subscribers = %v, want nil", err)
+	}
+
+	if len(received) != 2 || received[0] != "first:task.created" || received[1] != "second:task.created" {
+		t.Errorf("handlers received %v, want both handlers to see task.created", received)
+	}
+}
+
+func TestBrokerSink(t *testing.T) {
+	broker := &MemoryBroker{}
+	sink := &BrokerSink{Publisher: broker, Prefix: "productivity."}
+	event := Event{ID: 7, Type: EventSessionCreated, UserID: "someone@example.com", Data: json.RawMessage(`{"id":3}`)}
+
+	if err := sink.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	messages := broker.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("broker has %d messages, want 1", len(messages))
+	}
+	if messages[0].Subject != "productivity.session.created" || messages[0].Key != "someone@example.com" {
+		t.Errorf("message = (%q, %q), want (%q, %q)", messages[0].Subject, messages[0].Key, "productivity.session.created", "someone@example.com")
+	}
+
+	var decoded Event
+	if err := json.Unmarshal(messages[0].Data, &decoded); err != nil || decoded.ID != 7 || string(decoded.Data) != `{"id":3}` {
+		t.Errorf("message data = %s, want the encoded event", messages[0].Data)
+	}
+
+	broker.Err = errors.New("connection lost")
+	if err := sink.Publish(context.Background(), event); err == nil {
+		t.Error("Publish() to a failing broker returned nil")
+	}
+}
@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/goccy/go-json"
+)
+
+// Handler processes an event within the service.
+type Handler func(ctx context.Context, event Event) error
+
+// Bus is a sink that hands events to in-process subscribers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[string][]Handler{}}
+}
+
+// Subscribe registers a handler for events of the given types.
+func (b *Bus) Subscribe(handler Handler, eventTypes ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, eventType := range eventTypes {
+		b.handlers[eventType] = append(b.handlers[eventType], handler)
+	}
+}
+
+func (b *Bus) Name() string {
+	return "bus"
+}
+
+// Publish runs every handler subscribed to the event's type, even if some
+// fail, and joins their errors.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := b.handlers[event.Type]
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Publisher is the interface of a message broker such as NATS or Kafka. The
+// subject is the NATS subject or Kafka topic, and the key is used by brokers
+// that partition messages, so that messages sharing a key stay in order.
+type Publisher interface {
+	Publish(ctx context.Context, subject string, key string, data []byte) error
+}
+
+// BrokerSink publishes events to a message broker, under the subject
+// Prefix followed by the event type and keyed by user.
+type BrokerSink struct {
+	Publisher Publisher
+	Prefix    string
+}
+
+func (s *BrokerSink) Name() string {
+	return "broker"
+}
+
+func (s *BrokerSink) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.Publisher.Publish(ctx, s.Prefix+event.Type, event.UserID, data)
+}
+
+// BrokerMessage is a message published to a MemoryBroker.
+type BrokerMessage struct {
+	Subject string
+	Key     string
+	Data    []byte
+}
+
+// MemoryBroker is an in-memory Publisher for tests and local development.
+// Setting Err makes every publish fail with it.
+type MemoryBroker struct {
+	mu       sync.Mutex
+	messages []BrokerMessage
+	Err      error
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, subject string, key string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Err != nil {
+		return fmt.Errorf("publish to %s: %w", subject, b.Err)
+	}
+	b.messages = append(b.messages, BrokerMessage{Subject: subject, Key: key, Data: data})
+	return nil
+}
+
+// Messages returns the messages published so far, oldest first.
+func (b *MemoryBroker) Messages() []BrokerMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]BrokerMessage(nil), b.messages...)
+}
@@ -0,0 +1,69 @@
+package rbac
+
+// Permission is an action on a kind of resource.
+type Permission string
+
+const (
+	ReadTasks     Permission = "tasks:read"
+	WriteTasks    Permission = "tasks:write"
+	ReadSessions  Permission = "sessions:read"
+	WriteSessions Permission = "sessions:write"
+	ReadMetrics   Permission = "metrics:read"
+	ReadAccount   Permission = "account:read"
+	WriteAccount  Permission = "account:write"
+	ReadUsers     Permission = "users:read"
+	ManageUsers   Permission = "users:manage"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleUser   = "user"
+	RoleViewer = "viewer"
+)
+
+// Tasks cover reminders, imports and calendar exports; sessions cover
+// subjects and goals; the account covers settings, notifications,
+// integrations and the account's data as a whole. Permissions on users
+// are for the admin API, which acts on other users' data.
+var permissions = map[string][]Permission{
+	RoleAdmin: {
+		ReadTasks, WriteTasks, ReadSessions, WriteSessions, ReadMetrics,
+		ReadAccount, WriteAccount, ReadUsers, ManageUsers,
+	},
+	RoleUser: {
+		ReadTasks, WriteTasks, ReadSessions, WriteSessions, ReadMetrics,
+		ReadAccount, WriteAccount,
+	},
+	RoleViewer: {
+		ReadTasks, ReadSessions, ReadMetrics, ReadAccount,
+	},
+}
+
+// aliases name permissions after the resources users know them by, so
+// that personal access tokens can be requested with, say, pomodoro:write.
+var aliases = map[string]Permission{
+	"pomodoro:read":  ReadSessions,
+	"pomodoro:write": WriteSessions,
+	"goals:read":     ReadSessions,
+	"goals:write":    WriteSessions,
+}
+
+// ParsePermission returns the permission with the given name or alias.
+// Unknown names are returned as they are, and no role has them.
+func ParsePermission(name string) Permission {
+	if permission, ok := aliases[name]; ok {
+		return permission
+	}
+	return Permission(name)
+}
+
+// Can reports whether the role has the permission. Unknown roles have no
+// permissions.
+func Can(role string, permission Permission) bool {
+	for _, granted := range permissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
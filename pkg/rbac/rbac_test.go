@@ -0,0 +1,50 @@
+package rbac
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		expected   bool
+	}{
+		{RoleAdmin, ManageUsers, true},
+		{RoleAdmin, WriteTasks, true},
+		{RoleUser, WriteTasks, true},
+		{RoleUser, ReadMetrics, true},
+		{RoleUser, ReadUsers, false},
+		{RoleUser, ManageUsers, false},
+		{RoleViewer, ReadTasks, true},
+		{RoleViewer, ReadAccount, true},
+		{RoleViewer, WriteTasks, false},
+		{RoleViewer, WriteSessions, false},
+		{RoleViewer, WriteAccount, false},
+		{"", ReadTasks, false},
+		{"superuser", ReadTasks, false},
+	}
+
+	for _, test := range tests {
+		if result := Can(test.role, test.permission); result != test.expected {
+			t.Errorf("Can(%q, %q) = %v, want %v", test.role, test.permission, result, test.expected)
+		}
+	}
+}
+
+func TestParsePermission(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Permission
+	}{
+		{"tasks:write", WriteTasks},
+		{"pomodoro:write", WriteSessions},
+		{"pomodoro:read", ReadSessions},
+		{"goals:write", WriteSessions},
+		{"unknown:scope", Permission("unknown:scope")},
+	}
+
+	for _, test := range tests {
+		if result := ParsePermission(test.name); result != test.expected {
+			t.Errorf("ParsePermission(%q) = %q, want %q", test.name, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/goal"
+	"github.com/abyan-dev/productivity/pkg/mail"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/rollup"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"gorm.io/gorm"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var funcs = map[string]any{
+	"minutes": FormatMinutes,
+	"date": func(t time.Time) string {
+		return t.Format("Mon, Jan 2")
+	},
+	"percent": func(ratio float64) string {
+		return fmt.Sprintf("%.0f%%", ratio*100)
+	},
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("weekly.html").Funcs(funcs).ParseFS(templates, "templates/weekly.html"))
+	textTemplate = texttemplate.Must(texttemplate.New("weekly.txt").Funcs(funcs).ParseFS(templates, "templates/weekly.txt"))
+)
+
+type TaskSummary struct {
+	ID      uint      `json:"id"`
+	Title   string    `json:"title"`
+	DueDate time.Time `json:"due_date"`
+}
+
+type DayFocus struct {
+	Date         time.Time `json:"date"`
+	FocusMinutes float64   `json:"focus_minutes"`
+}
+
+type GoalStatus struct {
+	Kind          string  `json:"kind"`
+	Period        string  `json:"period"`
+	Target        float64 `json:"target"`
+	Value         float64 `json:"value"`
+	Progress      float64 `json:"progress"`
+	Met           bool    `json:"met"`
+	CurrentStreak int     `json:"current_streak"`
+}
+
+// Weekly is a review of a user's last seven days, including today.
+type Weekly struct {
+	UserID            string        `json:"user_id"`
+	Email             string        `json:"email"`
+	TimeZone          string        `json:"time_zone"`
+	From              time.Time     `json:"from"`
+	To                time.Time     `json:"to"`
+	TotalFocusMinutes float64       `json:"total_focus_minutes"`
+	FocusByDay        []DayFocus    `json:"focus_by_day"`
+	TasksCompleted    []TaskSummary `json:"tasks_completed"`
+	OverdueTasks      []TaskSummary `json:"overdue_tasks"`
+	DueNextWeek       []TaskSummary `json:"due_next_week"`
+	Goals             []GoalStatus  `json:"goals"`
+}
+
+// Build gathers the weekly review of a user as of now.
+func Build(db *gorm.DB, userID string, now time.Time, location *time.Location) (Weekly, error) {
+	to := utils.StartOfDay(now, location).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -7)
+
+	var user model.User
+	if err := db.Select("email").Where("id = ?", userID).First(&user).Error; err != nil {
+		return Weekly{}, err
+	}
+
+	weekly := Weekly{
+		UserID:         userID,
+		Email:          user.Email,
+		TimeZone:       location.String(),
+		From:           from,
+		To:             to,
+		FocusByDay:     []DayFocus{},
+		TasksCompleted: []TaskSummary{},
+		OverdueTasks:   []TaskSummary{},
+		DueNextWeek:    []TaskSummary{},
+		Goals:          []GoalStatus{},
+	}
+
+	days, err := rollup.Days(db, userID, from, to, location)
+	if err != nil {
+		return Weekly{}, err
+	}
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		minutes := days[day.Format(rollup.DateLayout)].FocusMinutes
+		weekly.FocusByDay = append(weekly.FocusByDay, DayFocus{Date: day, FocusMinutes: minutes})
+		weekly.TotalFocusMinutes += minutes
+	}
+
+	var completed []model.Task
+	err = db.Where("user_id = ? AND is_complete = ? AND completed_at >= ? AND completed_at < ?", userID, true, from.UTC(), to.UTC()).
+		Order("completed_at").Find(&completed).Error
+	if err != nil {
+		return Weekly{}, err
+	}
+	weekly.TasksCompleted = summarizeTasks(completed, location)
+
+	var overdue []model.Task
+	err = overdueTasks(db, userID, now).Find(&overdue).Error
+	if err != nil {
+		return Weekly{}, err
+	}
+	weekly.OverdueTasks = summarizeTasks(overdue, location)
+
+	var upcoming []model.Task
+	err = db.Where("user_id = ? AND is_complete = ? AND due_date >= ? AND due_date < ?", userID, false, now.UTC(), to.AddDate(0, 0, 7).UTC()).
+		Order("due_date").Find(&upcoming).Error
+	if err != nil {
+		return Weekly{}, err
+	}
+	weekly.DueNextWeek = summarizeTasks(upcoming, location)
+
+	var goals []model.Goal
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&goals).Error; err != nil {
+		return Weekly{}, err
+	}
+	for _, g := range goals {
+		progress, err := goal.Track(db, g, now, location)
+		if err != nil {
+			return Weekly{}, err
+		}
+		status := GoalStatus{
+			Kind:          g.Kind,
+			Period:        g.Period,
+			Target:        g.Target,
+			Value:         progress.Current.Value,
+			Progress:      progress.Current.Value / g.Target,
+			Met:           progress.Current.Met,
+			CurrentStreak: progress.CurrentStreak,
+		}
+		weekly.Goals = append(weekly.Goals, status)
+	}
+
+	return weekly, nil
+}
+
+// Render renders the weekly review as an email with a plain text and an HTML
+// body.
+func Render(weekly Weekly) (mail.Message, error) {
+	var html, text bytes.Buffer
+
+	if err := htmlTemplate.Execute(&html, weekly); err != nil {
+		return mail.Message{}, err
+	}
+	if err := textTemplate.Execute(&text, weekly); err != nil {
+		return mail.Message{}, err
+	}
+
+	return mail.Message{
+		To:      weekly.Email,
+		Subject: fmt.Sprintf("Your week in review: %s", FormatMinutes(weekly.TotalFocusMinutes)),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// FormatMinutes formats a number of minutes as hours and minutes, such as
+// "1h 25m".
+func FormatMinutes(minutes float64) string {
+	total := int(minutes + 0.5)
+	if total < 60 {
+		return fmt.Sprintf("%dm", total)
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%dh %dm", total/60, total%60), " 0m")
+}
+
+// overdueTasks selects the open tasks of a user that are past their due
+// date. Tasks without a due date, such as to-dos from calendar clients that
+// set no DUE, are stored with the zero time and never count as overdue.
+func overdueTasks(db *gorm.DB, userID string, now time.Time) *gorm.DB {
+	return db.Model(&model.Task{}).
+		Where("user_id = ? AND is_complete = ? AND due_date > ? AND due_date < ?", userID, false, time.Time{}, now.UTC()).
+		Order("due_date")
+}
+
+func summarizeTasks(tasks []model.Task, location *time.Location) []TaskSummary {
+	summaries := make([]TaskSummary, 0, len(tasks))
+	for _, task := range tasks {
+		summaries = append(summaries, TaskSummary{ID: task.ID, Title: task.Title, DueDate: task.DueDate.In(location)})
+	}
+	return summaries
+}
@@ -0,0 +1,125 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/ical"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestFormatMinutes(t *testing.T) {
+	tests := []struct {
+		minutes  float64
+		expected string
+	}{
+		{0, "0m"},
+		{25, "25m"},
+		{59.6, "1h"},
+		{60, "1h"},
+		{85, "1h 25m"},
+		{605, "10h 5m"},
+	}
+
+	for _, test := range tests {
+		if result := FormatMinutes(test.minutes); result != test.expected {
+			t.Errorf("FormatMinutes(%v) = %q, want %q", test.minutes, result, test.expected)
+		}
+	}
+}
+
+func TestLastSlot(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	tests := []struct {
+		day      time.Weekday
+		hour     int
+		now      time.Time
+		location *time.Location
+		expected time.Time
+	}{
+		// Saturday afternoon, before the Sunday slot: last week's Sunday.
+		{time.Sunday, 18, time.Date(2024, 7, 27, 15, 0, 0, 0, time.UTC), time.UTC, time.Date(2024, 7, 21, 18, 0, 0, 0, time.UTC)},
+		// Sunday evening, right after the slot.
+		{time.Sunday, 18, time.Date(2024, 7, 28, 18, 5, 0, 0, time.UTC), time.UTC, time.Date(2024, 7, 28, 18, 0, 0, 0, time.UTC)},
+		// Monday morning slot, asked on Wednesday.
+		{time.Monday, 8, time.Date(2024, 7, 24, 12, 0, 0, 0, time.UTC), time.UTC, time.Date(2024, 7, 22, 8, 0, 0, 0, time.UTC)},
+		// 11:30 UTC on Sunday is already past 18:00 in Jakarta.
+		{time.Sunday, 18, time.Date(2024, 7, 28, 11, 30, 0, 0, time.UTC), jakarta, time.Date(2024, 7, 28, 18, 0, 0, 0, jakarta)},
+	}
+
+	for _, test := range tests {
+		result := LastSlot(test.day, test.hour, test.now, test.location)
+		if !result.Equal(test.expected) {
+			t.Errorf("LastSlot(%v, %d, %v) = %v, want %v", test.day, test.hour, test.now, result, test.expected)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	from := time.Date(2024, 7, 22, 0, 0, 0, 0, time.UTC)
+	weekly := Weekly{
+		UserID:            "user-1",
+		Email:             "test@example.com",
+		TimeZone:          "UTC",
+		From:              from,
+		To:                from.AddDate(0, 0, 7),
+		TotalFocusMinutes: 125,
+		FocusByDay:        []DayFocus{{Date: from, FocusMinutes: 125}},
+		TasksCompleted:    []TaskSummary{{ID: 1, Title: "Read <chapter> 4"}},
+		OverdueTasks:      []TaskSummary{},
+		DueNextWeek:       []TaskSummary{{ID: 2, Title: "Essay draft", DueDate: from.AddDate(0, 0, 9)}},
+		Goals:             []GoalStatus{{Kind: "focus_minutes", Period: "daily", Target: 100, Value: 50, Progress: 0.5}},
+	}
+
+	msg, err := Render(weekly)
+	if err != nil {
+		t.Fatalf("Failed to render report: %v", err)
+	}
+
+	if msg.To != "test@example.com" {
+		t.Errorf("To = %q, want test@example.com", msg.To)
+	}
+	if msg.Subject != "Your week in review: 2h 5m" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+
+	for _, want := range []string{"FOCUS TIME: 2h 5m", "Read <chapter> 4", "Nothing overdue", "Essay draft (due Wed, Jul 31)", "50%"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("Text body does not contain %q:\n%s", want, msg.Text)
+		}
+	}
+	for _, want := range []string{"Read &lt;chapter&gt; 4", "Mon, Jul 22 &ndash; Sun, Jul 28"} {
+		if !strings.Contains(msg.HTML, want) {
+			t.Errorf("HTML body does not contain %q:\n%s", want, msg.HTML)
+		}
+	}
+}
+
+func TestOverdueTasksSkipsTasksWithoutDueDate(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A VTODO without DUE is stored with the zero time as its due date.
+	todo, err := ical.ParseTodo("BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:no-due\r\nSUMMARY:Someday\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", time.UTC)
+	if err != nil || todo.Due != nil {
+		t.Fatalf("ParseTodo() = (%v, %v), want no due date", todo.Due, err)
+	}
+
+	now := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var tasks []model.Task
+		return overdueTasks(tx, "user@example.com", now).Find(&tasks)
+	})
+	if !strings.Contains(sql, "due_date > '0000-00-00 00:00:00'") {
+		t.Errorf("overdueTasks() = %q, want tasks with the zero due date excluded", sql)
+	}
+}
@@ -0,0 +1,146 @@
+package report
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/mail"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// maxDelay is how late a weekly report may still be sent, for instance after
+// the service was down at the scheduled time.
+const maxDelay = 24 * time.Hour
+
+// Scheduler sends the weekly review to every user who opted in, at their
+// preferred day and hour in their time zone. Each report is claimed by
+// atomically advancing the user's last sent time before sending, so running
+// a scheduler on several replicas does not send duplicates.
+type Scheduler struct {
+	DB       *gorm.DB
+	Sender   mail.Sender
+	Interval time.Duration
+}
+
+func NewScheduler(db *gorm.DB, sender mail.Sender) *Scheduler {
+	return &Scheduler{
+		DB:       db,
+		Sender:   sender,
+		Interval: 5 * time.Minute,
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(time.Now()); err != nil {
+				slog.Error("Failed to send weekly reports", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// RunOnce sends all weekly reports that are due at now.
+func (s *Scheduler) RunOnce(now time.Time) error {
+	var subscribers []model.UserSettings
+	if err := s.DB.Where("weekly_report_enabled = ?", true).Find(&subscribers).Error; err != nil {
+		return err
+	}
+
+	for _, settings := range subscribers {
+		day, ok := model.ParseWeekday(settings.WeeklyReportDay)
+		if !ok {
+			continue
+		}
+
+		location := settings.Location()
+		slot := LastSlot(day, settings.WeeklyReportHour, now, location)
+		if settings.LastWeeklyReportAt != nil && !settings.LastWeeklyReportAt.Before(slot) {
+			continue
+		}
+		if now.Sub(slot) > maxDelay {
+			continue
+		}
+
+		claimed, err := s.claim(settings, now)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.send(settings.UserID, now, location); err != nil {
+			slog.Error("Failed to send weekly report", slog.String("user_id", settings.UserID), slog.String("error", err.Error()))
+			s.release(settings, now)
+		}
+	}
+
+	return nil
+}
+
+func (s *Scheduler) send(userID string, now time.Time, location *time.Location) error {
+	weekly, err := Build(s.DB, userID, now, location)
+	if err != nil {
+		return err
+	}
+
+	msg, err := Render(weekly)
+	if err != nil {
+		return err
+	}
+
+	return s.Sender.Send(msg)
+}
+
+// claim marks the report as sent, unless another replica got there first.
+func (s *Scheduler) claim(settings model.UserSettings, now time.Time) (bool, error) {
+	query := s.DB.Model(&model.UserSettings{}).Where("user_id = ?", settings.UserID)
+	if settings.LastWeeklyReportAt == nil {
+		query = query.Where("last_weekly_report_at IS NULL")
+	} else {
+		query = query.Where("last_weekly_report_at = ?", *settings.LastWeeklyReportAt)
+	}
+
+	result := query.Update("last_weekly_report_at", claimStamp(now))
+	return result.RowsAffected == 1, result.Error
+}
+
+// release undoes a claim so that the report is retried on the next run.
+func (s *Scheduler) release(settings model.UserSettings, now time.Time) {
+	err := s.DB.Model(&model.UserSettings{}).
+		Where("user_id = ? AND last_weekly_report_at = ?", settings.UserID, claimStamp(now)).
+		Update("last_weekly_report_at", settings.LastWeeklyReportAt).Error
+	if err != nil {
+		slog.Error("Failed to release weekly report claim", slog.String("user_id", settings.UserID), slog.String("error", err.Error()))
+	}
+}
+
+// claimStamp truncates now to the precision Postgres stores timestamps with,
+// so that a claim can be matched again when releasing it.
+func claimStamp(now time.Time) time.Time {
+	return now.UTC().Truncate(time.Microsecond)
+}
+
+// LastSlot returns the most recent occurrence, at or before now, of the given
+// weekday and hour in location.
+func LastSlot(day time.Weekday, hour int, now time.Time, location *time.Location) time.Time {
+	offset := (int(day) + 6) % 7
+	weekStart := utils.StartOfWeek(now, location)
+	slotDay := weekStart.AddDate(0, 0, offset)
+	slot := time.Date(slotDay.Year(), slotDay.Month(), slotDay.Day(), hour, 0, 0, 0, location)
+	if slot.After(now) {
+		slotDay = slotDay.AddDate(0, 0, -7)
+		slot = time.Date(slotDay.Year(), slotDay.Month(), slotDay.Day(), hour, 0, 0, 0, location)
+	}
+	return slot
+}
@@ -0,0 +1,87 @@
+package revocation
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// cache remembers whether tokens are revoked, evicting the least recently
+// used entries beyond its size.
+type cache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	id      string
+	revoked bool
+	until   time.Time
+}
+
+func newCache(size int) *cache {
+	return &cache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *cache) get(id string, now time.Time) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[id]
+	if !ok {
+		return false, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !now.Before(entry.until) {
+		c.order.Remove(element)
+		delete(c.entries, id)
+		return false, false
+	}
+	c.order.MoveToFront(element)
+	return entry.revoked, true
+}
+
+func (c *cache) set(id string, revoked bool, until time.Time) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[id]; ok {
+		element.Value = &cacheEntry{id: id, revoked: revoked, until: until}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[id] = c.order.PushFront(&cacheEntry{id: id, revoked: revoked, until: until})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).id)
+	}
+}
+
+// forgetAccepted drops every token remembered as not revoked, for when
+// tokens have been revoked without knowing which.
+func (c *cache) forgetAccepted() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if entry := element.Value.(*cacheEntry); !entry.revoked {
+			c.order.Remove(element)
+			delete(c.entries, entry.id)
+		}
+		element = next
+	}
+}
@@ -0,0 +1,57 @@
+package revocation
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"gorm.io/gorm"
+)
+
+// Janitor deletes revoked tokens, refresh token families and revocations of
+// all of a user's tokens that have expired, as their tokens would be refused
+// anyway.
+type Janitor struct {
+	DB       *gorm.DB
+	Interval time.Duration
+}
+
+func NewJanitor(db *gorm.DB) *Janitor {
+	return &Janitor{
+		DB:       db,
+		Interval: time.Hour,
+	}
+}
+
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := j.RunOnce(time.Now()); err != nil {
+				slog.Error("Failed to delete expired revocations", slog.String("error", err.Error()))
+			} else if n > 0 {
+				slog.Info("Deleted expired revocations", slog.Int64("count", n))
+			}
+		}
+	}
+}
+
+// RunOnce deletes the revoked tokens, refresh token families and
+// revocations that expired before now and returns how many rows it deleted.
+func (j *Janitor) RunOnce(now time.Time) (int64, error) {
+	deleted := int64(0)
+	for _, expired := range []interface{}{&model.RevokedToken{}, &model.RefreshFamily{}, &model.TokenRevocation{}} {
+		result := j.DB.Where("expires_at < ?", now.UTC()).Delete(expired)
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+	return deleted, nil
+}
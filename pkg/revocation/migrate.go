@@ -0,0 +1,90 @@
+package revocation
+
+import (
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// MigrateLegacy converts the revoked_tokens table from when it stored the
+// tokens themselves, keeping the tokens that have not expired yet, and
+// returns how many it kept. It does nothing if the table has already been
+// converted, and must run before the table is auto-migrated.
+func MigrateLegacy(db *gorm.DB, now time.Time) (int, error) {
+	migrator := db.Migrator()
+	if !migrator.HasTable("revoked_tokens") || !migrator.HasColumn("revoked_tokens", "token") {
+		return 0, nil
+	}
+
+	kept := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var tokens []string
+		if err := tx.Table("revoked_tokens").Pluck("token", &tokens).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Migrator().DropTable("revoked_tokens"); err != nil {
+			return err
+		}
+		if err := tx.AutoMigrate(&model.RevokedToken{}); err != nil {
+			return err
+		}
+
+		parser := jwt.NewParser()
+		for _, token := range tokens {
+			claims := jwt.MapClaims{}
+			if _, _, err := parser.ParseUnverified(token, claims); err != nil {
+				continue
+			}
+
+			expires := expiresAt(claims, now)
+			if expires.Before(now) {
+				continue
+			}
+			userID, err := account.TokenUserID(tx, claims)
+			if err != nil {
+				return err
+			}
+
+			revokedToken := model.RevokedToken{
+				TokenID:   TokenID(token, claims),
+				RevokedAt: now.UTC(),
+				ExpiresAt: expires.UTC(),
+				UserID:    userID,
+			}
+			if err := tx.Where("token_id = ?", revokedToken.TokenID).FirstOrCreate(&revokedToken).Error; err != nil {
+				return err
+			}
+			kept++
+		}
+		return nil
+	})
+
+	return kept, err
+}
+
+// MigrateRevocationExpiry gives the revocations of all of a user's tokens
+// made before they had an expiry one, DefaultLifetime after the second they
+// revoke tokens before. It does nothing if they already have one, and must
+// run before the table is auto-migrated.
+func MigrateRevocationExpiry(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable("token_revocations") || migrator.HasColumn("token_revocations", "expires_at") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE token_revocations ADD COLUMN expires_at timestamp").Error; err != nil {
+			return err
+		}
+		err := tx.Exec("UPDATE token_revocations SET revoked_before = date_trunc('second', revoked_before), expires_at = date_trunc('second', revoked_before) + make_interval(secs => ?)",
+			DefaultLifetime.Seconds()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec("ALTER TABLE token_revocations ALTER COLUMN expires_at SET NOT NULL").Error
+	})
+}
@@ -0,0 +1,156 @@
+package revocation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RotationGrace is how long after a refresh token was exchanged it is still
+// accepted, from concurrent requests that were sent with it, without being
+// taken for reuse.
+const RotationGrace = 30 * time.Second
+
+var (
+	ErrRefreshReused = errors.New("refresh token was already used")
+	ErrFamilyRevoked = errors.New("refresh token family is revoked")
+)
+
+// Rotation is the outcome of exchanging a refresh token. NextTokenID is the
+// jti of the refresh token to issue in its place, or empty if the token was
+// exchanged moments ago by a concurrent request, in which case only a new
+// access token should be issued.
+type Rotation struct {
+	FamilyID    string
+	NextTokenID string
+}
+
+// Rotate exchanges a verified refresh token of the user for the next one in
+// its family. Refresh tokens issued without a family start one. Presenting
+// a token that was already exchanged, outside the grace period, revokes the
+// family and returns ErrRefreshReused.
+func (s *Store) Rotate(token string, claims jwt.MapClaims, userID string, now time.Time) (Rotation, error) {
+	presented := TokenID(token, claims)
+	familyID, _ := claims["fid"].(string)
+	legacy := familyID == ""
+
+	nextID, err := newID()
+	if err != nil {
+		return Rotation{}, err
+	}
+	if legacy {
+		if familyID, err = newID(); err != nil {
+			return Rotation{}, err
+		}
+	}
+
+	rotation := Rotation{}
+	reused := false
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var family model.RefreshFamily
+		locked := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+
+		var err error
+		if legacy {
+			err = locked.Where("previous_token_id = ?", presented).First(&family).Error
+		} else {
+			err = locked.Where("id = ?", familyID).First(&family).Error
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			family = model.RefreshFamily{
+				ID:             familyID,
+				CurrentTokenID: presented,
+				CreatedAt:      now.UTC(),
+				ExpiresAt:      expiresAt(claims, now).UTC(),
+				UserID:         userID,
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&family).Error; err != nil {
+				return err
+			}
+			err = locked.Where("id = ?", familyID).First(&family).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if family.RevokedAt != nil {
+			return ErrFamilyRevoked
+		}
+
+		if family.CurrentTokenID != presented {
+			if family.PreviousTokenID == presented && family.RotatedAt != nil && now.Sub(*family.RotatedAt) < RotationGrace {
+				rotation = Rotation{FamilyID: family.ID}
+				return nil
+			}
+			reused = true
+			return tx.Model(&family).UpdateColumn("revoked_at", now.UTC()).Error
+		}
+
+		err = tx.Model(&family).UpdateColumns(map[string]interface{}{
+			"current_token_id":  nextID,
+			"previous_token_id": presented,
+			"rotated_at":        now.UTC(),
+			"expires_at":        now.Add(DefaultLifetime).UTC(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		// A token from before families existed cannot be recognized by its
+		// family, so it is revoked by itself.
+		if legacy {
+			revokedToken := model.RevokedToken{
+				TokenID:   presented,
+				RevokedAt: now.UTC(),
+				ExpiresAt: expiresAt(claims, now).UTC(),
+				UserID:    userID,
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revokedToken).Error; err != nil {
+				return err
+			}
+		}
+
+		rotation = Rotation{FamilyID: family.ID, NextTokenID: nextID}
+		return nil
+	})
+	if err != nil {
+		return Rotation{}, err
+	}
+	if reused {
+		s.cache.forgetAccepted()
+		return Rotation{}, ErrRefreshReused
+	}
+	if legacy && rotation.NextTokenID != "" {
+		s.cache.set(presented, true, expiresAt(claims, now))
+	}
+
+	return rotation, nil
+}
+
+// RevokeFamily revokes a refresh token family, and with it every refresh
+// and access token issued to it.
+func (s *Store) RevokeFamily(familyID string, now time.Time) error {
+	err := s.DB.Model(&model.RefreshFamily{}).
+		Where("id = ? AND revoked_at IS NULL", familyID).
+		UpdateColumn("revoked_at", now.UTC()).Error
+	if err != nil {
+		return err
+	}
+	s.cache.forgetAccepted()
+	return nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
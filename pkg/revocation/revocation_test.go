@@ -0,0 +1,107 @@
+package revocation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTokenID(t *testing.T) {
+	tests := []struct {
+		token    string
+		claims   jwt.MapClaims
+		expected string
+	}{
+		{"a.b.c", jwt.MapClaims{"jti": "abc123"}, "abc123"},
+		{"a.b.c", jwt.MapClaims{}, utils.HashToken("a.b.c")},
+		{"a.b.c", jwt.MapClaims{"jti": ""}, utils.HashToken("a.b.c")},
+		{"a.b.c", jwt.MapClaims{"jti": 42}, utils.HashToken("a.b.c")},
+	}
+
+	for _, test := range tests {
+		if result := TokenID(test.token, test.claims); result != test.expected {
+			t.Errorf("TokenID(%q, %v) = %q, want %q", test.token, test.claims, result, test.expected)
+		}
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	now := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		expected time.Time
+	}{
+		{"exp", jwt.MapClaims{"exp": float64(now.Add(time.Hour).Unix())}, now.Add(time.Hour)},
+		{"no exp", jwt.MapClaims{}, now.Add(DefaultLifetime)},
+		{"far exp", jwt.MapClaims{"exp": float64(now.AddDate(100, 0, 0).Unix())}, now.Add(DefaultLifetime)},
+	}
+
+	for _, test := range tests {
+		if result := expiresAt(test.claims, now); !result.Equal(test.expected) {
+			t.Errorf("%s: expiresAt() = %v, want %v", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestLifetime(t *testing.T) {
+	s := NewStore(nil, &Config{AccessLifetime: 15 * time.Minute})
+
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		expected time.Duration
+	}{
+		{"access", jwt.MapClaims{"typ": utils.TokenTypeAccess}, 15 * time.Minute},
+		{"refresh", jwt.MapClaims{"typ": utils.TokenTypeRefresh}, DefaultLifetime},
+		{"no typ", jwt.MapClaims{}, 15 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if result := s.lifetime(test.claims); result != test.expected {
+			t.Errorf("%s: lifetime() = %v, want %v", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := newCache(2)
+
+	c.set("a", false, now.Add(time.Minute))
+	c.set("b", true, now.Add(time.Hour))
+
+	if revoked, ok := c.get("a", now); !ok || revoked {
+		t.Errorf("Expected a to be cached as accepted, got %v, %v", revoked, ok)
+	}
+	if _, ok := c.get("a", now.Add(time.Minute)); ok {
+		t.Error("Expected a to expire after its TTL")
+	}
+
+	c.set("a", false, now.Add(time.Minute))
+	c.get("b", now)
+	c.set("c", false, now.Add(time.Minute))
+	if _, ok := c.get("a", now); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := c.get("b", now); !ok {
+		t.Error("Expected b to still be cached")
+	}
+
+	c.forgetAccepted()
+	if _, ok := c.get("c", now); ok {
+		t.Error("Expected accepted tokens to be forgotten")
+	}
+	if revoked, ok := c.get("b", now); !ok || !revoked {
+		t.Error("Expected revoked tokens to be kept")
+	}
+
+	disabled := newCache(0)
+	disabled.set("a", true, now.Add(time.Hour))
+	if _, ok := disabled.get("a", now); ok {
+		t.Error("Expected a cache of size zero to remember nothing")
+	}
+}
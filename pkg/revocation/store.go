@@ -0,0 +1,178 @@
+package revocation
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultLifetime is how long a revoked token is kept revoked at most, and
+// how long one without an exp claim is: the lifetime of refresh tokens.
+const DefaultLifetime = 7 * 24 * time.Hour
+
+type Config struct {
+	// CacheTTL is how long a token found not to be revoked is trusted
+	// without asking the database again, so also how long a token revoked
+	// on another replica may still be accepted. Zero disables the cache.
+	CacheTTL time.Duration
+	// CacheSize is how many tokens are remembered at most.
+	CacheSize int
+	// AccessLifetime is how long access tokens live at most. Tokens without
+	// an iat claim are taken to have been issued that long before they
+	// expire, or DefaultLifetime for refresh tokens, to tell whether they
+	// were issued before their user's tokens were revoked.
+	AccessLifetime time.Duration
+}
+
+func LoadConfig() (*Config, error) {
+	config := &Config{
+		CacheTTL:       30 * time.Second,
+		CacheSize:      10000,
+		AccessLifetime: 15 * time.Minute,
+	}
+
+	if ttlStr := os.Getenv("REVOCATION_CACHE_TTL"); ttlStr != "" {
+		ttl, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REVOCATION_CACHE_TTL: %w", err)
+		}
+		config.CacheTTL = ttl
+	}
+	if sizeStr := os.Getenv("REVOCATION_CACHE_SIZE"); sizeStr != "" {
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REVOCATION_CACHE_SIZE: %w", err)
+		}
+		config.CacheSize = size
+	}
+	if lifetimeStr := os.Getenv("REVOCATION_ACCESS_LIFETIME"); lifetimeStr != "" {
+		lifetime, err := time.ParseDuration(lifetimeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REVOCATION_ACCESS_LIFETIME: %w", err)
+		}
+		config.AccessLifetime = lifetime
+	}
+
+	return config, nil
+}
+
+// Store revokes tokens, one at a time or all of a user's at once, and
+// answers whether a token is revoked from an in-process cache in front of
+// the database.
+type Store struct {
+	DB             *gorm.DB
+	TTL            time.Duration
+	AccessLifetime time.Duration
+	cache          *cache
+}
+
+func NewStore(db *gorm.DB, config *Config) *Store {
+	size := config.CacheSize
+	if config.CacheTTL <= 0 {
+		size = 0
+	}
+	return &Store{DB: db, TTL: config.CacheTTL, AccessLifetime: config.AccessLifetime, cache: newCache(size)}
+}
+
+// TokenID returns what a token is revoked by: its jti claim, or the hash of
+// the token if it has none.
+func TokenID(token string, claims jwt.MapClaims) string {
+	if jti, _ := claims["jti"].(string); jti != "" && len(jti) <= 64 {
+		return jti
+	}
+	return utils.HashToken(token)
+}
+
+// IsRevoked reports whether the token has been revoked, by itself, along
+// with the rest of its refresh token family, or along with all of its
+// user's tokens.
+func (s *Store) IsRevoked(token string, claims jwt.MapClaims, now time.Time) (bool, error) {
+	id := TokenID(token, claims)
+	if revoked, ok := s.cache.get(id, now); ok {
+		return revoked, nil
+	}
+
+	var count int64
+	if err := s.DB.Model(&model.RevokedToken{}).Where("token_id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	revoked := count > 0
+	if familyID, _ := claims["fid"].(string); !revoked && familyID != "" {
+		err := s.DB.Model(&model.RefreshFamily{}).Where("id = ? AND revoked_at IS NOT NULL", familyID).Count(&count).Error
+		if err != nil {
+			return false, err
+		}
+		revoked = count > 0
+	}
+	if !revoked {
+		var err error
+		if revoked, err = account.TokenRevoked(s.DB, claims, s.lifetime(claims)); err != nil {
+			return false, err
+		}
+	}
+
+	// A revoked token stays revoked, so it can be remembered until it
+	// expires.
+	until := now.Add(s.TTL)
+	if revoked {
+		until = expiresAt(claims, now)
+	}
+	s.cache.set(id, revoked, until)
+
+	return revoked, nil
+}
+
+// Revoke revokes a single token of the user with the given ID until it
+// expires.
+func (s *Store) Revoke(token string, claims jwt.MapClaims, userID string, now time.Time) error {
+	revokedToken := model.RevokedToken{
+		TokenID:   TokenID(token, claims),
+		RevokedAt: now.UTC(),
+		ExpiresAt: expiresAt(claims, now).UTC(),
+		UserID:    userID,
+	}
+	err := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&revokedToken).Error
+	if err != nil {
+		return err
+	}
+
+	s.cache.set(revokedToken.TokenID, true, revokedToken.ExpiresAt)
+	return nil
+}
+
+// RevokeAll revokes every token issued to the user so far.
+func (s *Store) RevokeAll(userID string, now time.Time) error {
+	if err := account.RevokeTokens(s.DB, userID, now, DefaultLifetime); err != nil {
+		return err
+	}
+	s.cache.forgetAccepted()
+	return nil
+}
+
+// lifetime returns how long tokens of the type of the one with the given
+// claims live at most.
+func (s *Store) lifetime(claims jwt.MapClaims) time.Duration {
+	if claims["typ"] == utils.TokenTypeRefresh {
+		return DefaultLifetime
+	}
+	return s.AccessLifetime
+}
+
+// expiresAt returns when a revoked token expires, which is never later than
+// DefaultLifetime from now, so that no token is kept revoked for longer than
+// any token the service accepts lives.
+func expiresAt(claims jwt.MapClaims, now time.Time) time.Time {
+	limit := now.Add(DefaultLifetime)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil && exp.Time.Before(limit) {
+		return exp.Time
+	}
+	return limit
+}
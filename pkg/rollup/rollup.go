@@ -0,0 +1,447 @@
+package rollup
+
+import (
+	"errors"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const DateLayout = "2006-01-02"
+
+// UserLocation returns the time zone a user's rollups are computed in.
+func UserLocation(db *gorm.DB, userID string) (*time.Location, error) {
+	var settings model.UserSettings
+	err := db.Where("user_id = ?", userID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return settings.Location(), nil
+}
+
+// Aggregate computes per-day counters, keyed by local date, from raw rows.
+// Sessions are attributed to the day they started on, provided that falls
+// within [from, to), and interruptions to the day of their session. Tasks
+// count as created or completed on the day of the respective timestamp.
+func Aggregate(sessions []model.PomodoroSession, interruptions []model.Interruption, tasks []model.Task, from time.Time, to time.Time, location *time.Location) map[string]model.DailyRollup {
+	days := map[string]model.DailyRollup{}
+	inRange := func(t time.Time) bool {
+		return !t.Before(from) && t.Before(to)
+	}
+	update := func(t time.Time, fn func(r *model.DailyRollup)) {
+		key := t.In(location).Format(DateLayout)
+		r := days[key]
+		fn(&r)
+		days[key] = r
+	}
+
+	sessionDays := map[uint]time.Time{}
+	for _, session := range sessions {
+		if session.IsRunning() || !inRange(session.StartTime) {
+			continue
+		}
+		sessionDays[session.ID] = session.StartTime
+		update(session.StartTime, func(r *model.DailyRollup) {
+			minutes := session.Duration().Minutes()
+			r.FocusMinutes += minutes
+			r.SessionCount++
+			if session.IsManual {
+				r.ManualFocusMinutes += minutes
+				r.ManualSessionCount++
+			}
+		})
+	}
+
+	for _, interruption := range interruptions {
+		start, ok := sessionDays[interruption.SessionID]
+		if !ok {
+			continue
+		}
+		update(start, func(r *model.DailyRollup) {
+			if interruption.Kind == model.InterruptionInternal {
+				r.InternalInterruptions++
+			} else {
+				r.ExternalInterruptions++
+			}
+		})
+	}
+
+	for _, task := range tasks {
+		if inRange(task.CreatedAt) {
+			update(task.CreatedAt, func(r *model.DailyRollup) { r.TasksCreated++ })
+		}
+		if task.IsComplete && task.CompletedAt != nil && inRange(*task.CompletedAt) {
+			update(*task.CompletedAt, func(r *model.DailyRollup) { r.TasksCompleted++ })
+		}
+	}
+
+	return days
+}
+
+// aggregateRaw loads a user's raw rows relevant to [from, to) and aggregates
+// them per local day.
+func aggregateRaw(db *gorm.DB, userID string, from time.Time, to time.Time, location *time.Location) (map[string]model.DailyRollup, error) {
+	from, to = from.UTC(), to.UTC()
+
+	var sessions []model.PomodoroSession
+	err := db.Where("user_id = ? AND end_time IS NOT NULL AND start_time >= ? AND start_time < ?", userID, from, to).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var interruptions []model.Interruption
+	err = db.Model(&model.Interruption{}).
+		Joins("JOIN pomodoro_sessions ON pomodoro_sessions.id = interruptions.session_id").
+		Where("pomodoro_sessions.user_id = ? AND pomodoro_sessions.end_time IS NOT NULL", userID).
+		Where("pomodoro_sessions.start_time >= ? AND pomodoro_sessions.start_time < ?", from, to).
+		Select("interruptions.*").
+		Find(&interruptions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []model.Task
+	err = db.Where("user_id = ?", userID).
+		Where("((created_at >= ? AND created_at < ?) OR (completed_at >= ? AND completed_at < ?))", from, to, from, to).
+		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return Aggregate(sessions, interruptions, tasks, from, to, location), nil
+}
+
+// store replaces the rollups of a user for the local days in [from, to) with
+// the given ones.
+func store(tx *gorm.DB, userID string, from time.Time, to time.Time, location *time.Location, days map[string]model.DailyRollup) error {
+	err := tx.Where("user_id = ? AND day >= ? AND day < ?", userID, from.In(location).Format(DateLayout), to.In(location).Format(DateLayout)).
+		Delete(&model.DailyRollup{}).Error
+	if err != nil {
+		return err
+	}
+
+	rollups := make([]model.DailyRollup, 0, len(days))
+	for key, r := range days {
+		if r.IsEmpty() {
+			continue
+		}
+		day, err := time.Parse(DateLayout, key)
+		if err != nil {
+			return err
+		}
+		r.UserID = userID
+		r.Day = day
+		rollups = append(rollups, r)
+	}
+
+	if len(rollups) == 0 {
+		return nil
+	}
+
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(rollups, 500).Error
+}
+
+// Refresh recomputes the rollup of a single local day, given as a date in
+// DateLayout, from the raw rows.
+func Refresh(db *gorm.DB, userID string, date string) error {
+	location, err := UserLocation(db, userID)
+	if err != nil {
+		return err
+	}
+
+	day, err := time.ParseInLocation(DateLayout, date, location)
+	if err != nil {
+		return err
+	}
+	next := day.AddDate(0, 0, 1)
+
+	days, err := aggregateRaw(db, userID, day, next, location)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		return store(tx, userID, day, next, location, days)
+	})
+}
+
+// Touch records that a user's activity at the given times has changed. The
+// affected days are marked as pending and refreshed right away; should the
+// refresh fail, the catch-up worker picks the marks up later.
+func Touch(db *gorm.DB, userID string, times ...time.Time) error {
+	location, err := UserLocation(db, userID)
+	if err != nil {
+		return err
+	}
+
+	days := localDays(location, times)
+	if err := markPending(db, userID, days); err != nil {
+		return err
+	}
+
+	for date := range days {
+		if err := Refresh(db, userID, date); err != nil {
+			return err
+		}
+		err := db.Where("user_id = ? AND day = ?", userID, date).Delete(&model.PendingRollup{}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Rebuild recomputes all rollups of a user for the days in [from, to). Only
+// the calendar dates of from and to are used, interpreted in the user's time
+// zone, and a zero from or to leaves that end of the range open. An empty
+// userID rebuilds every user with any recorded activity.
+func Rebuild(db *gorm.DB, userID string, from time.Time, to time.Time) error {
+	if userID == "" {
+		userIDs, err := activeUsers(db)
+		if err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			if err := Rebuild(db, userID, from, to); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	location, err := UserLocation(db, userID)
+	if err != nil {
+		return err
+	}
+	from, to = localRange(from, to, location)
+
+	days, err := aggregateRaw(db, userID, from, to, location)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := store(tx, userID, from, to, location, days); err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND day >= ? AND day < ?", userID, from.Format(DateLayout), to.Format(DateLayout)).
+			Delete(&model.PendingRollup{}).Error
+	})
+}
+
+// Invalidate marks every day of a user in [from, to) that has activity or a
+// stored rollup as pending, for the worker to recompute. The range is
+// interpreted as by Rebuild, and an empty userID likewise marks every user.
+// Unlike Rebuild it is cheap enough to run in the transaction of the change
+// that calls for it, such as a new time zone, and the worker retries the
+// recomputation until it succeeds.
+func Invalidate(db *gorm.DB, userID string, from time.Time, to time.Time) error {
+	if userID == "" {
+		userIDs, err := activeUsers(db)
+		if err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			if err := Invalidate(db, userID, from, to); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	location, err := UserLocation(db, userID)
+	if err != nil {
+		return err
+	}
+	from, to = localRange(from, to, location)
+
+	var times, completed []time.Time
+	err = db.Model(&model.PomodoroSession{}).
+		Where("user_id = ? AND end_time IS NOT NULL AND start_time >= ? AND start_time < ?", userID, from.UTC(), to.UTC()).
+		Pluck("start_time", &times).Error
+	if err != nil {
+		return err
+	}
+	var created []time.Time
+	err = db.Model(&model.Task{}).
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, from.UTC(), to.UTC()).
+		Pluck("created_at", &created).Error
+	if err != nil {
+		return err
+	}
+	err = db.Model(&model.Task{}).
+		Where("user_id = ? AND completed_at >= ? AND completed_at < ?", userID, from.UTC(), to.UTC()).
+		Pluck("completed_at", &completed).Error
+	if err != nil {
+		return err
+	}
+	times = append(append(times, created...), completed...)
+	days := localDays(location, times)
+
+	// Stored rollups may be keyed by the days of a previous time zone, so
+	// they are recomputed too, which drops the ones left without activity.
+	var stored []time.Time
+	err = db.Model(&model.DailyRollup{}).
+		Where("user_id = ? AND day >= ? AND day < ?", userID, from.Format(DateLayout), to.Format(DateLayout)).
+		Pluck("day", &stored).Error
+	if err != nil {
+		return err
+	}
+	for _, day := range stored {
+		day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		days[day.Format(DateLayout)] = day
+	}
+
+	return markPending(db, userID, days)
+}
+
+// localRange turns the dates of from and to into the start of those days in
+// location. A zero from or to leaves that end of the range open, up to the
+// end of the current day.
+func localRange(from time.Time, to time.Time, location *time.Location) (time.Time, time.Time) {
+	if from.IsZero() {
+		from = time.Unix(0, 0).UTC()
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, location)
+
+	if to.IsZero() {
+		to = utils.StartOfDay(time.Now(), location).AddDate(0, 0, 1)
+	}
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, location)
+
+	return from, to
+}
+
+// localDays returns the local days of the given times, keyed by date.
+func localDays(location *time.Location, times []time.Time) map[string]time.Time {
+	days := map[string]time.Time{}
+	for _, t := range times {
+		if t.IsZero() {
+			continue
+		}
+		local := t.In(location)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+		days[day.Format(DateLayout)] = day
+	}
+	return days
+}
+
+func markPending(db *gorm.DB, userID string, days map[string]time.Time) error {
+	if len(days) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	pending := make([]model.PendingRollup, 0, len(days))
+	for _, day := range days {
+		pending = append(pending, model.PendingRollup{UserID: userID, Day: day, CreatedAt: now})
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(pending, 500).Error
+}
+
+// Days returns a user's per-day counters, keyed by local date, for activity
+// in [from, to). Complete past days are read from the rollup table, while the
+// current day, partially covered days at either end and days with pending
+// refreshes are aggregated from the raw rows. If location differs from the
+// one the rollups were computed in, everything is aggregated from raw rows.
+func Days(db *gorm.DB, userID string, from time.Time, to time.Time, location *time.Location) (map[string]model.DailyRollup, error) {
+	stored, err := UserLocation(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rolledFrom := utils.StartOfDay(from, location)
+	if rolledFrom.Before(from) {
+		rolledFrom = rolledFrom.AddDate(0, 0, 1)
+	}
+	rolledTo := utils.StartOfDay(to, location)
+	if today := utils.StartOfDay(time.Now(), location); today.Before(rolledTo) {
+		rolledTo = today
+	}
+
+	if stored.String() != location.String() || !rolledFrom.Before(rolledTo) {
+		return aggregateRaw(db, userID, from, to, location)
+	}
+
+	days := map[string]model.DailyRollup{}
+	merge := func(other map[string]model.DailyRollup) {
+		for key, r := range other {
+			existing := days[key]
+			existing.Add(r)
+			days[key] = existing
+		}
+	}
+
+	if from.Before(rolledFrom) {
+		head, err := aggregateRaw(db, userID, from, rolledFrom, location)
+		if err != nil {
+			return nil, err
+		}
+		merge(head)
+	}
+
+	var pending []model.PendingRollup
+	err = db.Where("user_id = ? AND day >= ? AND day < ?", userID, rolledFrom.Format(DateLayout), rolledTo.Format(DateLayout)).
+		Find(&pending).Error
+	if err != nil {
+		return nil, err
+	}
+	pendingDates := map[string]bool{}
+	for _, p := range pending {
+		date := p.Day.Format(DateLayout)
+		pendingDates[date] = true
+		day, err := time.ParseInLocation(DateLayout, date, location)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := aggregateRaw(db, userID, day, day.AddDate(0, 0, 1), location)
+		if err != nil {
+			return nil, err
+		}
+		merge(raw)
+	}
+
+	var rollups []model.DailyRollup
+	err = db.Where("user_id = ? AND day >= ? AND day < ?", userID, rolledFrom.Format(DateLayout), rolledTo.Format(DateLayout)).
+		Find(&rollups).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rollups {
+		date := r.Day.Format(DateLayout)
+		if !pendingDates[date] {
+			merge(map[string]model.DailyRollup{date: r})
+		}
+	}
+
+	if rolledTo.Before(to) {
+		tail, err := aggregateRaw(db, userID, rolledTo, to, location)
+		if err != nil {
+			return nil, err
+		}
+		merge(tail)
+	}
+
+	return days, nil
+}
+
+// Sum adds up the counters of all days.
+func Sum(days map[string]model.DailyRollup) model.DailyRollup {
+	total := model.DailyRollup{}
+	for _, r := range days {
+		total.Add(r)
+	}
+	return total
+}
+
+func activeUsers(db *gorm.DB) ([]string, error) {
+	var userIDs []string
+	err := db.Raw("SELECT user_id FROM pomodoro_sessions UNION SELECT user_id FROM tasks").Scan(&userIDs).Error
+	return userIDs, err
+}
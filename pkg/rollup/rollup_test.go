@@ -0,0 +1,83 @@
+package rollup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+)
+
+func at(day int, hour int, minute int) time.Time {
+	return time.Date(2024, 7, day, hour, minute, 0, 0, time.UTC)
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestAggregate(t *testing.T) {
+	sessions := []model.PomodoroSession{
+		{ID: 1, StartTime: at(22, 9, 0), EndTime: ptr(at(22, 9, 25))},
+		{ID: 2, StartTime: at(22, 23, 50), EndTime: ptr(at(23, 0, 15))}, // counts towards the 22nd
+		{ID: 3, StartTime: at(23, 14, 0), EndTime: ptr(at(23, 15, 0)), IsManual: true},
+		{ID: 4, StartTime: at(23, 16, 0)},                             // still running
+		{ID: 5, StartTime: at(25, 9, 0), EndTime: ptr(at(25, 9, 25))}, // out of range
+	}
+	interruptions := []model.Interruption{
+		{SessionID: 2, Kind: model.InterruptionInternal, OccurredAt: at(23, 0, 5)},
+		{SessionID: 2, Kind: model.InterruptionExternal, OccurredAt: at(23, 0, 10)},
+		{SessionID: 4, Kind: model.InterruptionExternal, OccurredAt: at(23, 16, 5)},
+	}
+	tasks := []model.Task{
+		{CreatedAt: at(22, 8, 0), IsComplete: true, CompletedAt: ptr(at(23, 10, 0))},
+		{CreatedAt: at(23, 8, 0)},
+		{CreatedAt: at(20, 8, 0), IsComplete: false, CompletedAt: nil},
+	}
+
+	days := Aggregate(sessions, interruptions, tasks, at(22, 0, 0), at(24, 0, 0), time.UTC)
+
+	expected := map[string]model.DailyRollup{
+		"2024-07-22": {FocusMinutes: 50, SessionCount: 2, InternalInterruptions: 1, ExternalInterruptions: 1, TasksCreated: 1},
+		"2024-07-23": {FocusMinutes: 60, ManualFocusMinutes: 60, SessionCount: 1, ManualSessionCount: 1, TasksCreated: 1, TasksCompleted: 1},
+	}
+
+	if len(days) != len(expected) {
+		t.Fatalf("Aggregate returned %d days, want %d: %+v", len(days), len(expected), days)
+	}
+	for date, want := range expected {
+		if got := days[date]; got != want {
+			t.Errorf("Day %s = %+v, want %+v", date, got, want)
+		}
+	}
+}
+
+func TestAggregateInTimeZone(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	// 20:00 UTC on the 22nd is already the 23rd in Jakarta.
+	sessions := []model.PomodoroSession{
+		{ID: 1, StartTime: at(22, 20, 0), EndTime: ptr(at(22, 20, 25))},
+	}
+
+	days := Aggregate(sessions, nil, nil, at(22, 0, 0), at(24, 0, 0), jakarta)
+
+	if days["2024-07-23"].SessionCount != 1 {
+		t.Errorf("Aggregate = %+v, want the session on 2024-07-23", days)
+	}
+}
+
+func TestSum(t *testing.T) {
+	days := map[string]model.DailyRollup{
+		"2024-07-22": {FocusMinutes: 50, SessionCount: 2, TasksCreated: 1},
+		"2024-07-23": {FocusMinutes: 25, SessionCount: 1, ExternalInterruptions: 3},
+	}
+
+	total := Sum(days)
+	want := model.DailyRollup{FocusMinutes: 75, SessionCount: 3, TasksCreated: 1, ExternalInterruptions: 3}
+	if total != want {
+		t.Errorf("Sum = %+v, want %+v", total, want)
+	}
+}
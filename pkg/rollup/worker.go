@@ -0,0 +1,90 @@
+package rollup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Worker periodically refreshes the days marked as pending whose refresh
+// did not complete when the underlying rows were written. Pending rows are
+// claimed with SKIP LOCKED, so several replicas can run a worker at once.
+type Worker struct {
+	DB        *gorm.DB
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewWorker(db *gorm.DB) *Worker {
+	return &Worker{
+		DB:        db,
+		Interval:  time.Minute,
+		BatchSize: 100,
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Time zone changes and rebuilds mark many days at once, so
+			// batches are run back to back until the backlog is cleared.
+			total := 0
+			for ctx.Err() == nil {
+				n, err := w.RunOnce()
+				if err != nil {
+					slog.Error("Failed to refresh pending rollups", slog.String("error", err.Error()))
+					break
+				}
+				total += n
+				if n < w.BatchSize {
+					break
+				}
+			}
+			if total > 0 {
+				slog.Info("Refreshed pending rollups", slog.Int("count", total))
+			}
+		}
+	}
+}
+
+// RunOnce refreshes one batch of pending days and returns how many were
+// processed.
+func (w *Worker) RunOnce() (int, error) {
+	processed := 0
+
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		var pending []model.PendingRollup
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Order("created_at").
+			Limit(w.BatchSize).
+			Find(&pending).Error
+		if err != nil {
+			return err
+		}
+
+		for _, p := range pending {
+			date := p.Day.Format(DateLayout)
+			if err := Refresh(tx, p.UserID, date); err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ? AND day = ?", p.UserID, date).Delete(&model.PendingRollup{}).Error; err != nil {
+				return err
+			}
+			processed++
+		}
+
+		return nil
+	})
+
+	return processed, err
+}
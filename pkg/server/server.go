@@ -1,14 +1,30 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/abyan-dev/productivity/pkg/account"
+	"github.com/abyan-dev/productivity/pkg/archive"
+	"github.com/abyan-dev/productivity/pkg/auth"
+	"github.com/abyan-dev/productivity/pkg/erasure"
 	"github.com/abyan-dev/productivity/pkg/handler"
+	"github.com/abyan-dev/productivity/pkg/importer"
+	"github.com/abyan-dev/productivity/pkg/mail"
 	"github.com/abyan-dev/productivity/pkg/middleware"
 	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/notify"
+	"github.com/abyan-dev/productivity/pkg/outbox"
+	"github.com/abyan-dev/productivity/pkg/rbac"
+	"github.com/abyan-dev/productivity/pkg/report"
+	"github.com/abyan-dev/productivity/pkg/revocation"
+	"github.com/abyan-dev/productivity/pkg/rollup"
 	"github.com/abyan-dev/productivity/pkg/utils"
+	"github.com/abyan-dev/productivity/pkg/webhook"
 	"github.com/goccy/go-json"
 	"gorm.io/gorm"
 
@@ -17,7 +33,13 @@ import (
 )
 
 type Server struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Verifier *auth.Verifier
+	// TokenPrecedence says whether the Authorization header or the cookie
+	// is used when a request has both.
+	TokenPrecedence string
+	// AllowedOrigins may make credentialed requests from browsers.
+	AllowedOrigins []string
 }
 
 func (s *Server) New() *fiber.App {
@@ -36,20 +58,90 @@ func (s *Server) New() *fiber.App {
 
 	s.DB = db
 
+	authConfig, err := auth.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading auth configuration: %v", err)
+	}
+
+	s.TokenPrecedence = authConfig.TokenPrecedence
+	s.AllowedOrigins = authConfig.AllowedOrigins
+	s.Verifier, err = auth.NewVerifier(authConfig)
+	if err != nil {
+		log.Fatalf("Error setting up token verification: %v", err)
+	}
+
+	if archive.SigningSecret() == "" {
+		log.Fatalf("Error loading export configuration: %v", archive.ErrNoSigningSecret)
+	}
+
 	slog.Info("Applying database migrations...")
-	if err := db.AutoMigrate(&model.Task{}, &model.PomodoroSession{}); err != nil {
+	if converted, err := account.MigrateUserIDs(db); err != nil {
+		log.Fatalf("Error keying tables by user ID: %v", err)
+	} else if converted > 0 {
+		slog.Info("Keyed tables by user ID", slog.Int("count", converted))
+	}
+	if kept, err := revocation.MigrateLegacy(db, time.Now()); err != nil {
+		log.Fatalf("Error converting revoked tokens: %v", err)
+	} else if kept > 0 {
+		slog.Info("Converted revoked tokens", slog.Int("count", kept))
+	}
+	if err := revocation.MigrateRevocationExpiry(db); err != nil {
+		log.Fatalf("Error adding expiry to revocations: %v", err)
+	}
+	if err := db.AutoMigrate(&model.Task{}, &model.PomodoroSession{}, &model.Interruption{}, &model.Subject{}, &model.Goal{}, &model.UserSettings{}, &model.DailyRollup{}, &model.PendingRollup{}, &model.Reminder{}, &model.NotificationPreferences{}, &model.Notification{}, &model.WebhookEndpoint{}, &model.WebhookDelivery{}, &model.OutboxEvent{}, &model.CalendarFeed{}, &model.AppPassword{}, &model.ImportJob{}, &model.ExportJob{}, &model.ErasureRequest{}, &model.ErasureAudit{}, &model.User{}, &model.TokenRevocation{}, &model.AdminAuditEntry{}, &model.PersonalAccessToken{}, &model.RevokedToken{}, &model.RefreshFamily{}); err != nil {
 		log.Fatalf("Error auto-migrating database: %v", err)
 	}
 
+	revocationConfig, err := revocation.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading revocation configuration: %v", err)
+	}
+	revocations := revocation.NewStore(db, revocationConfig)
+
+	mailConfig, err := mail.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading mail configuration: %v", err)
+	}
+
+	mailSender := mail.NewSMTPSender(mailConfig)
+	notifier := notify.NewDispatcher(db,
+		&notify.InAppChannel{DB: db},
+		&notify.EmailChannel{DB: db, Sender: mailSender},
+		notify.NewWebhookChannel(),
+	)
+
+	// Goals can only be met by finishing focus time or completing tasks.
+	bus := outbox.NewBus()
+	bus.Subscribe(func(ctx context.Context, event outbox.Event) error {
+		return notify.CheckGoals(db, notifier, event.UserID, time.Now())
+	}, outbox.EventPomodoroStopped, outbox.EventSessionCreated, outbox.EventSessionUpdated, outbox.EventTaskCompleted)
+
+	slog.Info("Starting background workers...")
+	go rollup.NewWorker(db).Run(context.Background())
+	go report.NewScheduler(db, mailSender).Run(context.Background())
+	go notify.NewReminderScheduler(db, notifier).Run(context.Background())
+	go notify.NewSweeper(db, notifier).Run(context.Background())
+	go webhook.NewDeliverer(db).Run(context.Background())
+	go importer.NewRunner(db).Run(context.Background())
+	go archive.NewExporter(db).Run(context.Background())
+	go erasure.NewWorker(db).Run(context.Background())
+	go revocation.NewJanitor(db).Run(context.Background())
+	go outbox.NewDispatcher(db, bus, &webhook.Sink{DB: db}).Run(context.Background())
+	go outbox.NewJanitor(db).Run(context.Background())
+
 	slog.Info("Setting up the app...")
 
 	app := fiber.New(fiber.Config{
 		JSONEncoder: json.Marshal,
 		JSONDecoder: json.Unmarshal,
+		// CalDAV clients use the WebDAV methods besides the standard ones
+		RequestMethods: append(append([]string{}, fiber.DefaultMethods...), "PROPFIND", "REPORT"),
 	})
 
 	app.Use(func(c *fiber.Ctx) error {
 		c.Locals("db", db)
+		c.Locals("revocations", revocations)
+		c.Locals("verifier", s.Verifier)
 		return c.Next()
 	})
 
@@ -64,31 +156,168 @@ func (s *Server) initRouter(app fiber.Router) {
 	api := app.Group("/api")
 
 	app.Use(cors.New(cors.Config{
-		AllowOrigins:     "http://localhost:3000",                  // Allow specific origin
-		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS", // Allow all methods
-		AllowHeaders:     "Content-Type, Authorization",            // Allow specific headers
+		AllowOrigins:     strings.Join(s.AllowedOrigins, ","),                     // Allow specific origins
+		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",                // Allow all methods
+		AllowHeaders:     "Content-Type, Authorization, " + middleware.CSRFHeader, // Allow specific headers
 		AllowCredentials: true,
 	}))
 
 	// Health check
 	api.Get("/health", handler.Health)
-	api.Get("/health/protected", middleware.RequireAuthenticated(), handler.HealthProtected)
+	api.Get("/health/protected", middleware.RequireAuthenticated(s.Verifier, s.TokenPrecedence), handler.HealthProtected)
 
-	api.Use(middleware.RequireAuthenticated())
+	// Calendar feeds authenticate with the token in their URL
+	api.Get("/productivity/calendar/feeds/:token/calendar.ics", handler.ServeCalendarFeed)
+
+	// CalDAV clients cannot use the JWT cookie, so they authenticate with
+	// app passwords over Basic auth
+	app.All("/.well-known/caldav", handler.CalDAVWellKnown)
+	app.Options("/dav/*", handler.CalDAVOptions)
+
+	dav := app.Group("/dav", middleware.RequireAppPassword())
+	dav.Add("PROPFIND", "/", middleware.RequirePermission(rbac.ReadTasks), handler.PropfindPrincipal)
+	dav.Add("PROPFIND", "/tasks/", middleware.RequirePermission(rbac.ReadTasks), handler.PropfindTasks)
+	dav.Add("REPORT", "/tasks/", middleware.RequirePermission(rbac.ReadTasks), handler.ReportTasks)
+	dav.Add("PROPFIND", "/tasks/:name", middleware.RequirePermission(rbac.ReadTasks), handler.PropfindTask)
+	dav.Get("/tasks/:name", middleware.RequirePermission(rbac.ReadTasks), handler.GetTaskResource)
+	dav.Put("/tasks/:name", middleware.RequirePermission(rbac.WriteTasks), handler.PutTaskResource)
+	dav.Delete("/tasks/:name", middleware.RequirePermission(rbac.WriteTasks), handler.DeleteTaskResource)
+
+	// Export downloads are authenticated by the signature in their link
+	api.Get("/productivity/exports/:id/archive.zip", handler.DownloadExport)
+
+	// Notifications from the auth service are authenticated by their signature
+	api.Post("/internal/auth/events", handler.HandleAuthEvent)
+
+	// Browsers fetch the token to repeat in the X-CSRF-Token header
+	api.Get("/productivity/csrf-token", handler.GetCSRFToken)
+
+	api.Use(middleware.RequireAuthenticated(s.Verifier, s.TokenPrecedence), middleware.ResolveUser(), middleware.RequireCSRF(s.AllowedOrigins))
 
 	// Task management
-	api.Post("/productivity/tasks", handler.CreateTask)
-	api.Get("/productivity/tasks", handler.GetAllTasks)
-	api.Get("/productivity/tasks/:id", handler.GetTask)
-	api.Put("/productivity/tasks/:id", handler.UpdateTask)
-	api.Delete("/productivity/tasks/:id", handler.DeleteTask)
+	api.Post("/productivity/tasks", middleware.RequirePermission(rbac.WriteTasks), handler.CreateTask)
+	api.Get("/productivity/tasks", middleware.RequirePermission(rbac.ReadTasks), handler.GetAllTasks)
+	api.Get("/productivity/tasks/:id", middleware.RequirePermission(rbac.ReadTasks), handler.GetTask)
+	api.Put("/productivity/tasks/:id", middleware.RequirePermission(rbac.WriteTasks), handler.UpdateTask)
+	api.Delete("/productivity/tasks/:id", middleware.RequirePermission(rbac.WriteTasks), handler.DeleteTask)
+	api.Get("/productivity/tasks/:id/reminders", middleware.RequirePermission(rbac.ReadTasks), handler.GetTaskReminders)
+	api.Put("/productivity/tasks/:id/reminders", middleware.RequirePermission(rbac.WriteTasks), handler.SetTaskReminders)
+
+	// Task imports
+	api.Post("/productivity/imports/preview", middleware.RequirePermission(rbac.WriteTasks), handler.PreviewImport)
+	api.Post("/productivity/imports", middleware.RequirePermission(rbac.WriteTasks), handler.CreateImport)
+	api.Get("/productivity/imports", middleware.RequirePermission(rbac.ReadTasks), handler.GetAllImports)
+	api.Get("/productivity/imports/:id", middleware.RequirePermission(rbac.ReadTasks), handler.GetImport)
+	api.Post("/productivity/imports/:id/rollback", middleware.RequirePermission(rbac.WriteTasks), handler.RollbackImport)
+
+	// Account data export
+	api.Post("/productivity/exports", middleware.RequirePermission(rbac.WriteAccount), handler.CreateExport)
+	api.Get("/productivity/exports", middleware.RequirePermission(rbac.ReadAccount), handler.GetAllExports)
+	api.Post("/productivity/exports/import", middleware.RequirePermission(rbac.WriteAccount), handler.ImportArchive)
+	api.Get("/productivity/exports/:id", middleware.RequirePermission(rbac.ReadAccount), handler.GetExport)
+
+	// Logging out needs no permission, so that every role can
+	api.Post("/productivity/account/logout", handler.Logout)
+	api.Post("/productivity/account/logout-all", handler.LogoutAll)
+
+	// Account erasure
+	api.Post("/productivity/account/erasure", middleware.RequirePermission(rbac.WriteAccount), handler.RequestErasure)
+	api.Get("/productivity/account/erasure", middleware.RequirePermission(rbac.ReadAccount), handler.GetErasure)
+	api.Delete("/productivity/account/erasure", middleware.RequirePermission(rbac.WriteAccount), handler.CancelErasure)
+
+	// Notifications
+	api.Get("/productivity/notifications", middleware.RequirePermission(rbac.ReadAccount), handler.GetAllNotifications)
+	api.Get("/productivity/notifications/unread-count", middleware.RequirePermission(rbac.ReadAccount), handler.GetUnreadNotificationCount)
+	api.Put("/productivity/notifications/read-all", middleware.RequirePermission(rbac.WriteAccount), handler.MarkAllNotificationsRead)
+	api.Get("/productivity/notifications/preferences", middleware.RequirePermission(rbac.ReadAccount), handler.GetNotificationPreferences)
+	api.Put("/productivity/notifications/preferences", middleware.RequirePermission(rbac.WriteAccount), handler.UpdateNotificationPreferences)
+	api.Put("/productivity/notifications/:id/read", middleware.RequirePermission(rbac.WriteAccount), handler.MarkNotificationRead)
+	api.Put("/productivity/notifications/:id/unread", middleware.RequirePermission(rbac.WriteAccount), handler.MarkNotificationUnread)
+	api.Delete("/productivity/notifications/:id", middleware.RequirePermission(rbac.WriteAccount), handler.DeleteNotification)
+
+	// Outbound webhooks
+	api.Post("/productivity/webhooks", middleware.RequirePermission(rbac.WriteAccount), handler.CreateWebhook)
+	api.Get("/productivity/webhooks", middleware.RequirePermission(rbac.ReadAccount), handler.GetAllWebhooks)
+	api.Get("/productivity/webhooks/:id", middleware.RequirePermission(rbac.ReadAccount), handler.GetWebhook)
+	api.Put("/productivity/webhooks/:id", middleware.RequirePermission(rbac.WriteAccount), handler.UpdateWebhook)
+	api.Delete("/productivity/webhooks/:id", middleware.RequirePermission(rbac.WriteAccount), handler.DeleteWebhook)
+	api.Get("/productivity/webhooks/:id/deliveries", middleware.RequirePermission(rbac.ReadAccount), handler.GetWebhookDeliveries)
+	api.Post("/productivity/webhooks/:id/test", middleware.RequirePermission(rbac.WriteAccount), handler.SendTestWebhook)
+
+	// Calendar export
+	api.Get("/productivity/calendar/export.ics", middleware.RequirePermission(rbac.ReadTasks), handler.ExportCalendar)
+	api.Get("/productivity/calendar/feed", middleware.RequirePermission(rbac.ReadAccount), handler.GetCalendarFeed)
+	api.Post("/productivity/calendar/feed", middleware.RequirePermission(rbac.WriteAccount), handler.CreateCalendarFeed)
+	api.Put("/productivity/calendar/feed", middleware.RequirePermission(rbac.WriteAccount), handler.UpdateCalendarFeed)
+	api.Delete("/productivity/calendar/feed", middleware.RequirePermission(rbac.WriteAccount), handler.DeleteCalendarFeed)
+
+	// App passwords for CalDAV
+	api.Post("/productivity/app-passwords", middleware.RequirePermission(rbac.WriteAccount), handler.CreateAppPassword)
+	api.Get("/productivity/app-passwords", middleware.RequirePermission(rbac.ReadAccount), handler.GetAllAppPasswords)
+	api.Delete("/productivity/app-passwords/:id", middleware.RequirePermission(rbac.WriteAccount), handler.DeleteAppPassword)
+
+	// Personal access tokens for scripts and integrations
+	api.Post("/productivity/tokens", middleware.RequirePermission(rbac.WriteAccount), handler.CreatePersonalAccessToken)
+	api.Get("/productivity/tokens", middleware.RequirePermission(rbac.ReadAccount), handler.GetAllPersonalAccessTokens)
+	api.Delete("/productivity/tokens/:id", middleware.RequirePermission(rbac.WriteAccount), handler.DeletePersonalAccessToken)
+
+	// Study subjects
+	api.Post("/productivity/subjects", middleware.RequirePermission(rbac.WriteSessions), handler.CreateSubject)
+	api.Get("/productivity/subjects", middleware.RequirePermission(rbac.ReadSessions), handler.GetAllSubjects)
+	api.Get("/productivity/subjects/:id", middleware.RequirePermission(rbac.ReadSessions), handler.GetSubject)
+	api.Put("/productivity/subjects/:id", middleware.RequirePermission(rbac.WriteSessions), handler.UpdateSubject)
+	api.Delete("/productivity/subjects/:id", middleware.RequirePermission(rbac.WriteSessions), handler.DeleteSubject)
 
 	// Pomodoro timer
-	api.Post("/productivity/pomodoro/start", handler.StartPomodoro)
-	api.Put("/productivity/pomodoro/stop", handler.StopPomodoro)
+	api.Post("/productivity/pomodoro/start", middleware.RequirePermission(rbac.WriteSessions), handler.StartPomodoro)
+	api.Put("/productivity/pomodoro/stop", middleware.RequirePermission(rbac.WriteSessions), handler.StopPomodoro)
+	api.Get("/productivity/pomodoro/active", middleware.RequirePermission(rbac.ReadSessions), handler.GetActivePomodoro)
+	api.Post("/productivity/pomodoro/active/interruptions", middleware.RequirePermission(rbac.WriteSessions), handler.LogInterruption)
+	api.Post("/productivity/pomodoro/sessions", middleware.RequirePermission(rbac.WriteSessions), handler.CreateSession)
+	api.Get("/productivity/pomodoro/sessions", middleware.RequirePermission(rbac.ReadSessions), handler.GetAllSessions)
+	api.Put("/productivity/pomodoro/sessions/:id", middleware.RequirePermission(rbac.WriteSessions), handler.UpdateSession)
+	api.Delete("/productivity/pomodoro/sessions/:id", middleware.RequirePermission(rbac.WriteSessions), handler.DeleteSession)
+
+	// Focus goals
+	api.Post("/productivity/goals", middleware.RequirePermission(rbac.WriteSessions), handler.CreateGoal)
+	api.Get("/productivity/goals", middleware.RequirePermission(rbac.ReadSessions), handler.GetAllGoals)
+	api.Put("/productivity/goals/:id", middleware.RequirePermission(rbac.WriteSessions), handler.UpdateGoal)
+	api.Delete("/productivity/goals/:id", middleware.RequirePermission(rbac.WriteSessions), handler.DeleteGoal)
+	api.Get("/productivity/goals/:id/progress", middleware.RequirePermission(rbac.ReadSessions), handler.GetGoalProgress)
+
+	// Weekly review
+	api.Get("/productivity/reports/weekly/preview", middleware.RequirePermission(rbac.ReadMetrics), handler.PreviewWeeklyReport)
+
+	// User settings
+	api.Get("/productivity/settings", middleware.RequirePermission(rbac.ReadAccount), handler.GetSettings)
+	api.Put("/productivity/settings", middleware.RequirePermission(rbac.WriteAccount), handler.UpdateSettings)
 
 	// Study performance metrics
-	api.Get("/productivity/metrics/study", handler.GenerateStudyMetrics)
+	api.Get("/productivity/metrics/study", middleware.RequirePermission(rbac.ReadMetrics), handler.GenerateStudyMetrics)
+	api.Get("/productivity/metrics/heatmap", middleware.RequirePermission(rbac.ReadMetrics), handler.GetFocusHeatmap)
+	api.Get("/productivity/metrics/time-of-day", middleware.RequirePermission(rbac.ReadMetrics), handler.GetTimeOfDayMetrics)
+
+	// Administration, with every request written to the audit log
+	admin := api.Group("/admin", middleware.AuditAdmin())
+	admin.Get("/stats", middleware.RequirePermission(rbac.ReadUsers), handler.GetAdminStats)
+	admin.Get("/service", middleware.RequirePermission(rbac.ReadUsers), handler.GetServiceStats)
+	admin.Get("/audit", middleware.RequirePermission(rbac.ReadUsers), handler.GetAdminAudit)
+	admin.Get("/users", middleware.RequirePermission(rbac.ReadUsers), handler.GetAdminUsers)
+	admin.Get("/users/:email/tasks", middleware.RequirePermission(rbac.ReadUsers), handler.GetAdminUserTasks)
+	admin.Post("/users/:email/pomodoro/stop", middleware.RequirePermission(rbac.ManageUsers), handler.AdminStopPomodoro)
+	admin.Post("/users/:email/tokens/revoke", middleware.RequirePermission(rbac.ManageUsers), handler.AdminRevokeTokens)
+	admin.Post("/users/:email/rollups/rebuild", middleware.RequirePermission(rbac.ManageUsers), handler.AdminRebuildRollups)
+	admin.Post("/users/:email/erasure", middleware.RequirePermission(rbac.ManageUsers), handler.AdminRequestErasure)
+	admin.Delete("/users/:email/erasure", middleware.RequirePermission(rbac.ManageUsers), handler.AdminCancelErasure)
+	admin.Post("/rollups/rebuild", middleware.RequirePermission(rbac.ManageUsers), handler.AdminRebuildRollups)
+	admin.Get("/erasures", middleware.RequirePermission(rbac.ReadUsers), handler.GetErasureAudits)
+	admin.Post("/webhooks", middleware.RequirePermission(rbac.ManageUsers), handler.AdminCreateWebhook)
+	admin.Get("/webhooks", middleware.RequirePermission(rbac.ReadUsers), handler.AdminGetAllWebhooks)
+	admin.Get("/webhooks/:id", middleware.RequirePermission(rbac.ReadUsers), handler.AdminGetWebhook)
+	admin.Put("/webhooks/:id", middleware.RequirePermission(rbac.ManageUsers), handler.AdminUpdateWebhook)
+	admin.Delete("/webhooks/:id", middleware.RequirePermission(rbac.ManageUsers), handler.AdminDeleteWebhook)
+	admin.Get("/webhooks/:id/deliveries", middleware.RequirePermission(rbac.ReadUsers), handler.AdminGetWebhookDeliveries)
+	admin.Post("/webhooks/:id/test", middleware.RequirePermission(rbac.ManageUsers), handler.AdminSendTestWebhook)
 }
 
 func (s *Server) Run(app *fiber.App) {
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"os"
 	"time"
@@ -9,18 +11,62 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// The typ claim tells access tokens and refresh tokens apart, so that
+// neither is accepted in place of the other.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
 type AuthTokenPair struct {
 	AccessToken  string
 	RefreshToken string
 }
 
 func CreateJWT(email string, name string, role string, expirationMinutes int) (string, error) {
+	return CreateSubjectJWT("", email, name, role, expirationMinutes)
+}
+
+// CreateSubjectJWT is CreateJWT for tokens carrying the user's stable ID as
+// the sub claim. An empty subject leaves the claim out.
+func CreateSubjectJWT(subject string, email string, name string, role string, expirationMinutes int) (string, error) {
+	return CreateFamilyJWT(subject, email, name, role, TokenTypeAccess, "", "", expirationMinutes)
+}
+
+// CreateFamilyJWT is CreateSubjectJWT for tokens of the given type
+// belonging to a refresh token family, given as the fid claim, with the
+// given jti. An empty family leaves the claim out, and an empty token ID is
+// generated.
+func CreateFamilyJWT(subject string, email string, name string, role string, tokenType string, familyID string, tokenID string, expirationMinutes int) (string, error) {
+	if tokenID == "" {
+		jti := make([]byte, 16)
+		if _, err := rand.Read(jti); err != nil {
+			return "", err
+		}
+		tokenID = hex.EncodeToString(jti)
+	}
+
 	claims := jwt.MapClaims{
+		"jti":   tokenID,
 		"email": email,
 		"name":  name,
 		"role":  role,
+		"typ":   tokenType,
+		"iat":   time.Now().Unix(),
 		"exp":   time.Now().Add(time.Minute * time.Duration(expirationMinutes)).Unix(),
 	}
+	if subject != "" {
+		claims["sub"] = subject
+	}
+	if familyID != "" {
+		claims["fid"] = familyID
+	}
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		claims["iss"] = issuer
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		claims["aud"] = audience
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
@@ -37,13 +83,21 @@ func CreateJWT(email string, name string, role string, expirationMinutes int) (s
 	return t, nil
 }
 
+// CreateAuthTokenPair creates an access token and a refresh token starting a
+// new refresh token family.
 func CreateAuthTokenPair(c *fiber.Ctx, email string, name string, role string) (AuthTokenPair, error) {
-	accessToken, err := CreateJWT(email, name, role, 5) // 5 minutes
+	family := make([]byte, 16)
+	if _, err := rand.Read(family); err != nil {
+		return AuthTokenPair{}, errors.New("failed to create token family")
+	}
+	familyID := hex.EncodeToString(family)
+
+	accessToken, err := CreateFamilyJWT("", email, name, role, TokenTypeAccess, familyID, "", 5) // 5 minutes
 	if err != nil {
 		return AuthTokenPair{}, errors.New("failed to create access token")
 	}
 
-	refreshToken, err := CreateJWT(email, name, role, 7*24*60) // 7 days
+	refreshToken, err := CreateFamilyJWT("", email, name, role, TokenTypeRefresh, familyID, "", 7*24*60) // 7 days
 	if err != nil {
 		return AuthTokenPair{}, errors.New("failed to create refresh token")
 	}
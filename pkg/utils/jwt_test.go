@@ -62,6 +62,44 @@ func TestCreateJWT(t *testing.T) {
 	}
 }
 
+func TestCreateFamilyJWT(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	token, err := CreateFamilyJWT("42", "test@example.com", "Test User", "user", TokenTypeRefresh, "family", "token", 60)
+	if err != nil {
+		t.Fatalf("Failed to create JWT: %v", err)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	}); err != nil {
+		t.Fatalf("Failed to parse JWT: %v", err)
+	}
+	if claims["fid"] != "family" || claims["jti"] != "token" || claims["sub"] != "42" || claims["typ"] != TokenTypeRefresh {
+		t.Errorf("Expected fid, jti, sub and typ claims, got %v", claims)
+	}
+
+	token, err = CreateFamilyJWT("", "test@example.com", "Test User", "user", TokenTypeAccess, "", "", 60)
+	if err != nil {
+		t.Fatalf("Failed to create JWT: %v", err)
+	}
+
+	claims = jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	}); err != nil {
+		t.Fatalf("Failed to parse JWT: %v", err)
+	}
+	if _, ok := claims["fid"]; ok {
+		t.Errorf("Expected no fid claim, got %v", claims["fid"])
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		t.Error("Expected a generated jti claim")
+	}
+}
+
 func TestCreateAuthTokenPair(t *testing.T) {
 	os.Setenv("JWT_SECRET", "secret")
 	defer os.Unsetenv("JWT_SECRET")
@@ -105,6 +143,9 @@ func TestCreateAuthTokenPair(t *testing.T) {
 			if claims["role"] != test.role {
 				t.Errorf("Role claim mismatch in access token: got %v, want %v", claims["role"], test.role)
 			}
+			if claims["typ"] != TokenTypeAccess {
+				t.Errorf("Type claim mismatch in access token: got %v, want %v", claims["typ"], TokenTypeAccess)
+			}
 		} else {
 			t.Errorf("Invalid access token claims or token")
 		}
@@ -129,6 +170,9 @@ func TestCreateAuthTokenPair(t *testing.T) {
 			if claims["role"] != test.role {
 				t.Errorf("Role claim mismatch in refresh token: got %v, want %v", claims["role"], test.role)
 			}
+			if claims["typ"] != TokenTypeRefresh {
+				t.Errorf("Type claim mismatch in refresh token: got %v, want %v", claims["typ"], TokenTypeRefresh)
+			}
 		} else {
 			t.Errorf("Invalid refresh token claims or token")
 		}
@@ -0,0 +1,17 @@
+package utils
+
+import "time"
+
+// StartOfDay returns midnight of the day t falls on in the given location.
+func StartOfDay(t time.Time, location *time.Location) time.Time {
+	local := t.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+}
+
+// StartOfWeek returns midnight of the Monday of the week t falls on in the
+// given location.
+func StartOfWeek(t time.Time, location *time.Location) time.Time {
+	day := StartOfDay(t, location)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
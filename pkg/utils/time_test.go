@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartOfDay(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	tests := []struct {
+		input    time.Time
+		location *time.Location
+		expected time.Time
+	}{
+		{time.Date(2024, 7, 27, 14, 30, 0, 0, time.UTC), time.UTC, time.Date(2024, 7, 27, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 7, 27, 20, 0, 0, 0, time.UTC), jakarta, time.Date(2024, 7, 28, 0, 0, 0, 0, jakarta)},
+		{time.Date(2024, 7, 27, 0, 0, 0, 0, time.UTC), time.UTC, time.Date(2024, 7, 27, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		result := StartOfDay(test.input, test.location)
+		if !result.Equal(test.expected) {
+			t.Errorf("StartOfDay(%v, %v) = %v, expected %v", test.input, test.location, result, test.expected)
+		}
+	}
+}
+
+func TestStartOfWeek(t *testing.T) {
+	tests := []struct {
+		input    time.Time
+		expected time.Time
+	}{
+		{time.Date(2024, 7, 22, 9, 0, 0, 0, time.UTC), time.Date(2024, 7, 22, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 7, 27, 14, 30, 0, 0, time.UTC), time.Date(2024, 7, 22, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 7, 28, 23, 59, 0, 0, time.UTC), time.Date(2024, 7, 22, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 8, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 7, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		result := StartOfWeek(test.input, time.UTC)
+		if !result.Equal(test.expected) {
+			t.Errorf("StartOfWeek(%v) = %v, expected %v", test.input, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewSecretToken(t *testing.T) {
+	token, hash, err := NewSecretToken("app_")
+	if err != nil {
+		t.Fatalf("NewSecretToken() error = %v", err)
+	}
+
+	if !strings.HasPrefix(token, "app_") || len(token) != len("app_")+43 {
+		t.Errorf("NewSecretToken() token = %q, want app_ followed by 43 characters", token)
+	}
+	if hash != HashToken(token) || len(hash) != 64 {
+		t.Errorf("NewSecretToken() hash = %q, want the SHA-256 hash of the token", hash)
+	}
+
+	other, _, err := NewSecretToken("app_")
+	if err != nil || other == token {
+		t.Errorf("NewSecretToken() returned the same token twice")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	expected := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if result := HashToken(""); result != expected {
+		t.Errorf("HashToken(%q) = %q, want %q", "", result, expected)
+	}
+}
@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"fmt"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -34,3 +37,92 @@ func ValidateTime(timeStr string) (bool, string, time.Time) {
 
 	return true, "Time is valid", parsedTime
 }
+
+func ValidateTimeRange(startStr string, endStr string) (bool, string, time.Time, time.Time) {
+	isStartValid, _, start := ValidateTime(startStr)
+	if !isStartValid {
+		return false, "Start time format is invalid", time.Time{}, time.Time{}
+	}
+
+	isEndValid, _, end := ValidateTime(endStr)
+	if !isEndValid {
+		return false, "End time format is invalid", time.Time{}, time.Time{}
+	}
+
+	if !end.After(start) {
+		return false, "End time must be after start time", time.Time{}, time.Time{}
+	}
+
+	return true, "Time range is valid", start, end
+}
+
+func ValidatePagination(pageStr string, pageSizeStr string) (bool, string, int, int) {
+	const (
+		defaultPageSize = 20
+		maxPageSize     = 100
+	)
+
+	page, pageSize := 1, defaultPageSize
+
+	if pageStr != "" {
+		parsed, err := strconv.Atoi(pageStr)
+		if err != nil || parsed < 1 {
+			return false, "Page must be a positive integer", 0, 0
+		}
+		page = parsed
+	}
+
+	if pageSizeStr != "" {
+		parsed, err := strconv.Atoi(pageSizeStr)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			return false, fmt.Sprintf("Page size must be an integer between 1 and %d", maxPageSize), 0, 0
+		}
+		pageSize = parsed
+	}
+
+	return true, "Pagination is valid", page, pageSize
+}
+
+func ValidateTimeZone(name string) (bool, string, *time.Location) {
+	if name == "" {
+		return true, "Time zone is valid", time.UTC
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return false, "Time zone is invalid", nil
+	}
+
+	return true, "Time zone is valid", location
+}
+
+func ValidateColor(color string) (bool, string) {
+	const colorRegexPattern = `^#[0-9a-fA-F]{6}$`
+	re := regexp.MustCompile(colorRegexPattern)
+	if !re.MatchString(color) {
+		return false, "Color must be a hex code such as '#1e90ff'"
+	}
+	return true, "Color is valid"
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http or https
+// URL whose host resolves only to public addresses, so that webhooks cannot
+// be used to reach the service's own network.
+func ValidateWebhookURL(rawURL string) (bool, string) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false, "Webhook URL must be an absolute http or https URL"
+	}
+
+	ips, err := lookupIP(parsed.Hostname())
+	if err != nil || len(ips) == 0 {
+		return false, "Webhook URL host could not be resolved"
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return false, "Webhook URL must not point to a private or local address"
+		}
+	}
+
+	return true, "Webhook URL is valid"
+}
@@ -1,6 +1,10 @@
 package utils
 
-import "testing"
+import (
+	"errors"
+	"net"
+	"testing"
+)
 
 func TestValidateEmail(t *testing.T) {
 	tests := []struct {
@@ -57,3 +61,152 @@ func TestValidateTime(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateTimeRange(t *testing.T) {
+	tests := []struct {
+		start    string
+		end      string
+		expected bool
+		message  string
+	}{
+		{"2024-07-27T14:00:00Z", "2024-07-27T14:25:00Z", true, "Time range is valid"},
+		{"2024-07-27T14:00:00+01:00", "2024-07-27T14:00:00Z", true, "Time range is valid"},
+
+		{"2024-07-27 14:00:00", "2024-07-27T14:25:00Z", false, "Start time format is invalid"},
+		{"2024-07-27T14:00:00Z", "InvalidTimeString", false, "End time format is invalid"},
+		{"2024-07-27T14:25:00Z", "2024-07-27T14:00:00Z", false, "End time must be after start time"},
+		{"2024-07-27T14:00:00Z", "2024-07-27T14:00:00Z", false, "End time must be after start time"},
+	}
+
+	for _, test := range tests {
+		valid, msg, _, _ := ValidateTimeRange(test.start, test.end)
+		if valid != test.expected || msg != test.message {
+			t.Errorf("ValidateTimeRange(%q, %q) = (%v, %q), expected (%v, %q)",
+				test.start, test.end, valid, msg, test.expected, test.message)
+		}
+	}
+}
+
+func TestValidatePagination(t *testing.T) {
+	tests := []struct {
+		page             string
+		pageSize         string
+		expected         bool
+		expectedPage     int
+		expectedPageSize int
+	}{
+		{"", "", true, 1, 20},
+		{"3", "50", true, 3, 50},
+		{"1", "100", true, 1, 100},
+
+		{"0", "", false, 0, 0},
+		{"-1", "", false, 0, 0},
+		{"abc", "", false, 0, 0},
+		{"", "0", false, 0, 0},
+		{"", "101", false, 0, 0},
+	}
+
+	for _, test := range tests {
+		valid, _, page, pageSize := ValidatePagination(test.page, test.pageSize)
+		if valid != test.expected || page != test.expectedPage || pageSize != test.expectedPageSize {
+			t.Errorf("ValidatePagination(%q, %q) = (%v, %d, %d), expected (%v, %d, %d)",
+				test.page, test.pageSize, valid, page, pageSize, test.expected, test.expectedPage, test.expectedPageSize)
+		}
+	}
+}
+
+func TestValidateTimeZone(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+		location string
+	}{
+		{"", true, "UTC"},
+		{"UTC", true, "UTC"},
+		{"Europe/Berlin", true, "Europe/Berlin"},
+		{"Asia/Jakarta", true, "Asia/Jakarta"},
+
+		{"Mars/Olympus_Mons", false, ""},
+		{"not a zone", false, ""},
+	}
+
+	for _, test := range tests {
+		valid, _, location := ValidateTimeZone(test.input)
+		if valid != test.expected {
+			t.Errorf("ValidateTimeZone(%q) = %v, expected %v", test.input, valid, test.expected)
+			continue
+		}
+		if valid && location.String() != test.location {
+			t.Errorf("ValidateTimeZone(%q) location = %q, expected %q", test.input, location.String(), test.location)
+		}
+	}
+}
+
+func TestValidateColor(t *testing.T) {
+	tests := []struct {
+		color    string
+		expected bool
+	}{
+		{"#1e90ff", true},
+		{"#FFFFFF", true},
+		{"#000000", true},
+
+		{"1e90ff", false},
+		{"#fff", false},
+		{"#1e90fg", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		result, _ := ValidateColor(test.color)
+		if result != test.expected {
+			t.Errorf("ValidateColor(%q) = %v; want %v", test.color, result, test.expected)
+		}
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	defer func(original func(string) ([]net.IP, error)) { lookupIP = original }(lookupIP)
+	lookupIP = func(host string) ([]net.IP, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			return []net.IP{ip}, nil
+		}
+		switch host {
+		case "example.com":
+			return []net.IP{net.ParseIP("93.184.216.34")}, nil
+		case "localhost":
+			return []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}, nil
+		case "internal.example.com":
+			return []net.IP{net.ParseIP("93.184.216.34"), net.ParseIP("10.0.0.5")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://example.com/hooks/productivity", true},
+		{"https://93.184.216.34/hook", true},
+
+		{"http://localhost:9000/hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://192.168.1.10/hook", false},
+		{"https://internal.example.com/hook", false},
+		{"https://unknown.example.com/hook", false},
+		{"", false},
+		{"example.com/hook", false},
+		{"ftp://example.com/hook", false},
+		{"https://", false},
+		{"://example.com", false},
+	}
+
+	for _, test := range tests {
+		result, _ := ValidateWebhookURL(test.url)
+		if result != test.expected {
+			t.Errorf("ValidateWebhookURL(%q) = %v; want %v", test.url, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxAttempts = 8
+	maxBackoff  = 6 * time.Hour
+	claimLease  = time.Minute
+)
+
+// Backoff returns how long to wait before retrying a delivery that failed
+// for the given number of times.
+func Backoff(attempts int) time.Duration {
+	backoff := 30 * time.Second
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
+
+// Deliverer sends pending webhook deliveries in the background. Each
+// delivery is claimed under a SKIP LOCKED row lock before it is attempted,
+// so several replicas can run a deliverer without sending duplicates.
+type Deliverer struct {
+	DB        *gorm.DB
+	Client    *http.Client
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewDeliverer(db *gorm.DB) *Deliverer {
+	return &Deliverer{
+		DB:        db,
+		Client:    utils.NewPublicHTTPClient(10 * time.Second),
+		Interval:  5 * time.Second,
+		BatchSize: 100,
+	}
+}
+
+func (d *Deliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.RunOnce(time.Now()); err != nil {
+				slog.Error("Failed to deliver webhooks", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// RunOnce attempts up to BatchSize deliveries that are due at now.
+func (d *Deliverer) RunOnce(now time.Time) error {
+	for i := 0; i < d.BatchSize; i++ {
+		processed, err := d.processNext(now)
+		if err != nil {
+			return err
+		}
+		if !processed {
+			return nil
+		}
+	}
+	return nil
+}
+
+// processNext claims the next due delivery and attempts it. The claim is
+// committed before the delivery is sent, so that no row lock or connection
+// is held during the request, and lasts for claimLease, after which a
+// delivery whose deliverer died mid-send is attempted again.
+func (d *Deliverer) processNext(now time.Time) (bool, error) {
+	processed := false
+	var (
+		delivery model.WebhookDelivery
+		endpoint model.WebhookEndpoint
+		send     bool
+	)
+
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, now.UTC()).
+			Where("locked_until IS NULL OR locked_until <= ?", now.UTC()).
+			Order("next_attempt_at, id").
+			First(&delivery).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		processed = true
+
+		err = tx.First(&endpoint, delivery.EndpointID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !endpoint.Active) {
+			delivery.Status = model.DeliveryFailed
+			delivery.LastError = "Endpoint was removed or disabled"
+			return tx.Save(&delivery).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		// The attempt is counted when it is claimed, so a delivery whose
+		// deliverer keeps dying mid-send still runs out of attempts.
+		send = true
+		delivery.Attempts++
+		return tx.Model(&delivery).UpdateColumns(map[string]any{
+			"attempts":     delivery.Attempts,
+			"locked_until": now.Add(claimLease).UTC(),
+		}).Error
+	})
+	if err != nil || !send {
+		return processed, err
+	}
+
+	code, err := d.send(endpoint, delivery, now)
+	updates := map[string]any{"response_code": code, "locked_until": nil}
+	if err != nil {
+		updates["last_error"] = err.Error()
+		if delivery.Attempts >= maxAttempts {
+			updates["status"] = model.DeliveryFailed
+		} else {
+			updates["next_attempt_at"] = now.Add(Backoff(delivery.Attempts)).UTC()
+		}
+	} else {
+		updates["status"] = model.DeliverySucceeded
+		updates["delivered_at"] = now.UTC()
+		updates["last_error"] = ""
+	}
+
+	// A claim that lapsed may have been taken over by another deliverer, in
+	// which case the outcome of that later attempt is the one recorded.
+	err = d.DB.Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", delivery.ID, model.DeliveryPending, delivery.Attempts).
+		Updates(updates).Error
+
+	return processed, err
+}
+
+// send posts a delivery and returns the response code, if any. Any status
+// outside 2xx counts as a failure.
+func (d *Deliverer) send(endpoint model.WebhookEndpoint, delivery model.WebhookDelivery, now time.Time) (*int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, delivery.EventID)
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, now, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	code := resp.StatusCode
+	if code < 200 || code >= 300 {
+		return &code, fmt.Errorf("endpoint responded with status %d", code)
+	}
+
+	return &code, nil
+}
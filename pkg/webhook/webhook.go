@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/outbox"
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventPing is the event type of test deliveries.
+const EventPing = "ping"
+
+const (
+	SignatureHeader = "Webhook-Signature"
+	EventIDHeader   = "Webhook-Id"
+	EventTypeHeader = "Webhook-Event"
+
+	// signatureTolerance is how old a signature Verify still accepts.
+	signatureTolerance = 5 * time.Minute
+)
+
+// EventTypes lists the event types endpoints can subscribe to.
+var EventTypes = []string{
+	outbox.EventTaskCreated,
+	outbox.EventTaskUpdated,
+	outbox.EventTaskCompleted,
+	outbox.EventTaskDeleted,
+	outbox.EventPomodoroStarted,
+	outbox.EventPomodoroStopped,
+}
+
+func IsValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the body posted to webhook endpoints.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// NewEvent creates an event with a fresh random ID.
+func NewEvent(eventType string, data interface{}, now time.Time) (Event, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: "evt_" + id, Type: eventType, CreatedAt: now.UTC(), Data: data}, nil
+}
+
+// NewSecret generates a signing secret for an endpoint.
+func NewSecret() (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + secret, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign computes the signature header of a delivery attempt at timestamp. It
+// has the form "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">", so
+// receivers can both authenticate the body and reject replayed requests.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, computeMAC(secret, t, body))
+}
+
+// Verify checks a signature header produced by Sign, rejecting signatures
+// older than five minutes at now. It is what receivers are expected to do,
+// and documents the scheme.
+func Verify(secret string, header string, body []byte, now time.Time) bool {
+	var t, mac string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			mac = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || mac == "" {
+		return false
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return false
+	}
+
+	return hmac.Equal([]byte(mac), []byte(computeMAC(secret, t, body)))
+}
+
+func computeMAC(secret string, t string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(t))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Enqueue records a delivery of the user's event to every active endpoint
+// subscribed to its type, both the user's own and the service-wide ones.
+// The deliveries are sent by the Deliverer.
+func Enqueue(db *gorm.DB, userID string, event Event) error {
+	var endpoints []model.WebhookEndpoint
+	err := db.Where("user_id IN ? AND active = ?", []string{userID, model.ServiceWideWebhook}, true).Find(&endpoints).Error
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event.Type) {
+			continue
+		}
+		if _, err := EnqueueTo(db, endpoint, userID, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EnqueueTo records a delivery of the user's event to a single endpoint,
+// regardless of its subscriptions. The delivery belongs to the user whose
+// event it carries, even on a service-wide endpoint, so that it is erased
+// along with the user. An event already queued for the endpoint is not
+// queued again.
+func EnqueueTo(db *gorm.DB, endpoint model.WebhookEndpoint, userID string, event Event) (model.WebhookDelivery, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	delivery := model.WebhookDelivery{
+		EndpointID:    endpoint.ID,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       string(payload),
+		Status:        model.DeliveryPending,
+		NextAttemptAt: event.CreatedAt,
+		CreatedAt:     event.CreatedAt,
+		UserID:        userID,
+	}
+
+	return delivery, db.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery).Error
+}
+
+// Sink queues outbox events for delivery to the webhooks subscribed to them.
+// Webhook event IDs are derived from the outbox event IDs, so an outbox
+// event published twice is still only delivered once per endpoint.
+type Sink struct {
+	DB *gorm.DB
+}
+
+func (s *Sink) Name() string {
+	return "webhooks"
+}
+
+func (s *Sink) Publish(ctx context.Context, event outbox.Event) error {
+	return Enqueue(s.DB, event.UserID, Event{
+		ID:        fmt.Sprintf("evt_%d", event.ID),
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Data,
+	})
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abyan-dev/productivity/pkg/model"
+	"github.com/abyan-dev/productivity/pkg/outbox"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"id":"evt_1","type":"task.created"}`)
+	signedAt := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	header := Sign(secret, signedAt, body)
+
+	if !strings.HasPrefix(header, "t=1719835200,v1=") {
+		t.Fatalf("Sign() = %q, want prefix %q", header, "t=1719835200,v1=")
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		header   string
+		body     []byte
+		now      time.Time
+		expected bool
+	}{
+		{"valid", secret, header, body, signedAt.Add(time.Minute), true},
+		{"wrong secret", "whsec_other", header, body, signedAt, false},
+		{"tampered body", secret, header, []byte(`{"id":"evt_2","type":"task.created"}`), signedAt, false},
+		{"too old", secret, header, body, signedAt.Add(10 * time.Minute), false},
+		{"from the future", secret, header, body, signedAt.Add(-10 * time.Minute), false},
+		{"missing signature", secret, "t=1719835200", body, signedAt, false},
+		{"malformed", secret, "garbage", body, signedAt, false},
+	}
+
+	for _, test := range tests {
+		if result := Verify(test.secret, test.header, test.body, test.now); result != test.expected {
+			t.Errorf("%s: Verify() = %v, want %v", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{10, 4*time.Hour + 16*time.Minute},
+		{12, 6 * time.Hour},
+	}
+
+	for _, test := range tests {
+		if result := Backoff(test.attempts); result != test.expected {
+			t.Errorf("Backoff(%d) = %v, want %v", test.attempts, result, test.expected)
+		}
+	}
+}
+
+func TestSubscribes(t *testing.T) {
+	endpoint := model.WebhookEndpoint{EventTypes: "task.created,pomodoro.stopped"}
+
+	tests := []struct {
+		eventType string
+		expected  bool
+	}{
+		{outbox.EventTaskCreated, true},
+		{outbox.EventPomodoroStopped, true},
+		{outbox.EventTaskDeleted, false},
+		{"task", false},
+	}
+
+	for _, test := range tests {
+		if result := endpoint.Subscribes(test.eventType); result != test.expected {
+			t.Errorf("Subscribes(%q) = %v, want %v", test.eventType, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+#!/bin/bash
+
+CONTAINER_NAME="mailhog"
+IMAGE="mailhog/mailhog"
+SMTP_PORT="1025:1025"
+UI_PORT="8025:8025"
+
+if [ "$(docker ps -a -q -f name=$CONTAINER_NAME)" ]; then
+    if [ "$(docker ps -q -f name=$CONTAINER_NAME)" ]; then
+        echo "Container '$CONTAINER_NAME' is already running."
+    else
+        echo "Starting existing container '$CONTAINER_NAME'."
+        exec docker start $CONTAINER_NAME
+    fi
+else
+    echo "Creating and starting a new container '$CONTAINER_NAME'."
+    exec docker run -d --name $CONTAINER_NAME -p $SMTP_PORT -p $UI_PORT $IMAGE
+fi